@@ -0,0 +1,95 @@
+package protocol
+
+import "fmt"
+
+// Queue health levels reported by HealthFromQueue.
+const (
+	QueueHealthOK       = "ok"
+	QueueHealthElevated = "elevated"
+	QueueHealthCritical = "critical"
+)
+
+// AgentInfoPayload is sent periodically by agent with agent-wide state that
+// does not belong to any single monitor. ConfigRevision is the highest
+// TaskPayload.ConfigRevision the agent has applied. The clock fields describe
+// how far the agent's timestamps can be trusted; ClockSource names what the
+// clock is synchronized against, e.g. "ntp" or "ptp".
+type AgentInfoPayload struct {
+	QueueDepth      int    `json:"queue_depth"`
+	QueueCapacity   int    `json:"queue_capacity"`
+	DroppedMessages int64  `json:"dropped_messages"`
+	ConfigRevision  int    `json:"config_revision"`
+	ClockSynced     bool   `json:"clock_synced,omitempty"`
+	NTPOffsetMs     int    `json:"ntp_offset_ms,omitempty"`
+	ClockSource     string `json:"clock_source,omitempty"`
+}
+
+// Validate reports whether the agent info is well formed.
+func (p AgentInfoPayload) Validate() error {
+	if p.QueueDepth < 0 {
+		return fmt.Errorf("agent_info: queue_depth must be non-negative, got %d", p.QueueDepth)
+	}
+	if p.QueueCapacity < 0 {
+		return fmt.Errorf("agent_info: queue_capacity must be non-negative, got %d", p.QueueCapacity)
+	}
+	if p.DroppedMessages < 0 {
+		return fmt.Errorf("agent_info: dropped_messages must be non-negative, got %d", p.DroppedMessages)
+	}
+	if p.ConfigRevision < 0 {
+		return fmt.Errorf("agent_info: config_revision must be non-negative, got %d", p.ConfigRevision)
+	}
+	return nil
+}
+
+// MaxTrustedClockOffsetMs is the largest offset from its time source at which
+// an agent's timestamps are still used for ordering.
+const MaxTrustedClockOffsetMs = 1000
+
+// TrustTimestamps reports whether the hub should order the agent's messages
+// by their own timestamps. Agents whose clocks are not synchronized, or are
+// too far off, have their messages stamped on receipt instead.
+func TrustTimestamps(info AgentInfoPayload) bool {
+	if !info.ClockSynced {
+		return false
+	}
+	offset := info.NTPOffsetMs
+	if offset < 0 {
+		offset = -offset
+	}
+	return offset <= MaxTrustedClockOffsetMs
+}
+
+// QueueHealth classifies the reported send queue pressure.
+func (p AgentInfoPayload) QueueHealth() string {
+	return HealthFromQueue(p.QueueDepth, p.QueueCapacity)
+}
+
+// HealthFromQueue classifies send queue pressure: below 50% full is ok, below
+// 90% is elevated, and anything fuller is critical. A queue without capacity is
+// always critical since it cannot buffer anything.
+func HealthFromQueue(depth, capacity int) string {
+	if capacity <= 0 {
+		return QueueHealthCritical
+	}
+	switch fill := float64(depth) / float64(capacity); {
+	case fill < 0.5:
+		return QueueHealthOK
+	case fill < 0.9:
+		return QueueHealthElevated
+	default:
+		return QueueHealthCritical
+	}
+}
+
+// ConfigOutOfSync reports whether the agent's applied config revision differs
+// from the one the hub last pushed, in which case the hub should re-send the
+// agent's tasks. A revision ahead of the hub's also counts, since it means the
+// hub lost state.
+func ConfigOutOfSync(hubRev, agentRev int) bool {
+	return hubRev != agentRev
+}
+
+// NewAgentInfoMessage creates an agent info message.
+func NewAgentInfoMessage(info AgentInfoPayload) *Message {
+	return MustNewMessage(MsgTypeAgentInfo, info)
+}
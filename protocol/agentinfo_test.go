@@ -0,0 +1,101 @@
+package protocol
+
+import "testing"
+
+func TestHealthFromQueue(t *testing.T) {
+	tests := []struct {
+		depth, capacity int
+		want            string
+	}{
+		{0, 100, QueueHealthOK},
+		{49, 100, QueueHealthOK},
+		{50, 100, QueueHealthElevated},
+		{89, 100, QueueHealthElevated},
+		{90, 100, QueueHealthCritical},
+		{100, 100, QueueHealthCritical},
+		{150, 100, QueueHealthCritical},
+		{0, 0, QueueHealthCritical},
+	}
+	for _, tt := range tests {
+		if got := HealthFromQueue(tt.depth, tt.capacity); got != tt.want {
+			t.Errorf("HealthFromQueue(%d, %d) = %q, want %q", tt.depth, tt.capacity, got, tt.want)
+		}
+	}
+}
+
+func TestAgentInfoValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       AgentInfoPayload
+		wantErr bool
+	}{
+		{"valid", AgentInfoPayload{QueueDepth: 3, QueueCapacity: 10, DroppedMessages: 2}, false},
+		{"negative depth", AgentInfoPayload{QueueDepth: -1}, true},
+		{"negative capacity", AgentInfoPayload{QueueCapacity: -1}, true},
+		{"negative drops", AgentInfoPayload{DroppedMessages: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAgentInfoMessageRoundTrip(t *testing.T) {
+	m := NewAgentInfoMessage(AgentInfoPayload{QueueDepth: 95, QueueCapacity: 100, DroppedMessages: 7})
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var p AgentInfoPayload
+	if err := m.ParsePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.DroppedMessages != 7 || p.QueueHealth() != QueueHealthCritical {
+		t.Errorf("round trip = %+v, health %q", p, p.QueueHealth())
+	}
+}
+
+func TestConfigOutOfSync(t *testing.T) {
+	tests := []struct {
+		hubRev, agentRev int
+		want             bool
+	}{
+		{0, 0, false},
+		{5, 5, false},
+		{5, 4, true},
+		{5, 6, true},
+		{3, 0, true},
+	}
+	for _, tt := range tests {
+		if got := ConfigOutOfSync(tt.hubRev, tt.agentRev); got != tt.want {
+			t.Errorf("ConfigOutOfSync(%d, %d) = %v, want %v", tt.hubRev, tt.agentRev, got, tt.want)
+		}
+	}
+	if err := (AgentInfoPayload{ConfigRevision: -1}).Validate(); err == nil {
+		t.Error("Validate() accepted a negative config revision")
+	}
+}
+
+func TestTrustTimestamps(t *testing.T) {
+	tests := []struct {
+		name string
+		info AgentInfoPayload
+		want bool
+	}{
+		{"unsynced", AgentInfoPayload{NTPOffsetMs: 0}, false},
+		{"synced", AgentInfoPayload{ClockSynced: true, NTPOffsetMs: 12, ClockSource: "ntp"}, true},
+		{"at limit", AgentInfoPayload{ClockSynced: true, NTPOffsetMs: MaxTrustedClockOffsetMs}, true},
+		{"behind at limit", AgentInfoPayload{ClockSynced: true, NTPOffsetMs: -MaxTrustedClockOffsetMs}, true},
+		{"too far ahead", AgentInfoPayload{ClockSynced: true, NTPOffsetMs: MaxTrustedClockOffsetMs + 1}, false},
+		{"too far behind", AgentInfoPayload{ClockSynced: true, NTPOffsetMs: -5000}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrustTimestamps(tt.info); got != tt.want {
+				t.Errorf("TrustTimestamps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Aliases are short integers the hub assigns to monitors in their task so
+// that high-volume heartbeats can carry the alias instead of the full monitor
+// ID. Aliases are scoped to one agent connection and zero means none.
+
+// AssignAliases gives each task a unique alias numbered from 1 and returns
+// the resulting alias table.
+func AssignAliases(tasks []TaskPayload) map[int]string {
+	table := make(map[int]string, len(tasks))
+	for i := range tasks {
+		tasks[i].Alias = i + 1
+		table[tasks[i].Alias] = tasks[i].MonitorID
+	}
+	return table
+}
+
+// BuildAliasTable collects the aliases of tasks received from the hub. It
+// fails if two monitors share an alias.
+func BuildAliasTable(tasks []TaskPayload) (map[int]string, error) {
+	table := make(map[int]string, len(tasks))
+	for _, t := range tasks {
+		if t.Alias == 0 {
+			continue
+		}
+		if existing, ok := table[t.Alias]; ok && existing != t.MonitorID {
+			return nil, fmt.Errorf("alias %d is assigned to both %s and %s", t.Alias, existing, t.MonitorID)
+		}
+		table[t.Alias] = t.MonitorID
+	}
+	return table, nil
+}
+
+// ResolveAlias returns the monitor ID an alias stands for.
+func ResolveAlias(alias int, table map[int]string) (string, error) {
+	monitorID, ok := table[alias]
+	if !ok {
+		return "", fmt.Errorf("unknown alias %d", alias)
+	}
+	return monitorID, nil
+}
+
+// ResolveAlias fills in MonitorID from the heartbeat's alias. Heartbeats that
+// already carry a monitor ID are left as they are.
+func (h *HeartbeatPayload) ResolveAlias(table map[int]string) error {
+	if h.MonitorID != "" {
+		return nil
+	}
+	monitorID, err := ResolveAlias(h.Alias, table)
+	if err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+	h.MonitorID = monitorID
+	return nil
+}
+
+// aliasKey stands in for the monitor ID of a heartbeat that carries only an
+// alias, wherever heartbeats are tracked per monitor within one connection.
+func aliasKey(alias int) string {
+	return fmt.Sprintf("alias:%d", alias)
+}
+
+// monitorKey identifies the monitor of a heartbeat: its monitor ID, or its
+// alias when that is all it carries.
+func (h HeartbeatPayload) monitorKey() string {
+	if h.MonitorID == "" && h.Alias > 0 {
+		return aliasKey(h.Alias)
+	}
+	return h.MonitorID
+}
+
+// monitorIDIn returns the monitor ID of a heartbeat, looking its alias up in
+// table when that is all it carries. It returns "" for an alias the table does
+// not know.
+func (h HeartbeatPayload) monitorIDIn(table map[int]string) string {
+	if h.MonitorID == "" && h.Alias > 0 {
+		return table[h.Alias]
+	}
+	return h.MonitorID
+}
+
+// ResolveAliases returns a transform that fills in the monitor ID of
+// heartbeats that carry only an alias, using the connection's alias table. A
+// heartbeat with an unknown alias fails. Other messages pass through
+// unchanged.
+func ResolveAliases(table map[int]string) Transform {
+	return func(m *Message) (*Message, error) {
+		if m.Type != MsgTypeHeartbeat {
+			return m, nil
+		}
+		var hb HeartbeatPayload
+		if err := m.ParsePayload(&hb); err != nil {
+			return nil, err
+		}
+		if hb.MonitorID != "" || hb.Alias == 0 {
+			return m, nil
+		}
+		if err := hb.ResolveAlias(table); err != nil {
+			return nil, err
+		}
+		payload, err := json.Marshal(hb)
+		if err != nil {
+			return nil, err
+		}
+		out := *m
+		out.Payload = payload
+		return &out, nil
+	}
+}
+
+// NewAliasedHeartbeatMessage creates a heartbeat message that identifies the
+// monitor by its alias.
+func NewAliasedHeartbeatMessage(alias int, status string, latencyMs int, errorMsg string) *Message {
+	return MustNewMessage(MsgTypeHeartbeat, HeartbeatPayload{
+		Alias:        alias,
+		Status:       status,
+		LatencyMs:    latencyMs,
+		ErrorMessage: errorMsg,
+	})
+}
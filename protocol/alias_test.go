@@ -0,0 +1,142 @@
+package protocol
+
+import (
+	"encoding/json"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestAssignAliases(t *testing.T) {
+	tasks := []TaskPayload{{MonitorID: "a"}, {MonitorID: "b"}, {MonitorID: "c"}}
+	table := AssignAliases(tasks)
+	if want := map[int]string{1: "a", 2: "b", 3: "c"}; !maps.Equal(table, want) {
+		t.Errorf("AssignAliases() = %v, want %v", table, want)
+	}
+	for i, task := range tasks {
+		if task.Alias != i+1 {
+			t.Errorf("tasks[%d].Alias = %d, want %d", i, task.Alias, i+1)
+		}
+	}
+
+	built, err := BuildAliasTable(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(built, table) {
+		t.Errorf("BuildAliasTable() = %v, want %v", built, table)
+	}
+}
+
+func TestBuildAliasTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		tasks   []TaskPayload
+		want    map[int]string
+		wantErr bool
+	}{
+		{"empty", nil, map[int]string{}, false},
+		{"unaliased tasks are skipped", []TaskPayload{{MonitorID: "a"}, {MonitorID: "b", Alias: 4}}, map[int]string{4: "b"}, false},
+		{"repeated task", []TaskPayload{{MonitorID: "a", Alias: 1}, {MonitorID: "a", Alias: 1}}, map[int]string{1: "a"}, false},
+		{"conflict", []TaskPayload{{MonitorID: "a", Alias: 1}, {MonitorID: "b", Alias: 1}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildAliasTable(tt.tasks)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildAliasTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Errorf("BuildAliasTable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeartbeatResolveAlias(t *testing.T) {
+	table := map[int]string{1: "mon-1"}
+	tests := []struct {
+		name    string
+		hb      HeartbeatPayload
+		want    string
+		wantErr bool
+	}{
+		{"by alias", HeartbeatPayload{Alias: 1, Status: "up"}, "mon-1", false},
+		{"monitor id wins", HeartbeatPayload{MonitorID: "mon-9", Alias: 1, Status: "up"}, "mon-9", false},
+		{"unknown alias", HeartbeatPayload{Alias: 2, Status: "up"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hb.ResolveAlias(table)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveAlias() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.hb.MonitorID != tt.want {
+				t.Errorf("MonitorID = %q, want %q", tt.hb.MonitorID, tt.want)
+			}
+		})
+	}
+}
+
+func TestAliasedHeartbeatMessage(t *testing.T) {
+	m := NewAliasedHeartbeatMessage(3, "up", 8, "")
+	if err := m.Validate(); err != nil {
+		t.Fatalf("aliased heartbeat rejected: %v", err)
+	}
+	if strings.Contains(string(m.Payload), "monitor_id") {
+		t.Errorf("aliased heartbeat still carries monitor_id: %s", m.Payload)
+	}
+	var hb HeartbeatPayload
+	if err := json.Unmarshal(m.Payload, &hb); err != nil {
+		t.Fatal(err)
+	}
+	if hb.Alias != 3 {
+		t.Errorf("Alias = %d, want 3", hb.Alias)
+	}
+	if err := NewAliasedHeartbeatMessage(0, "up", 8, "").Validate(); err == nil {
+		t.Error("heartbeat without monitor_id or alias accepted")
+	}
+}
+
+func TestResolveAliases(t *testing.T) {
+	resolve := ResolveAliases(map[int]string{3: "mon-3"})
+	tests := []struct {
+		name    string
+		msg     *Message
+		want    string // monitor_id after the transform
+		same    bool   // message passed through untouched
+		wantErr bool
+	}{
+		{"alias resolved", NewAliasedHeartbeatMessage(3, "up", 8, ""), "mon-3", false, false},
+		{"monitor id kept", NewHeartbeatMessage("mon-1", "up", 8, ""), "mon-1", true, false},
+		{"unknown alias", NewAliasedHeartbeatMessage(9, "up", 8, ""), "", false, true},
+		{"other type", NewTaskMessage("mon-1", "http", "https://example.com", 30, 5), "mon-1", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolve(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("transform error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (got == tt.msg) != tt.same {
+				t.Errorf("message passed through = %v, want %v", got == tt.msg, tt.same)
+			}
+			var p struct {
+				MonitorID string `json:"monitor_id"`
+			}
+			json.Unmarshal(got.Payload, &p)
+			if p.MonitorID != tt.want {
+				t.Errorf("monitor_id = %q, want %q", p.MonitorID, tt.want)
+			}
+		})
+	}
+	original := NewAliasedHeartbeatMessage(3, "up", 8, "")
+	payload := string(original.Payload)
+	resolve(original)
+	if string(original.Payload) != payload {
+		t.Error("ResolveAliases modified the message passed in")
+	}
+}
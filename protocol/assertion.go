@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Assertion is the outcome of one condition a check evaluated, such as the
+// status code or a body match, so a failed check shows exactly what failed.
+type Assertion struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// Validate reports whether the assertion is named.
+func (a Assertion) Validate() error {
+	if a.Name == "" {
+		return errors.New("assertion: name is required")
+	}
+	return nil
+}
+
+// StatusFromAssertions derives a check's status from its assertions: up when
+// every assertion passed, down otherwise. A check with no assertions is up.
+func StatusFromAssertions(assertions []Assertion) MonitorStatus {
+	for _, a := range assertions {
+		if !a.Passed {
+			return StatusDown
+		}
+	}
+	return StatusUp
+}
+
+// FailedAssertions returns the assertions that did not pass.
+func FailedAssertions(assertions []Assertion) []Assertion {
+	var failed []Assertion
+	for _, a := range assertions {
+		if !a.Passed {
+			failed = append(failed, a)
+		}
+	}
+	return failed
+}
+
+func validateAssertions(assertions []Assertion) error {
+	for i, a := range assertions {
+		if err := a.Validate(); err != nil {
+			return fmt.Errorf("assertions[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
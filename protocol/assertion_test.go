@@ -0,0 +1,47 @@
+package protocol
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStatusFromAssertions(t *testing.T) {
+	status := Assertion{Name: "status_code", Passed: true, Expected: "200", Actual: "200"}
+	body := Assertion{Name: "body_contains", Passed: false, Expected: "ok", Actual: "maintenance"}
+	latency := Assertion{Name: "latency_under", Passed: false, Expected: "500ms", Actual: "900ms"}
+	tests := []struct {
+		name       string
+		assertions []Assertion
+		wantStatus MonitorStatus
+		wantFailed []Assertion
+	}{
+		{"none", nil, StatusUp, nil},
+		{"all passed", []Assertion{status}, StatusUp, nil},
+		{"one failed", []Assertion{status, body}, StatusDown, []Assertion{body}},
+		{"several failed", []Assertion{latency, status, body}, StatusDown, []Assertion{latency, body}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusFromAssertions(tt.assertions); got != tt.wantStatus {
+				t.Errorf("StatusFromAssertions() = %q, want %q", got, tt.wantStatus)
+			}
+			if got := FailedAssertions(tt.assertions); !slices.Equal(got, tt.wantFailed) {
+				t.Errorf("FailedAssertions() = %v, want %v", got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestHeartbeatAssertionsValidate(t *testing.T) {
+	hb := HeartbeatPayload{MonitorID: "m", Status: "down", Assertions: []Assertion{
+		{Name: "status_code", Passed: true},
+		{Passed: false, Expected: "ok"},
+	}}
+	if err := hb.Validate(); err == nil {
+		t.Error("Validate() accepted an unnamed assertion")
+	}
+	hb.Assertions[1].Name = "body_contains"
+	if err := hb.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
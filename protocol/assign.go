@@ -0,0 +1,47 @@
+package protocol
+
+import "hash/fnv"
+
+// AssignMonitor picks the agent that should run a monitor using rendezvous
+// (highest random weight) hashing: every agent gets a pseudo-random score for
+// the monitor and the highest score wins. Adding or removing an agent only
+// moves the monitors that agent wins or loses, about 1/N of them. The result
+// depends only on the set of agents, not their order. It returns "" when
+// there are no agents.
+func AssignMonitor(monitorID string, agentIDs []string) string {
+	var best string
+	var bestScore uint64
+	for _, agentID := range agentIDs {
+		score := rendezvousScore(monitorID, agentID)
+		if best == "" || score > bestScore || score == bestScore && agentID < best {
+			best, bestScore = agentID, score
+		}
+	}
+	return best
+}
+
+// OwnsMonitor reports whether agentID is the agent AssignMonitor picks for the
+// monitor, letting agents that know the fleet membership self-select.
+func OwnsMonitor(agentID, monitorID string, agentIDs []string) bool {
+	return agentID != "" && AssignMonitor(monitorID, agentIDs) == agentID
+}
+
+func rendezvousScore(monitorID, agentID string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(agentID))
+	h.Write([]byte{0})
+	h.Write([]byte(monitorID))
+	return mix64(h.Sum64())
+}
+
+// mix64 is the splitmix64 finalizer. FNV alone clusters for keys that share
+// long prefixes, such as UUIDs from the same generator, which skews the
+// distribution across agents.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
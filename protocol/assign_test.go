@@ -0,0 +1,79 @@
+package protocol
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestAssignMonitor(t *testing.T) {
+	agents := []string{"agent-a", "agent-b", "agent-c", "agent-d"}
+
+	if got := AssignMonitor("mon-1", nil); got != "" {
+		t.Errorf("AssignMonitor with no agents = %q, want empty", got)
+	}
+	if got := AssignMonitor("mon-1", []string{"solo"}); got != "solo" {
+		t.Errorf("AssignMonitor with one agent = %q, want solo", got)
+	}
+
+	reversed := slices.Clone(agents)
+	slices.Reverse(reversed)
+	for i := range 100 {
+		id := fmt.Sprintf("mon-%d", i)
+		if a, b := AssignMonitor(id, agents), AssignMonitor(id, reversed); a != b {
+			t.Fatalf("%s: assignment depends on agent order: %s vs %s", id, a, b)
+		}
+	}
+}
+
+func TestAssignMonitorDistribution(t *testing.T) {
+	agents := []string{"agent-a", "agent-b", "agent-c", "agent-d"}
+	const monitors = 4000
+	counts := make(map[string]int)
+	for i := range monitors {
+		counts[AssignMonitor(fmt.Sprintf("7f3c2a10-0000-4000-8000-%012d", i), agents)]++
+	}
+	for _, a := range agents {
+		// Each agent should get close to a quarter; allow generous slack.
+		if c := counts[a]; c < monitors/4*7/10 || c > monitors/4*13/10 {
+			t.Errorf("%s got %d of %d monitors", a, c, monitors)
+		}
+	}
+}
+
+func TestAssignMonitorMinimalMovement(t *testing.T) {
+	agents := []string{"agent-a", "agent-b", "agent-c", "agent-d"}
+	grown := append(slices.Clone(agents), "agent-e")
+	const monitors = 2000
+	var moved int
+	for i := range monitors {
+		id := fmt.Sprintf("mon-%d", i)
+		before, after := AssignMonitor(id, agents), AssignMonitor(id, grown)
+		if before != after {
+			if after != "agent-e" {
+				t.Fatalf("%s moved from %s to %s, not to the new agent", id, before, after)
+			}
+			moved++
+		}
+	}
+	// About 1/5 of monitors should move to the new agent.
+	if moved < monitors/10 || moved > monitors*3/10 {
+		t.Errorf("%d of %d monitors moved, want about %d", moved, monitors, monitors/5)
+	}
+}
+
+func TestOwnsMonitor(t *testing.T) {
+	agents := []string{"agent-a", "agent-b", "agent-c"}
+	var owners int
+	for _, a := range agents {
+		if OwnsMonitor(a, "mon-1", agents) {
+			owners++
+		}
+	}
+	if owners != 1 {
+		t.Errorf("%d agents own mon-1, want exactly 1", owners)
+	}
+	if OwnsMonitor("", "mon-1", nil) {
+		t.Error("empty agent owns a monitor with no fleet")
+	}
+}
@@ -0,0 +1,208 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HistoryEncodingDeflate is the HistoryBatchPayload encoding produced by
+// BackfillEncoder: grouped, delta-timestamped JSON compressed with flate
+// against the preset dictionary.
+const HistoryEncodingDeflate = "deflate"
+
+// MaxHistoryBatchCount is the most heartbeats one history batch may carry.
+const MaxHistoryBatchCount = 100000
+
+// HistoryBatchPayload is sent by agent after reconnecting with the heartbeats
+// it buffered while offline, encoded as one compressed blob. Count is the
+// number of heartbeats in Data, at most MaxHistoryBatchCount.
+type HistoryBatchPayload struct {
+	Encoding          string `json:"encoding"`
+	DictionaryVersion int    `json:"dictionary_version,omitempty"`
+	Count             int    `json:"count"`
+	Data              []byte `json:"data"`
+}
+
+// Validate reports whether the batch uses a known encoding and dictionary.
+func (p HistoryBatchPayload) Validate() error {
+	if p.Encoding != HistoryEncodingDeflate {
+		return fmt.Errorf("history_batch: unknown encoding %q", p.Encoding)
+	}
+	if _, err := dictionary(p.DictionaryVersion); err != nil {
+		return fmt.Errorf("history_batch: %w", err)
+	}
+	if p.Count < 0 || p.Count > MaxHistoryBatchCount {
+		return fmt.Errorf("history_batch: count must be between 0 and %d, got %d", MaxHistoryBatchCount, p.Count)
+	}
+	if len(p.Data) == 0 {
+		return errors.New("history_batch: data is required")
+	}
+	return nil
+}
+
+// HistoryEntry is one buffered heartbeat and when its check ran.
+type HistoryEntry struct {
+	At        time.Time
+	Heartbeat HeartbeatPayload
+}
+
+// historyGroup is the encoded history of one monitor. Times are stored as the
+// first check time in Unix milliseconds followed by the gap to each next
+// check, which stays small for periodic checks. The heartbeats omit their
+// monitor ID since the group carries it. Heartbeats that carry only an alias
+// are grouped by alias under an empty monitor ID and keep their alias.
+type historyGroup struct {
+	MonitorID  string             `json:"m"`
+	Base       int64              `json:"b"`
+	Deltas     []int64            `json:"d"`
+	Heartbeats []HeartbeatPayload `json:"h"`
+}
+
+// BackfillEncoder collects heartbeats buffered while the agent was offline
+// and encodes them as a single history batch. It is safe for concurrent use.
+type BackfillEncoder struct {
+	mu      sync.Mutex
+	entries map[string][]HistoryEntry
+	count   int
+	size    int
+	budget  *ConnectionBudget
+}
+
+// NewBackfillEncoder creates an empty encoder.
+func NewBackfillEncoder() *BackfillEncoder {
+	return &BackfillEncoder{entries: make(map[string][]HistoryEntry)}
+}
+
+// SetBudget makes the encoder charge buffered heartbeats against b,
+// releasing them once they are encoded. Call it before the first Add.
+func (e *BackfillEncoder) SetBudget(b *ConnectionBudget) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.budget = b
+}
+
+// Add buffers a heartbeat taken at the given time. It fails without buffering
+// the heartbeat if the budget cannot hold it or the encoder already holds
+// MaxHistoryBatchCount heartbeats.
+func (e *BackfillEncoder) Add(at time.Time, hb HeartbeatPayload) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.count >= MaxHistoryBatchCount {
+		return fmt.Errorf("history_batch: encoder is full at %d heartbeats", MaxHistoryBatchCount)
+	}
+	size := heartbeatSize(hb)
+	if err := e.budget.Charge(size); err != nil {
+		return fmt.Errorf("history_batch: %w", err)
+	}
+	key := hb.monitorKey()
+	e.entries[key] = append(e.entries[key], HistoryEntry{At: at, Heartbeat: hb})
+	e.count++
+	e.size += size
+	return nil
+}
+
+// Len returns the number of buffered heartbeats.
+func (e *BackfillEncoder) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.count
+}
+
+// Encode builds a history batch message from everything buffered and empties
+// the encoder. Heartbeats are grouped by monitor and ordered by time within
+// each group.
+func (e *BackfillEncoder) Encode() (*Message, error) {
+	e.mu.Lock()
+	entries, count := e.entries, e.count
+	e.entries, e.count = make(map[string][]HistoryEntry), 0
+	e.budget.Release(e.size)
+	e.size = 0
+	e.mu.Unlock()
+
+	monitors := make([]string, 0, len(entries))
+	for id := range entries {
+		monitors = append(monitors, id)
+	}
+	sort.Strings(monitors)
+
+	groups := make([]historyGroup, 0, len(monitors))
+	for _, id := range monitors {
+		list := entries[id]
+		slices.SortStableFunc(list, func(a, b HistoryEntry) int { return a.At.Compare(b.At) })
+
+		g := historyGroup{
+			MonitorID:  list[0].Heartbeat.MonitorID,
+			Base:       list[0].At.UnixMilli(),
+			Deltas:     make([]int64, len(list)),
+			Heartbeats: make([]HeartbeatPayload, len(list)),
+		}
+		prev := g.Base
+		for i, entry := range list {
+			ms := entry.At.UnixMilli()
+			g.Deltas[i] = ms - prev
+			prev = ms
+			hb := entry.Heartbeat
+			hb.MonitorID = ""
+			g.Heartbeats[i] = hb
+		}
+		groups = append(groups, g)
+	}
+
+	raw, err := json.Marshal(groups)
+	if err != nil {
+		return nil, fmt.Errorf("history_batch: %w", err)
+	}
+	data, err := CompressWithDictionary(raw, DictionaryVersion)
+	if err != nil {
+		return nil, fmt.Errorf("history_batch: %w", err)
+	}
+	return NewMessage(MsgTypeHistoryBatch, HistoryBatchPayload{
+		Encoding:          HistoryEncodingDeflate,
+		DictionaryVersion: DictionaryVersion,
+		Count:             count,
+		Data:              data,
+	})
+}
+
+// DecodeHistoryBatch reconstructs the heartbeats of a history batch, grouped
+// by monitor and in time order within each monitor. Times are restored to
+// millisecond precision. A batch whose Count does not match the heartbeats in
+// Data is rejected.
+func DecodeHistoryBatch(p HistoryBatchPayload) ([]HistoryEntry, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	raw, err := DecompressWithDictionary(p.Data, p.DictionaryVersion)
+	if err != nil {
+		return nil, fmt.Errorf("history_batch: %w", err)
+	}
+	var groups []historyGroup
+	if err := json.Unmarshal(raw, &groups); err != nil {
+		return nil, fmt.Errorf("history_batch: %w", err)
+	}
+
+	// Count comes from the peer, so it only sizes the result once Validate
+	// has bounded it, and it must still match what Data holds.
+	entries := make([]HistoryEntry, 0, p.Count)
+	for _, g := range groups {
+		if len(g.Deltas) != len(g.Heartbeats) {
+			return nil, fmt.Errorf("history_batch: monitor %q has %d times for %d heartbeats", g.MonitorID, len(g.Deltas), len(g.Heartbeats))
+		}
+		ms := g.Base
+		for i, hb := range g.Heartbeats {
+			ms += g.Deltas[i]
+			hb.MonitorID = g.MonitorID
+			entries = append(entries, HistoryEntry{At: time.UnixMilli(ms), Heartbeat: hb})
+		}
+	}
+	if len(entries) != p.Count {
+		return nil, fmt.Errorf("history_batch: count is %d but data holds %d heartbeats", p.Count, len(entries))
+	}
+	return entries, nil
+}
@@ -0,0 +1,225 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestBackfillRoundTrip(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewBackfillEncoder()
+	// Added out of order and interleaved across monitors.
+	adds := []HistoryEntry{
+		{start.Add(30 * time.Second), HeartbeatPayload{MonitorID: "b", Status: "up", LatencyMs: 12}},
+		{start.Add(60 * time.Second), HeartbeatPayload{MonitorID: "a", Status: "down", ErrorMessage: "refused"}},
+		{start, HeartbeatPayload{MonitorID: "a", Status: "up", LatencyMs: 20}},
+		{start.Add(1500 * time.Microsecond), HeartbeatPayload{MonitorID: "b", Status: "up", LatencyMs: 9}},
+	}
+	for _, entry := range adds {
+		if err := e.Add(entry.At, entry.Heartbeat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e.Len() != len(adds) {
+		t.Errorf("Len() = %d, want %d", e.Len(), len(adds))
+	}
+
+	m, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Len() != 0 {
+		t.Errorf("Len() after Encode = %d, want 0", e.Len())
+	}
+	var p HistoryBatchPayload
+	if err := m.ParsePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeHistoryBatch(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []HistoryEntry{adds[2], adds[1], adds[3], adds[0]}
+	if len(got) != len(want) {
+		t.Fatalf("decoded %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].At.Equal(want[i].At.Truncate(time.Millisecond)) || got[i].Heartbeat.MonitorID != want[i].Heartbeat.MonitorID ||
+			got[i].Heartbeat.Status != want[i].Heartbeat.Status || got[i].Heartbeat.LatencyMs != want[i].Heartbeat.LatencyMs {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBackfillAliases(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewBackfillEncoder()
+	adds := []HistoryEntry{
+		{start.Add(time.Second), HeartbeatPayload{Alias: 1, Status: "down"}},
+		{start, HeartbeatPayload{Alias: 2, Status: "up"}},
+		{start, HeartbeatPayload{Alias: 1, Status: "up"}},
+		{start, HeartbeatPayload{MonitorID: "a", Status: "up"}},
+	}
+	for _, entry := range adds {
+		if err := e.Add(entry.At, entry.Heartbeat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p HistoryBatchPayload
+	if err := m.ParsePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeHistoryBatch(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []HistoryEntry{adds[3], adds[2], adds[0], adds[1]}
+	if len(got) != len(want) {
+		t.Fatalf("decoded %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].At.Equal(want[i].At) || got[i].Heartbeat.MonitorID != want[i].Heartbeat.MonitorID ||
+			got[i].Heartbeat.Alias != want[i].Heartbeat.Alias || got[i].Heartbeat.Status != want[i].Heartbeat.Status {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecodeHistoryBatchRejects(t *testing.T) {
+	encode := func(groups []historyGroup) []byte {
+		raw, _ := json.Marshal(groups)
+		data, err := CompressWithDictionary(raw, DictionaryVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	one := encode([]historyGroup{{MonitorID: "a", Deltas: []int64{0}, Heartbeats: []HeartbeatPayload{{Status: "up"}}}})
+	batch := func(count int, data []byte) HistoryBatchPayload {
+		return HistoryBatchPayload{Encoding: HistoryEncodingDeflate, DictionaryVersion: DictionaryVersion, Count: count, Data: data}
+	}
+
+	tests := []struct {
+		name string
+		p    HistoryBatchPayload
+	}{
+		{"unknown encoding", HistoryBatchPayload{Encoding: "zstd", Count: 1, Data: one}},
+		{"unknown dictionary", HistoryBatchPayload{Encoding: HistoryEncodingDeflate, DictionaryVersion: 99, Count: 1, Data: one}},
+		{"no data", batch(0, nil)},
+		{"negative count", batch(-1, one)},
+		{"huge count", batch(1<<50, one)},
+		{"count too high", batch(2, one)},
+		{"count too low", batch(0, one)},
+		{"not deflate", batch(1, []byte("not compressed"))},
+		{"not json", batch(1, encode(nil)[:1])},
+		{"times and heartbeats differ", batch(1, encode([]historyGroup{{MonitorID: "a", Deltas: []int64{0, 1}, Heartbeats: []HeartbeatPayload{{}}}}))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if entries, err := DecodeHistoryBatch(tt.p); err == nil {
+				t.Errorf("DecodeHistoryBatch() = %d entries, want an error", len(entries))
+			}
+		})
+	}
+	if entries, err := DecodeHistoryBatch(batch(1, one)); err != nil || len(entries) != 1 {
+		t.Errorf("DecodeHistoryBatch(valid) = %d entries, %v", len(entries), err)
+	}
+}
+
+func TestBackfillEncoderFull(t *testing.T) {
+	e := NewBackfillEncoder()
+	e.count = MaxHistoryBatchCount
+	if err := e.Add(time.Now(), HeartbeatPayload{MonitorID: "a", Status: "up"}); err == nil {
+		t.Error("Add() accepted a heartbeat past MaxHistoryBatchCount")
+	}
+}
+
+func TestDecompressWithDictionaryLimit(t *testing.T) {
+	compress := func(n int) []byte {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriterDict(&buf, flate.BestSpeed, DefaultDictionary)
+		w.Write(bytes.Repeat([]byte("a"), n))
+		w.Close()
+		return buf.Bytes()
+	}
+	if _, err := DecompressWithDictionary(compress(MaxDecompressedSize+1), DictionaryVersion); !errors.Is(err, ErrDecompressedTooLarge) {
+		t.Errorf("DecompressWithDictionary(bomb) = %v, want ErrDecompressedTooLarge", err)
+	}
+	if out, err := DecompressWithDictionary(compress(MaxDecompressedSize), DictionaryVersion); err != nil || len(out) != MaxDecompressedSize {
+		t.Errorf("DecompressWithDictionary(limit) = %d bytes, %v", len(out), err)
+	}
+}
+
+// BenchmarkBackfill encodes and decodes an hour of heartbeats from 50
+// monitors, as an agent buffers them while offline.
+func BenchmarkBackfill(b *testing.B) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rng := rand.New(rand.NewPCG(1, 2))
+	var entries []HistoryEntry
+	for i := range 6000 {
+		hb := HeartbeatPayload{
+			MonitorID: fmt.Sprintf("3f6c2a9e-%04d-4f1e-9c1d-7a0b5e2c8d41", i%50),
+			Status:    "up",
+			LatencyMs: 20 + rng.IntN(230),
+		}
+		if rng.IntN(100) == 0 {
+			hb.Status, hb.LatencyMs, hb.ErrorMessage = "down", 0, "dial tcp 10.0.0.7:443: connect: connection refused"
+		}
+		entries = append(entries, HistoryEntry{
+			At:        start.Add(time.Duration(i/50)*30*time.Second + time.Duration(rng.IntN(400))*time.Millisecond),
+			Heartbeat: hb,
+		})
+	}
+	var jsonSize int
+	for _, entry := range entries {
+		data, _ := json.Marshal(entry.Heartbeat)
+		jsonSize += len(data)
+	}
+
+	encode := func(b *testing.B) *Message {
+		e := NewBackfillEncoder()
+		for _, entry := range entries {
+			if err := e.Add(entry.At, entry.Heartbeat); err != nil {
+				b.Fatal(err)
+			}
+		}
+		m, err := e.Encode()
+		if err != nil {
+			b.Fatal(err)
+		}
+		return m
+	}
+
+	b.Run("encode", func(b *testing.B) {
+		var m *Message
+		for b.Loop() {
+			m = encode(b)
+		}
+		b.ReportMetric(float64(len(m.Payload))/float64(len(entries)), "bytes/heartbeat")
+		b.ReportMetric(float64(jsonSize)/float64(len(entries)), "json-bytes/heartbeat")
+	})
+
+	b.Run("decode", func(b *testing.B) {
+		var p HistoryBatchPayload
+		if err := encode(b).ParsePayload(&p); err != nil {
+			b.Fatal(err)
+		}
+		for b.Loop() {
+			if _, err := DecodeHistoryBatch(p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
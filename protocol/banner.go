@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxTCPProbeBytes caps the data a TCP task may send and expect.
+const MaxTCPProbeBytes = 1024
+
+// NewTCPBannerTaskMessage creates a TCP task that optionally sends sendData
+// after connecting and expects the response to contain expectData. Either may
+// be empty: with no sendData the agent just reads the greeting banner, as for
+// SMTP, and with no expectData any response counts as up.
+func NewTCPBannerTaskMessage(monitorID, target string, interval, timeout int, sendData, expectData string) *Message {
+	return MustNewMessage(MsgTypeTask, TaskPayload{
+		MonitorID:  monitorID,
+		Type:       string(MonitorTCP),
+		Target:     target,
+		Interval:   interval,
+		Timeout:    timeout,
+		SendData:   sendData,
+		ExpectData: expectData,
+	})
+}
+
+// MatchBanner reports whether the banner received from a TCP service
+// satisfies the task's ExpectData. An empty expectation matches anything,
+// including no banner at all.
+func MatchBanner(banner, expectData string) bool {
+	return expectData == "" || strings.Contains(banner, expectData)
+}
+
+func (t TaskPayload) validateBanner() error {
+	if t.SendData == "" && t.ExpectData == "" {
+		return nil
+	}
+	if t.Type != string(MonitorTCP) {
+		return fmt.Errorf("task: send_data and expect_data only apply to tcp monitors, not %q", t.Type)
+	}
+	if len(t.SendData) > MaxTCPProbeBytes {
+		return fmt.Errorf("task: send_data is %d bytes, limit is %d", len(t.SendData), MaxTCPProbeBytes)
+	}
+	if len(t.ExpectData) > MaxTCPProbeBytes {
+		return fmt.Errorf("task: expect_data is %d bytes, limit is %d", len(t.ExpectData), MaxTCPProbeBytes)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchBanner(t *testing.T) {
+	tests := []struct {
+		banner, expect string
+		want           bool
+	}{
+		{"220 mail.example.com ESMTP Postfix\r\n", "ESMTP", true},
+		{"220 mail.example.com ESMTP Postfix\r\n", "IMAP", false},
+		{"", "", true},
+		{"anything", "", true},
+		{"", "+PONG", false},
+		{"+PONG\r\n", "+PONG", true},
+	}
+	for _, tt := range tests {
+		if got := MatchBanner(tt.banner, tt.expect); got != tt.want {
+			t.Errorf("MatchBanner(%q, %q) = %v, want %v", tt.banner, tt.expect, got, tt.want)
+		}
+	}
+}
+
+func TestTCPBannerTaskValidate(t *testing.T) {
+	long := strings.Repeat("x", MaxTCPProbeBytes+1)
+	tests := []struct {
+		name    string
+		task    TaskPayload
+		wantErr bool
+	}{
+		{"plain tcp", TaskPayload{MonitorID: "m", Type: "tcp", Target: "db:5432"}, false},
+		{"send and expect", TaskPayload{MonitorID: "m", Type: "tcp", SendData: "PING\r\n", ExpectData: "+PONG"}, false},
+		{"expect only", TaskPayload{MonitorID: "m", Type: "tcp", ExpectData: "220"}, false},
+		{"non-tcp", TaskPayload{MonitorID: "m", Type: "http", ExpectData: "ok"}, true},
+		{"send too long", TaskPayload{MonitorID: "m", Type: "tcp", SendData: long}, true},
+		{"expect too long", TaskPayload{MonitorID: "m", Type: "tcp", ExpectData: long}, true},
+		{"at limit", TaskPayload{MonitorID: "m", Type: "tcp", SendData: long[1:]}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.task.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTCPBannerTaskMessage(t *testing.T) {
+	m := NewTCPBannerTaskMessage("mon-1", "mail:25", 60, 5, "", "ESMTP")
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var task TaskPayload
+	if err := m.ParsePayload(&task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Type != string(MonitorTCP) || task.ExpectData != "ESMTP" || task.SendData != "" {
+		t.Errorf("task = %+v", task)
+	}
+}
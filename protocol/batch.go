@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HeartbeatBatchPayload is sent by agent with several check results at once.
+type HeartbeatBatchPayload struct {
+	Heartbeats []HeartbeatPayload `json:"heartbeats"`
+}
+
+// Validate reports whether the batch and every heartbeat in it are well
+// formed.
+func (b HeartbeatBatchPayload) Validate() error {
+	if len(b.Heartbeats) == 0 {
+		return errors.New("heartbeat_batch: heartbeats must not be empty")
+	}
+	for i, hb := range b.Heartbeats {
+		if err := hb.Validate(); err != nil {
+			return fmt.Errorf("heartbeat_batch: heartbeats[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// NewHeartbeatBatchMessage creates a heartbeat batch message.
+func NewHeartbeatBatchMessage(heartbeats []HeartbeatPayload) *Message {
+	return MustNewMessage(MsgTypeHeartbeatBatch, HeartbeatBatchPayload{
+		Heartbeats: heartbeats,
+	})
+}
+
+// BatchSummary aggregates a heartbeat batch.
+type BatchSummary struct {
+	Total        int
+	ByStatus     map[string]int
+	ErrorCount   int
+	MinLatencyMs int
+	MaxLatencyMs int
+	AvgLatencyMs float64
+}
+
+// SummarizeBatch counts heartbeats by status and computes latency statistics
+// across the batch. ErrorCount is the number of heartbeats carrying an error
+// message. An empty batch yields a zero summary with an empty ByStatus map.
+func SummarizeBatch(b HeartbeatBatchPayload) BatchSummary {
+	s := BatchSummary{
+		Total:    len(b.Heartbeats),
+		ByStatus: make(map[string]int),
+	}
+	if s.Total == 0 {
+		return s
+	}
+
+	s.MinLatencyMs = b.Heartbeats[0].LatencyMs
+	var sum int
+	for _, hb := range b.Heartbeats {
+		s.ByStatus[hb.Status]++
+		if hb.ErrorMessage != "" {
+			s.ErrorCount++
+		}
+		s.MinLatencyMs = min(s.MinLatencyMs, hb.LatencyMs)
+		s.MaxLatencyMs = max(s.MaxLatencyMs, hb.LatencyMs)
+		sum += hb.LatencyMs
+	}
+	s.AvgLatencyMs = float64(sum) / float64(s.Total)
+	return s
+}
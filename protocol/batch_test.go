@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestHeartbeatBatchValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		batch   HeartbeatBatchPayload
+		wantErr bool
+	}{
+		{"empty", HeartbeatBatchPayload{}, true},
+		{"valid", HeartbeatBatchPayload{Heartbeats: []HeartbeatPayload{{MonitorID: "a", Status: "up"}}}, false},
+		{"invalid entry", HeartbeatBatchPayload{Heartbeats: []HeartbeatPayload{{MonitorID: "a", Status: "up"}, {MonitorID: "b"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.batch.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSummarizeBatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch HeartbeatBatchPayload
+		want  BatchSummary
+	}{
+		{
+			name:  "empty",
+			batch: HeartbeatBatchPayload{},
+			want:  BatchSummary{ByStatus: map[string]int{}},
+		},
+		{
+			name: "single",
+			batch: HeartbeatBatchPayload{Heartbeats: []HeartbeatPayload{
+				{MonitorID: "a", Status: "up", LatencyMs: 40},
+			}},
+			want: BatchSummary{Total: 1, ByStatus: map[string]int{"up": 1}, MinLatencyMs: 40, MaxLatencyMs: 40, AvgLatencyMs: 40},
+		},
+		{
+			name: "mixed",
+			batch: HeartbeatBatchPayload{Heartbeats: []HeartbeatPayload{
+				{MonitorID: "a", Status: "up", LatencyMs: 10},
+				{MonitorID: "b", Status: "down", LatencyMs: 0, ErrorMessage: "refused"},
+				{MonitorID: "c", Status: "up", LatencyMs: 50},
+				{MonitorID: "d", Status: "down", LatencyMs: 20, ErrorMessage: "timeout"},
+			}},
+			want: BatchSummary{Total: 4, ByStatus: map[string]int{"up": 2, "down": 2}, ErrorCount: 2, MinLatencyMs: 0, MaxLatencyMs: 50, AvgLatencyMs: 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SummarizeBatch(tt.batch)
+			if !maps.Equal(got.ByStatus, tt.want.ByStatus) {
+				t.Errorf("ByStatus = %v, want %v", got.ByStatus, tt.want.ByStatus)
+			}
+			if got.Total != tt.want.Total || got.ErrorCount != tt.want.ErrorCount ||
+				got.MinLatencyMs != tt.want.MinLatencyMs || got.MaxLatencyMs != tt.want.MaxLatencyMs ||
+				got.AvgLatencyMs != tt.want.AvgLatencyMs {
+				t.Errorf("SummarizeBatch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package protocol
+
+// boundedSet remembers up to capacity keys, forgetting the oldest first. It
+// keeps dedup state from growing without bound on long-lived connections.
+type boundedSet[K comparable] struct {
+	keys  map[K]struct{}
+	order []K
+	next  int
+}
+
+func newBoundedSet[K comparable](capacity int) *boundedSet[K] {
+	capacity = max(capacity, 1)
+	return &boundedSet[K]{
+		keys:  make(map[K]struct{}, capacity),
+		order: make([]K, 0, capacity),
+	}
+}
+
+func (s *boundedSet[K]) has(k K) bool {
+	_, ok := s.keys[k]
+	return ok
+}
+
+// add records k and reports whether it was new.
+func (s *boundedSet[K]) add(k K) bool {
+	if s.has(k) {
+		return false
+	}
+	s.insert(k)
+	return true
+}
+
+// insert records k, which must not be in the set yet, and returns the key it
+// forgot to make room, if any.
+func (s *boundedSet[K]) insert(k K) (evicted K, ok bool) {
+	if len(s.order) < cap(s.order) {
+		s.order = append(s.order, k)
+	} else {
+		evicted, ok = s.order[s.next], true
+		delete(s.keys, evicted)
+		s.order[s.next] = k
+		s.next = (s.next + 1) % len(s.order)
+	}
+	s.keys[k] = struct{}{}
+	return evicted, ok
+}
@@ -0,0 +1,38 @@
+package protocol
+
+import "testing"
+
+func TestBoundedSet(t *testing.T) {
+	s := newBoundedSet[int](3)
+	for _, k := range []int{1, 2, 3} {
+		if !s.add(k) {
+			t.Fatalf("add(%d) reported a duplicate", k)
+		}
+	}
+	if s.add(2) {
+		t.Error("add(2) reported a repeat as new")
+	}
+
+	evicted, ok := s.insert(4)
+	if !ok || evicted != 1 {
+		t.Errorf("insert(4) evicted %d, %v, want 1, true", evicted, ok)
+	}
+	if s.has(1) || !s.has(4) {
+		t.Error("oldest key not replaced by the newest")
+	}
+	s.add(5)
+	s.add(6)
+	for k, want := range map[int]bool{2: false, 3: false, 4: true, 5: true, 6: true} {
+		if s.has(k) != want {
+			t.Errorf("has(%d) = %v, want %v", k, !want, want)
+		}
+	}
+}
+
+func TestBoundedSetMinimumCapacity(t *testing.T) {
+	s := newBoundedSet[string](0)
+	s.add("a")
+	if _, ok := s.insert("b"); !ok || s.has("a") || !s.has("b") {
+		t.Error("zero capacity did not behave as one")
+	}
+}
@@ -0,0 +1,40 @@
+package protocol
+
+import "sync"
+
+// DefaultBroadcastMemory is how many broadcast IDs a tracker remembers when
+// no capacity is given.
+const DefaultBroadcastMemory = 1024
+
+// BroadcastTracker remembers which broadcasts an agent has already processed,
+// so a broadcast that arrives more than once, e.g. through several relays, is
+// handled once. It is safe for concurrent use.
+type BroadcastTracker struct {
+	mu   sync.Mutex
+	seen *boundedSet[string]
+}
+
+// NewBroadcastTracker creates a tracker that remembers the last capacity
+// broadcast IDs. A non-positive capacity uses DefaultBroadcastMemory.
+func NewBroadcastTracker(capacity int) *BroadcastTracker {
+	if capacity <= 0 {
+		capacity = DefaultBroadcastMemory
+	}
+	return &BroadcastTracker{seen: newBoundedSet[string](capacity)}
+}
+
+// Seen records id and reports whether it had already been recorded. An empty
+// ID is never considered seen, since the message was not a broadcast.
+func (t *BroadcastTracker) Seen(id string) bool {
+	if id == "" {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.seen.add(id)
+}
+
+// SeenMessage reports whether m is a broadcast that was already processed.
+func (t *BroadcastTracker) SeenMessage(m *Message) bool {
+	return t.Seen(m.BroadcastID)
+}
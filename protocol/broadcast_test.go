@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBroadcastTracker(t *testing.T) {
+	tr := NewBroadcastTracker(2)
+	if tr.Seen("b1") {
+		t.Error("first delivery reported as seen")
+	}
+	if !tr.Seen("b1") {
+		t.Error("second delivery not reported as seen")
+	}
+	if tr.Seen("") || tr.Seen("") {
+		t.Error("empty broadcast ID reported as seen")
+	}
+
+	tr.Seen("b2")
+	tr.Seen("b3")
+	if tr.Seen("b1") {
+		t.Error("tracker remembered more IDs than its capacity")
+	}
+}
+
+func TestBroadcastTrackerDefaultCapacity(t *testing.T) {
+	tr := NewBroadcastTracker(0)
+	for i := range DefaultBroadcastMemory {
+		tr.Seen(fmt.Sprintf("b%d", i))
+	}
+	if !tr.Seen("b0") {
+		t.Errorf("default tracker forgot an ID within %d broadcasts", DefaultBroadcastMemory)
+	}
+}
+
+func TestBroadcastTrackerSeenMessage(t *testing.T) {
+	tr := NewBroadcastTracker(10)
+	m := NewTaskCancelMessage("mon-1")
+	if tr.SeenMessage(m) || tr.SeenMessage(m) {
+		t.Error("non-broadcast message reported as seen")
+	}
+
+	m.BroadcastID = "b1"
+	relayed := *m
+	if tr.SeenMessage(m) {
+		t.Error("first broadcast delivery reported as seen")
+	}
+	if !tr.SeenMessage(&relayed) {
+		t.Error("relayed copy of the broadcast not reported as seen")
+	}
+}
+
+func TestBroadcastTrackerConcurrent(t *testing.T) {
+	tr := NewBroadcastTracker(100)
+	var first atomic.Int32
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Go(func() {
+			if !tr.Seen("b1") {
+				first.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	if n := first.Load(); n != 1 {
+		t.Errorf("%d deliveries processed the broadcast, want 1", n)
+	}
+}
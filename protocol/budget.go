@@ -0,0 +1,123 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned by ConnectionBudget.Charge when an allocation
+// would take a connection over its memory cap.
+var ErrBudgetExceeded = errors.New("connection memory budget exceeded")
+
+// messageOverhead approximates the memory a decoded message holds beyond its
+// variable-length fields: the struct, its timestamp and slice headers.
+const messageOverhead = 128
+
+// ConnectionBudget tracks the approximate bytes buffered on behalf of one
+// connection, such as decode, retry and history buffers, and refuses
+// allocations that would exceed a cap. It bounds what a single misbehaving
+// peer can make the hub hold. The buffering and decoding helpers in this
+// package charge it once given one with SetBudget, and
+// DecompressWithDictionaryBudget takes one directly. A nil budget is unlimited and tracks
+// nothing. It is safe for concurrent use.
+type ConnectionBudget struct {
+	mu   sync.Mutex
+	cap  int
+	used int
+}
+
+// NewConnectionBudget creates a budget of capBytes. A non-positive cap means
+// unlimited; usage is still tracked.
+func NewConnectionBudget(capBytes int) *ConnectionBudget {
+	return &ConnectionBudget{cap: capBytes}
+}
+
+// Charge reserves bytes against the budget. If that would exceed the cap
+// nothing is reserved and the error wraps ErrBudgetExceeded.
+func (b *ConnectionBudget) Charge(bytes int) error {
+	if bytes < 0 {
+		return fmt.Errorf("connection budget: cannot charge %d bytes", bytes)
+	}
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cap > 0 && b.used+bytes > b.cap {
+		return fmt.Errorf("%w: %d of %d bytes in use, %d requested", ErrBudgetExceeded, b.used, b.cap, bytes)
+	}
+	b.used += bytes
+	return nil
+}
+
+// hold charges bytes even past the cap, for buffering that has already been
+// accepted and cannot be refused, so later charges still see it.
+func (b *ConnectionBudget) hold(bytes int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used += bytes
+}
+
+// Release returns bytes previously charged. Releasing more than is in use
+// leaves the budget empty rather than negative.
+func (b *ConnectionBudget) Release(bytes int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used = max(b.used-bytes, 0)
+}
+
+// Used returns the bytes currently charged, always zero for a nil budget.
+func (b *ConnectionBudget) Used() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Remaining returns the bytes that can still be charged, or -1 when the
+// budget is unlimited, as a nil budget is. Held bytes can take it below zero.
+func (b *ConnectionBudget) Remaining() int {
+	if b == nil {
+		return -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cap <= 0 {
+		return -1
+	}
+	return b.cap - b.used
+}
+
+// MessageSize estimates the memory a buffered message holds, for charging it
+// against a ConnectionBudget.
+func MessageSize(m *Message) int {
+	return messageOverhead + len(m.ID) + len(m.Type) + len(m.Payload) +
+		len(m.TraceParent) + len(m.TraceState) + len(m.PartitionKey) +
+		len(m.PrevDigest) + len(m.BroadcastID)
+}
+
+// heartbeatSize estimates the memory a buffered heartbeat holds.
+func heartbeatSize(hb HeartbeatPayload) int {
+	n := messageOverhead + len(hb.MonitorID) + len(hb.Status) + len(hb.ErrorMessage) +
+		len(hb.CertIssuer) + len(hb.Banner)
+	for k, v := range hb.Metadata {
+		n += len(k) + len(v)
+	}
+	if hb.DownReason != nil {
+		n += len(hb.DownReason.Category) + len(hb.DownReason.Detail) + len(hb.DownReason.RawError)
+	}
+	for _, a := range hb.Assertions {
+		n += len(a.Name) + len(a.Expected) + len(a.Actual)
+	}
+	return n
+}
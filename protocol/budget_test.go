@@ -0,0 +1,227 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnectionBudget(t *testing.T) {
+	b := NewConnectionBudget(100)
+	if err := b.Charge(60); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Charge(50); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Charge over the cap = %v, want ErrBudgetExceeded", err)
+	}
+	if b.Used() != 60 || b.Remaining() != 40 {
+		t.Errorf("failed charge changed usage: used %d, remaining %d", b.Used(), b.Remaining())
+	}
+	if err := b.Charge(40); err != nil {
+		t.Errorf("Charge up to the cap = %v", err)
+	}
+	b.Release(70)
+	if b.Used() != 30 {
+		t.Errorf("Used() = %d, want 30", b.Used())
+	}
+	b.Release(1000)
+	if b.Used() != 0 {
+		t.Errorf("over-release left %d in use, want 0", b.Used())
+	}
+	if err := b.Charge(-1); err == nil {
+		t.Error("Charge accepted a negative size")
+	}
+}
+
+func TestConnectionBudgetUnlimited(t *testing.T) {
+	b := NewConnectionBudget(0)
+	if err := b.Charge(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+	if b.Used() != 1<<40 || b.Remaining() != -1 {
+		t.Errorf("used %d, remaining %d", b.Used(), b.Remaining())
+	}
+
+	var none *ConnectionBudget
+	if err := none.Charge(1 << 40); err != nil {
+		t.Errorf("nil budget Charge = %v", err)
+	}
+	none.Release(10)
+	if none.Used() != 0 || none.Remaining() != -1 {
+		t.Errorf("nil budget used %d, remaining %d", none.Used(), none.Remaining())
+	}
+}
+
+func TestConnectionBudgetConcurrent(t *testing.T) {
+	b := NewConnectionBudget(1000)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	granted := 0
+	for range 50 {
+		wg.Go(func() {
+			if b.Charge(100) == nil {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	if granted != 10 || b.Used() != 1000 {
+		t.Errorf("granted %d charges using %d bytes, want 10 and 1000", granted, b.Used())
+	}
+}
+
+func TestMessageSize(t *testing.T) {
+	small := NewPingMessage()
+	big := NewLogMessage("m", LogLevelInfo, strings.Repeat("x", 1000), 0)
+	if MessageSize(small) < messageOverhead {
+		t.Errorf("MessageSize() = %d, below the fixed overhead", MessageSize(small))
+	}
+	if MessageSize(big)-MessageSize(small) < 1000 {
+		t.Error("MessageSize() does not grow with the payload")
+	}
+}
+
+func TestBudgetedOrderer(t *testing.T) {
+	m := NewPingMessage()
+	b := NewConnectionBudget(2 * MessageSize(m))
+	o := NewPerKeyOrderer()
+	o.SetBudget(b)
+
+	release := make(chan struct{})
+	for range 2 {
+		if err := o.TrySubmit("k", m, func(*Message) { <-release }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := o.TrySubmit("k", m, func(*Message) {}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("TrySubmit over budget = %v, want ErrBudgetExceeded", err)
+	}
+	// Submit cannot refuse, so it queues and charges past the cap.
+	o.Submit("k", m, func(*Message) {})
+	if b.Used() != 3*MessageSize(m) {
+		t.Errorf("Used() = %d after Submit over budget, want %d", b.Used(), 3*MessageSize(m))
+	}
+	close(release)
+	o.Wait()
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after every handler ran", b.Used())
+	}
+}
+
+func TestBudgetedDependencyBuffer(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := NewConnectionBudget(2 * MessageSize(seqMsg(2, 1)))
+	d := NewDependencyBuffer(time.Minute)
+	d.SetBudget(b)
+
+	tryPush := func(m *Message) {
+		t.Helper()
+		if _, err := d.TryPushAt(m, now); err != nil {
+			t.Fatalf("TryPush(seq %d) = %v", m.Seq, err)
+		}
+	}
+
+	// Messages that are ready at once are never held, so cost nothing.
+	tryPush(seqMsg(1, 0))
+	if b.Used() != 0 {
+		t.Errorf("ready message charged %d bytes", b.Used())
+	}
+
+	tryPush(seqMsg(3, 2))
+	tryPush(seqMsg(4, 3))
+	if _, err := d.TryPushAt(seqMsg(5, 4), now); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("TryPush over budget = %v, want ErrBudgetExceeded", err)
+	}
+	if d.Held() != 2 {
+		t.Errorf("Held() = %d, want the rejected message not held", d.Held())
+	}
+	// Push cannot refuse, so it holds and charges past the cap.
+	d.PushAt(seqMsg(5, 4), now)
+	if d.Held() != 3 || b.Remaining() >= 0 {
+		t.Errorf("Push over budget held %d, remaining %d", d.Held(), b.Remaining())
+	}
+	tryPush(seqMsg(2, 1))
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after release", b.Used())
+	}
+
+	tryPush(seqMsg(10, 9))
+	d.Expire(now.Add(time.Minute))
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after expiry", b.Used())
+	}
+}
+
+func TestBudgetedBackfillEncoder(t *testing.T) {
+	hb := HeartbeatPayload{MonitorID: "mon-1", Status: "up", LatencyMs: 10}
+	b := NewConnectionBudget(3 * heartbeatSize(hb))
+	e := NewBackfillEncoder()
+	e.SetBudget(b)
+
+	now := time.Unix(1_700_000_000, 0)
+	for i := range 3 {
+		if err := e.Add(now.Add(time.Duration(i)*time.Second), hb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Add(now, hb); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Add over budget = %v, want ErrBudgetExceeded", err)
+	}
+	if e.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", e.Len())
+	}
+	if _, err := e.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after Encode", b.Used())
+	}
+}
+
+func TestBudgetedChunkAssembler(t *testing.T) {
+	b := NewConnectionBudget(25)
+	a := NewChunkAssembler()
+	a.SetBudget(b)
+
+	chunk := func(id string, index int) ChunkPayload {
+		return ChunkPayload{MessageID: id, Index: index, Total: 3, Data: []byte("0123456789")}
+	}
+	for i := range 2 {
+		if _, err := a.Add(chunk("a", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.Add(chunk("b", 0)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Add over budget = %v, want ErrBudgetExceeded", err)
+	}
+	if a.Pending() != 1 {
+		t.Errorf("Pending() = %d, want the rejected message not tracked", a.Pending())
+	}
+	a.Discard("a")
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after Discard", b.Used())
+	}
+
+	chunks, err := SplitMessage(NewPingMessage(), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = NewConnectionBudget(0)
+	a.SetBudget(b)
+	for _, c := range chunks {
+		var p ChunkPayload
+		if err := c.ParsePayload(&p); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after reassembly", b.Used())
+	}
+}
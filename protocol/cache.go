@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CachedResultPayload is pushed by hub with a precomputed check result the
+// agent adopts instead of running the check itself. The result is valid for
+// the monitor's ResultCacheTTLMs from the moment it is received.
+type CachedResultPayload struct {
+	MonitorID    string    `json:"monitor_id"`
+	Status       string    `json:"status"`
+	LatencyMs    int       `json:"latency_ms,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// Validate reports whether the cached result is well formed.
+func (p CachedResultPayload) Validate() error {
+	if p.MonitorID == "" {
+		return errors.New("cached_result: monitor_id is required")
+	}
+	if p.Status == "" {
+		return errors.New("cached_result: status is required")
+	}
+	if p.LatencyMs < 0 {
+		return fmt.Errorf("cached_result: latency_ms must be non-negative, got %d", p.LatencyMs)
+	}
+	return nil
+}
+
+// Fresh reports whether a cached result received at receivedAt may still be
+// adopted at now for a task with the given cache TTL.
+func (p CachedResultPayload) Fresh(ttlMs int, receivedAt, now time.Time) bool {
+	if ttlMs <= 0 {
+		return false
+	}
+	return now.Sub(receivedAt) < time.Duration(ttlMs)*time.Millisecond
+}
+
+// Heartbeat converts the cached result into the heartbeat the agent reports
+// for the monitor. The heartbeat is tagged with "cached" metadata so the hub
+// can tell adopted results from ones the agent checked itself.
+func (p CachedResultPayload) Heartbeat() HeartbeatPayload {
+	return HeartbeatPayload{
+		MonitorID:    p.MonitorID,
+		Status:       p.Status,
+		LatencyMs:    p.LatencyMs,
+		ErrorMessage: p.ErrorMessage,
+		Metadata:     map[string]string{"cached": "true"},
+	}
+}
+
+// NewCachedResultMessage creates a cached result message.
+func NewCachedResultMessage(monitorID, status string, latencyMs int, errorMsg string, checkedAt time.Time) *Message {
+	return MustNewMessage(MsgTypeCachedResult, CachedResultPayload{
+		MonitorID:    monitorID,
+		Status:       status,
+		LatencyMs:    latencyMs,
+		ErrorMessage: errorMsg,
+		CheckedAt:    checkedAt,
+	})
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachedResultFresh(t *testing.T) {
+	received := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := CachedResultPayload{MonitorID: "mon-1", Status: "up"}
+
+	tests := []struct {
+		name  string
+		ttlMs int
+		after time.Duration
+		want  bool
+	}{
+		{"just received", 1000, 0, true},
+		{"within ttl", 1000, 999 * time.Millisecond, true},
+		{"at ttl", 1000, time.Second, false},
+		{"past ttl", 1000, 2 * time.Second, false},
+		{"no cache ttl", 0, 0, false},
+		{"negative ttl", -1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Fresh(tt.ttlMs, received, received.Add(tt.after)); got != tt.want {
+				t.Errorf("Fresh(%d, +%v) = %v, want %v", tt.ttlMs, tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCachedResultHeartbeat(t *testing.T) {
+	p := CachedResultPayload{MonitorID: "mon-1", Status: "down", LatencyMs: 42, ErrorMessage: "refused"}
+	hb := p.Heartbeat()
+
+	if hb.MonitorID != "mon-1" || hb.Status != "down" || hb.LatencyMs != 42 || hb.ErrorMessage != "refused" {
+		t.Errorf("Heartbeat() = %+v, want fields copied from %+v", hb, p)
+	}
+	if hb.Metadata["cached"] != "true" {
+		t.Errorf("Heartbeat() metadata = %v, want cached=true", hb.Metadata)
+	}
+	if err := hb.Validate(); err != nil {
+		t.Errorf("Heartbeat() is invalid: %v", err)
+	}
+}
+
+func TestCachedResultValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       CachedResultPayload
+		wantErr bool
+	}{
+		{"valid", CachedResultPayload{MonitorID: "m", Status: "up"}, false},
+		{"missing monitor", CachedResultPayload{Status: "up"}, true},
+		{"missing status", CachedResultPayload{MonitorID: "m"}, true},
+		{"negative latency", CachedResultPayload{MonitorID: "m", Status: "up", LatencyMs: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCachedResultMessageRoundTrip(t *testing.T) {
+	checked := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewCachedResultMessage("mon-1", "up", 12, "", checked)
+	if err := m.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	var p CachedResultPayload
+	if err := m.ParsePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.MonitorID != "mon-1" || !p.CheckedAt.Equal(checked) {
+		t.Errorf("round trip = %+v", p)
+	}
+}
+
+func TestTaskResultCacheTTLValidate(t *testing.T) {
+	task := TaskPayload{MonitorID: "m", Type: "http", ResultCacheTTLMs: -1}
+	if err := task.Validate(); err == nil {
+		t.Error("Validate() accepted a negative result_cache_ttl_ms")
+	}
+}
@@ -0,0 +1,89 @@
+package protocol
+
+import (
+	"errors"
+	"slices"
+)
+
+// Feature capabilities an agent can advertise in AuthPayload.Capabilities, in
+// addition to one MonitorCapability per monitor type it can run.
+const (
+	CapabilityTCPProbe           = "tcp_probe"
+	CapabilityResultCache        = "result_cache"
+	CapabilityMaintenanceWindows = "maintenance_windows"
+	CapabilityRateBudget         = "rate_budget"
+)
+
+// MonitorCapability returns the capability for running checks of type t,
+// e.g. "monitor:http".
+func MonitorCapability(t MonitorType) string {
+	return "monitor:" + string(t)
+}
+
+// TaskRejectPayload is sent by agent when it refuses a task, so the hub can
+// assign the monitor elsewhere instead of waiting for heartbeats.
+type TaskRejectPayload struct {
+	MonitorID string `json:"monitor_id"`
+	Reason    string `json:"reason"`
+}
+
+// Validate reports whether the rejection names the monitor.
+func (p TaskRejectPayload) Validate() error {
+	if p.MonitorID == "" {
+		return errors.New("task_reject: monitor_id is required")
+	}
+	return nil
+}
+
+// NewTaskRejectMessage creates a task rejection.
+func NewTaskRejectMessage(monitorID, reason string) *Message {
+	return MustNewMessage(MsgTypeTaskReject, TaskRejectPayload{
+		MonitorID: monitorID,
+		Reason:    reason,
+	})
+}
+
+// TaskFilter is the agent's last line of defense against tasks it cannot
+// run, for a hub that assigns them regardless of the advertised capabilities.
+type TaskFilter struct {
+	caps []string
+}
+
+// NewTaskFilter creates a filter for an agent with the given capabilities.
+func NewTaskFilter(caps []string) *TaskFilter {
+	return &TaskFilter{caps: slices.Clone(caps)}
+}
+
+// Accept reports whether the agent can run task, and if not, the reason to
+// send back in a task rejection.
+func (f *TaskFilter) Accept(task TaskPayload) (bool, string) {
+	return AcceptTask(task, f.caps)
+}
+
+// AcceptTask reports whether an agent with caps can run task: the task must
+// be valid, its monitor type supported, and every optional feature it uses
+// advertised. If not, it returns the reason for the rejection.
+func AcceptTask(task TaskPayload, caps []string) (bool, string) {
+	if err := task.Validate(); err != nil {
+		return false, err.Error()
+	}
+	required := []string{MonitorCapability(MonitorType(task.Type))}
+	if task.SendData != "" || task.ExpectData != "" {
+		required = append(required, CapabilityTCPProbe)
+	}
+	if task.ResultCacheTTLMs > 0 {
+		required = append(required, CapabilityResultCache)
+	}
+	if len(task.MaintenanceWindows) > 0 {
+		required = append(required, CapabilityMaintenanceWindows)
+	}
+	if task.MaxChecksPerMinute > 0 {
+		required = append(required, CapabilityRateBudget)
+	}
+	for _, c := range required {
+		if !slices.Contains(caps, c) {
+			return false, "unsupported capability " + c
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptTask(t *testing.T) {
+	caps := []string{MonitorCapability(MonitorHTTP), MonitorCapability(MonitorTCP), CapabilityResultCache}
+	start := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name       string
+		task       TaskPayload
+		want       bool
+		wantReason string
+	}{
+		{"supported type", TaskPayload{MonitorID: "m", Type: "http"}, true, ""},
+		{"unsupported type", TaskPayload{MonitorID: "m", Type: "dns"}, false, "monitor:dns"},
+		{"invalid task", TaskPayload{Type: "http"}, false, "monitor_id is required"},
+		{"supported feature", TaskPayload{MonitorID: "m", Type: "http", ResultCacheTTLMs: 5000}, true, ""},
+		{"tcp probe not advertised", TaskPayload{MonitorID: "m", Type: "tcp", ExpectData: "220"}, false, CapabilityTCPProbe},
+		{"maintenance not advertised", TaskPayload{MonitorID: "m", Type: "http",
+			MaintenanceWindows: []Window{{Start: start, End: start.Add(time.Hour)}}}, false, CapabilityMaintenanceWindows},
+		{"rate budget not advertised", TaskPayload{MonitorID: "m", Type: "http", MaxChecksPerMinute: 10}, false, CapabilityRateBudget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := AcceptTask(tt.task, caps)
+			if got != tt.want || !strings.Contains(reason, tt.wantReason) || (got && reason != "") {
+				t.Errorf("AcceptTask() = %v, %q, want %v mentioning %q", got, reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestTaskFilter(t *testing.T) {
+	caps := []string{MonitorCapability(MonitorTCP), CapabilityTCPProbe}
+	f := NewTaskFilter(caps)
+	caps[0] = MonitorCapability(MonitorHTTP)
+
+	task := TaskPayload{MonitorID: "m", Type: "tcp", SendData: "PING\r\n", ExpectData: "+PONG"}
+	if ok, reason := f.Accept(task); !ok {
+		t.Errorf("Accept() = false, %q; filter must keep its own copy of the capabilities", reason)
+	}
+	ok, reason := f.Accept(TaskPayload{MonitorID: "m", Type: "http"})
+	if ok {
+		t.Fatal("Accept() took an unsupported task")
+	}
+	m := NewTaskRejectMessage("m", reason)
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var p TaskRejectPayload
+	if err := m.ParsePayload(&p); err != nil || p.Reason != reason {
+		t.Errorf("reject round trip = %+v, %v", p, err)
+	}
+	if err := (TaskRejectPayload{Reason: "x"}).Validate(); err == nil {
+		t.Error("Validate() accepted a rejection without a monitor")
+	}
+}
@@ -0,0 +1,155 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CertInfo describes one certificate of a TLS chain.
+type CertInfo struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+}
+
+// CertInfoFromX509 describes a parsed certificate.
+func CertInfoFromX509(cert *x509.Certificate) CertInfo {
+	sum := sha256.Sum256(cert.Raw)
+	return CertInfo{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.String(),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		FingerprintSHA256: hex.EncodeToString(sum[:]),
+	}
+}
+
+// HashCertChain returns a hash identifying a chain by every field of its
+// certificates, leaf first. Each field is length-prefixed and times are
+// hashed in UTC, so no two different chains share an encoding.
+func HashCertChain(chain []CertInfo) string {
+	h := sha256.New()
+	for _, c := range chain {
+		for _, field := range []string{
+			c.Subject,
+			c.Issuer,
+			c.SerialNumber,
+			c.NotBefore.UTC().Format(time.RFC3339Nano),
+			c.NotAfter.UTC().Format(time.RFC3339Nano),
+			c.FingerprintSHA256,
+		} {
+			fmt.Fprintf(h, "%d:%s", len(field), field)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CertInfoPayload is sent by agent with the certificate chain a TLS check saw.
+// The chain itself is only included when it differs from the one last
+// reported for the monitor; otherwise ChainHash alone refers to it.
+type CertInfoPayload struct {
+	MonitorID string     `json:"monitor_id"`
+	ChainHash string     `json:"chain_hash"`
+	Chain     []CertInfo `json:"chain,omitempty"`
+}
+
+// Validate reports whether the payload is well formed and an included chain
+// matches its hash.
+func (p CertInfoPayload) Validate() error {
+	if p.MonitorID == "" {
+		return errors.New("cert_info: monitor_id is required")
+	}
+	if p.ChainHash == "" {
+		return errors.New("cert_info: chain_hash is required")
+	}
+	if len(p.Chain) > 0 && HashCertChain(p.Chain) != p.ChainHash {
+		return fmt.Errorf("cert_info: chain does not match chain_hash %s", p.ChainHash)
+	}
+	return nil
+}
+
+// ChainChanged reports whether the reported chain differs from the one with
+// knownHash.
+func (p CertInfoPayload) ChainChanged(knownHash string) bool {
+	return p.ChainHash != knownHash
+}
+
+// NewCertInfoMessage creates a cert info message for chain, leaving the chain
+// out when its hash equals knownHash, the hash last reported for the monitor.
+func NewCertInfoMessage(monitorID string, chain []CertInfo, knownHash string) *Message {
+	p := CertInfoPayload{
+		MonitorID: monitorID,
+		ChainHash: HashCertChain(chain),
+	}
+	if p.ChainChanged(knownHash) {
+		p.Chain = chain
+	}
+	return MustNewMessage(MsgTypeCertInfo, p)
+}
+
+// maxCachedChains bounds how many chains a CertChainCache holds. Many
+// monitors usually share a chain, so this covers a large fleet.
+const maxCachedChains = 4096
+
+// CertChainCache remembers chains by hash so the hub can resolve cert info
+// payloads that carry only a hash. Once full it forgets the oldest chain
+// first. It is safe for concurrent use.
+type CertChainCache struct {
+	mu     sync.RWMutex
+	hashes *boundedSet[string]
+	chains map[string][]CertInfo
+}
+
+// NewCertChainCache creates an empty cache.
+func NewCertChainCache() *CertChainCache {
+	return &CertChainCache{
+		hashes: newBoundedSet[string](maxCachedChains),
+		chains: make(map[string][]CertInfo),
+	}
+}
+
+// Resolve returns the chain a payload refers to, storing it first if the
+// payload carries it and the hash is new. A chain already cached under the
+// hash is never replaced and is returned instead. It reports false for a hash
+// the cache has never seen or has forgotten, and for a chain that does not
+// match its hash, which is not stored; either way the hub should ask the
+// agent for the full chain.
+func (c *CertChainCache) Resolve(p CertInfoPayload) ([]CertInfo, bool) {
+	if len(p.Chain) > 0 {
+		if HashCertChain(p.Chain) != p.ChainHash {
+			return nil, false
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if chain, ok := c.chains[p.ChainHash]; ok {
+			return chain, true
+		}
+		if evicted, ok := c.hashes.insert(p.ChainHash); ok {
+			delete(c.chains, evicted)
+		}
+		c.chains[p.ChainHash] = p.Chain
+		return p.Chain, true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	chain, ok := c.chains[p.ChainHash]
+	return chain, ok
+}
+
+// Known reports whether the cache holds the chain with the given hash.
+func (c *CertChainCache) Known(hash string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.chains[hash]
+	return ok
+}
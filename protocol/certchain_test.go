@@ -0,0 +1,187 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testChain(n int) []CertInfo {
+	return []CertInfo{
+		{Subject: fmt.Sprintf("CN=leaf-%d", n), FingerprintSHA256: fmt.Sprintf("%064x", n)},
+		{Subject: "CN=intermediate", FingerprintSHA256: fmt.Sprintf("%064x", 1<<40)},
+	}
+}
+
+func TestCertInfoFromX509(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Unix(1_700_000_000, 0).UTC(),
+		NotAfter:     time.Unix(1_800_000_000, 0).UTC(),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := CertInfoFromX509(cert)
+	if info.Subject != "CN=example.com" || info.Issuer != "CN=example.com" || info.SerialNumber != "42" {
+		t.Errorf("CertInfoFromX509() = %+v", info)
+	}
+	if !info.NotAfter.Equal(tmpl.NotAfter) || len(info.FingerprintSHA256) != 64 {
+		t.Errorf("CertInfoFromX509() = %+v", info)
+	}
+}
+
+func TestHashCertChain(t *testing.T) {
+	a, b := testChain(1), testChain(2)
+	if HashCertChain(a) != HashCertChain(testChain(1)) {
+		t.Error("hash is not stable")
+	}
+	if HashCertChain(a) == HashCertChain(b) {
+		t.Error("different chains share a hash")
+	}
+	reversed := []CertInfo{a[1], a[0]}
+	if HashCertChain(a) == HashCertChain(reversed) {
+		t.Error("hash ignores certificate order")
+	}
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	base := CertInfo{Subject: "CN=a", Issuer: "CN=ca", SerialNumber: "1", NotBefore: at, NotAfter: at.AddDate(1, 0, 0), FingerprintSHA256: "ff"}
+	edits := map[string]func(*CertInfo){
+		"subject":    func(c *CertInfo) { c.Subject = "CN=forged" },
+		"issuer":     func(c *CertInfo) { c.Issuer = "CN=other" },
+		"serial":     func(c *CertInfo) { c.SerialNumber = "2" },
+		"not before": func(c *CertInfo) { c.NotBefore = c.NotBefore.Add(time.Nanosecond) },
+		"not after":  func(c *CertInfo) { c.NotAfter = c.NotAfter.AddDate(10, 0, 0) },
+		"shifted":    func(c *CertInfo) { c.Subject, c.Issuer = "CN=aCN=ca", "" },
+	}
+	for name, edit := range edits {
+		changed := base
+		edit(&changed)
+		if HashCertChain([]CertInfo{base}) == HashCertChain([]CertInfo{changed}) {
+			t.Errorf("hash ignores a change to the %s", name)
+		}
+	}
+	local := base
+	local.NotBefore = at.In(time.FixedZone("UTC+2", 2*60*60))
+	if HashCertChain([]CertInfo{base}) != HashCertChain([]CertInfo{local}) {
+		t.Error("hash depends on the time zone of an instant")
+	}
+}
+
+func TestCertInfoMessage(t *testing.T) {
+	chain := testChain(1)
+	hash := HashCertChain(chain)
+
+	var p CertInfoPayload
+	if err := NewCertInfoMessage("mon-1", chain, "").ParsePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Chain) != 2 || p.ChainHash != hash {
+		t.Errorf("first report = %+v, want the full chain", p)
+	}
+	if err := p.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	p = CertInfoPayload{}
+	if err := NewCertInfoMessage("mon-1", chain, hash).ParsePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Chain) != 0 || p.ChainHash != hash {
+		t.Errorf("unchanged report = %+v, want the hash only", p)
+	}
+}
+
+func TestCertInfoValidate(t *testing.T) {
+	chain := testChain(1)
+	tests := []struct {
+		name    string
+		p       CertInfoPayload
+		wantErr bool
+	}{
+		{"with chain", CertInfoPayload{MonitorID: "m", ChainHash: HashCertChain(chain), Chain: chain}, false},
+		{"hash only", CertInfoPayload{MonitorID: "m", ChainHash: "abc"}, false},
+		{"no monitor", CertInfoPayload{ChainHash: "abc"}, true},
+		{"no hash", CertInfoPayload{MonitorID: "m", Chain: chain}, true},
+		{"forged chain", CertInfoPayload{MonitorID: "m", ChainHash: HashCertChain(chain), Chain: testChain(2)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCertChainCache(t *testing.T) {
+	c := NewCertChainCache()
+	chain := testChain(1)
+	hash := HashCertChain(chain)
+
+	if _, ok := c.Resolve(CertInfoPayload{MonitorID: "m", ChainHash: hash}); ok {
+		t.Error("resolved a hash the cache has never seen")
+	}
+	if got, ok := c.Resolve(CertInfoPayload{MonitorID: "m", ChainHash: hash, Chain: chain}); !ok || len(got) != 2 {
+		t.Fatalf("Resolve() with chain = %v, %v", got, ok)
+	}
+	if got, ok := c.Resolve(CertInfoPayload{MonitorID: "other", ChainHash: hash}); !ok || got[0].Subject != "CN=leaf-1" {
+		t.Errorf("Resolve() by hash = %v, %v", got, ok)
+	}
+
+	// A chain that does not match its hash must not poison the cache.
+	forged := CertInfoPayload{MonitorID: "m", ChainHash: hash, Chain: testChain(2)}
+	if _, ok := c.Resolve(forged); ok {
+		t.Error("Resolve() accepted a chain that does not match its hash")
+	}
+	if got, _ := c.Resolve(CertInfoPayload{MonitorID: "m", ChainHash: hash}); got[0].Subject != "CN=leaf-1" {
+		t.Errorf("forged chain replaced the cached one: %v", got)
+	}
+	forged.ChainHash = HashCertChain(testChain(3))
+	if c.Resolve(forged); c.Known(forged.ChainHash) {
+		t.Error("forged chain stored under its claimed hash")
+	}
+
+	// A second report of a cached chain keeps the first one stored.
+	again := testChain(1)
+	if got, ok := c.Resolve(CertInfoPayload{MonitorID: "other", ChainHash: hash, Chain: again}); !ok || &got[0] != &chain[0] {
+		t.Errorf("Resolve() replaced the cached chain")
+	}
+}
+
+func TestCertChainCacheBounded(t *testing.T) {
+	c := NewCertChainCache()
+	first := HashCertChain(testChain(0))
+	for i := range maxCachedChains + 1 {
+		chain := testChain(i)
+		c.Resolve(CertInfoPayload{MonitorID: "m", ChainHash: HashCertChain(chain), Chain: chain})
+	}
+	if c.Known(first) {
+		t.Error("oldest chain not evicted")
+	}
+	if !c.Known(HashCertChain(testChain(maxCachedChains))) || !c.Known(HashCertChain(testChain(1))) {
+		t.Error("recent chains evicted")
+	}
+	c.mu.RLock()
+	n := len(c.chains)
+	c.mu.RUnlock()
+	if n != maxCachedChains {
+		t.Errorf("cache holds %d chains, want %d", n, maxCachedChains)
+	}
+}
@@ -0,0 +1,44 @@
+package protocol
+
+import "time"
+
+// ChangeFilter decides whether a heartbeat is worth sending, so that agents
+// can stay quiet while nothing changes. A heartbeat is sent when the status
+// differs from the last one sent, when latency moved by more than
+// LatencyDeltaPct percent, or when MaxQuiet has passed since the last send, so
+// the hub still sees the monitor is alive.
+type ChangeFilter struct {
+	// LatencyDeltaPct is the relative latency change, in percent, that counts
+	// as significant. Zero ignores latency.
+	LatencyDeltaPct float64
+	// MaxQuiet is the longest the agent may go without sending. Zero means
+	// every heartbeat is sent.
+	MaxQuiet time.Duration
+}
+
+// ShouldSend reports whether curr should be sent, given prev, the last
+// heartbeat sent for the monitor, and the time since it was sent. The first
+// heartbeat of a monitor, with a zero prev, is always sent.
+func (f ChangeFilter) ShouldSend(prev, curr HeartbeatPayload, sinceLastSend time.Duration) bool {
+	switch {
+	case prev.Status == "" || prev.Status != curr.Status:
+		return true
+	case f.MaxQuiet <= 0 || sinceLastSend >= f.MaxQuiet:
+		return true
+	}
+	return f.latencyChanged(prev.LatencyMs, curr.LatencyMs)
+}
+
+func (f ChangeFilter) latencyChanged(prev, curr int) bool {
+	if f.LatencyDeltaPct <= 0 || prev == curr {
+		return false
+	}
+	if prev == 0 {
+		return true
+	}
+	delta := float64(curr-prev) / float64(prev) * 100
+	if delta < 0 {
+		delta = -delta
+	}
+	return delta > f.LatencyDeltaPct
+}
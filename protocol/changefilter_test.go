@@ -0,0 +1,39 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChangeFilter(t *testing.T) {
+	f := ChangeFilter{LatencyDeltaPct: 50, MaxQuiet: time.Minute}
+	hb := func(status string, latency int) HeartbeatPayload {
+		return HeartbeatPayload{MonitorID: "m", Status: status, LatencyMs: latency}
+	}
+	tests := []struct {
+		name   string
+		filter ChangeFilter
+		prev   HeartbeatPayload
+		curr   HeartbeatPayload
+		since  time.Duration
+		want   bool
+	}{
+		{"first heartbeat", f, HeartbeatPayload{}, hb("up", 10), 0, true},
+		{"steady", f, hb("up", 100), hb("up", 110), 10 * time.Second, false},
+		{"status changed", f, hb("up", 100), hb("down", 100), time.Second, true},
+		{"latency jumped", f, hb("up", 100), hb("up", 151), time.Second, true},
+		{"latency dropped", f, hb("up", 100), hb("up", 40), time.Second, true},
+		{"latency at threshold", f, hb("up", 100), hb("up", 150), time.Second, false},
+		{"latency from zero", f, hb("up", 0), hb("up", 5), time.Second, true},
+		{"quiet too long", f, hb("up", 100), hb("up", 100), time.Minute, true},
+		{"latency ignored", ChangeFilter{MaxQuiet: time.Minute}, hb("up", 100), hb("up", 900), time.Second, false},
+		{"no max quiet sends everything", ChangeFilter{LatencyDeltaPct: 50}, hb("up", 100), hb("up", 100), time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.ShouldSend(tt.prev, tt.curr, tt.since); got != tt.want {
+				t.Errorf("ShouldSend() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxChunks is the most chunks one message may be split into.
+const MaxChunks = 4096
+
+// maxPendingChunked bounds how many incomplete messages a ChunkAssembler holds
+// at once. Starting another evicts the oldest.
+const maxPendingChunked = 256
+
+// ChunkPayload carries one piece of a message too large to send in one
+// frame. MessageID is the ID of the original message, Index counts from zero
+// and Total is the number of chunks.
+type ChunkPayload struct {
+	MessageID string `json:"message_id"`
+	Index     int    `json:"index"`
+	Total     int    `json:"total"`
+	Data      []byte `json:"data"`
+}
+
+// Validate reports whether the chunk is well formed.
+func (p ChunkPayload) Validate() error {
+	if p.MessageID == "" {
+		return errors.New("chunk: message_id is required")
+	}
+	if p.Total <= 0 || p.Total > MaxChunks {
+		return fmt.Errorf("chunk: total must be between 1 and %d, got %d", MaxChunks, p.Total)
+	}
+	if p.Index < 0 || p.Index >= p.Total {
+		return fmt.Errorf("chunk: index %d out of range for %d chunks", p.Index, p.Total)
+	}
+	return nil
+}
+
+// SplitMessage encodes m and splits it into chunk messages of at most
+// chunkSize bytes of data each, failing if that takes more than MaxChunks
+// chunks. A message without an ID is encoded with a new one, since the
+// receiver reassembles by ID; m itself is left unchanged.
+func SplitMessage(m *Message, chunkSize int) ([]*Message, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("chunk: size must be positive, got %d", chunkSize)
+	}
+	if m.ID == "" {
+		withID := *m
+		withID.ID = NewMessageID()
+		m = &withID
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	total := (len(data) + chunkSize - 1) / chunkSize
+	if total > MaxChunks {
+		return nil, fmt.Errorf("chunk: message needs %d chunks of %d bytes, more than %d", total, chunkSize, MaxChunks)
+	}
+	chunks := make([]*Message, 0, total)
+	for i := range total {
+		end := min((i+1)*chunkSize, len(data))
+		c, err := NewMessage(MsgTypeChunk, ChunkPayload{
+			MessageID: m.ID,
+			Index:     i,
+			Total:     total,
+			Data:      data[i*chunkSize : end],
+		})
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, nil
+}
+
+// ChunkAssembler reassembles chunked messages. Chunks of different messages
+// may be interleaved and arrive in any order. It holds at most 256 incomplete
+// messages, evicting the oldest to start another; Expire drops those whose
+// remaining chunks never came. It is safe for concurrent use.
+type ChunkAssembler struct {
+	mu      sync.Mutex
+	pending map[string]*partialMessage
+	budget  *ConnectionBudget
+}
+
+type partialMessage struct {
+	parts    [][]byte
+	received int
+	size     int
+	started  time.Time
+}
+
+// NewChunkAssembler creates an empty assembler.
+func NewChunkAssembler() *ChunkAssembler {
+	return &ChunkAssembler{pending: make(map[string]*partialMessage)}
+}
+
+// SetBudget makes the assembler charge the chunks it holds against b,
+// releasing them once their message is complete or discarded. Call it before
+// the first Add.
+func (a *ChunkAssembler) SetBudget(b *ConnectionBudget) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.budget = b
+}
+
+// Add stores a chunk and returns the original message once its last chunk is
+// in, or nil while chunks are still missing. Repeated chunks are ignored. A
+// chunk the budget cannot hold is rejected, leaving what was received of its
+// message in place. A reassembled message whose ID is not the chunks'
+// MessageID is rejected.
+func (a *ChunkAssembler) Add(c ChunkPayload) (*Message, error) {
+	return a.AddAt(c, time.Now())
+}
+
+// AddAt is Add with an explicit clock reading.
+func (a *ChunkAssembler) AddAt(c ChunkPayload, now time.Time) (*Message, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, ok := a.pending[c.MessageID]
+	if !ok {
+		if len(a.pending) >= maxPendingChunked {
+			a.drop(a.oldest())
+		}
+		p = &partialMessage{parts: make([][]byte, c.Total), started: now}
+		a.pending[c.MessageID] = p
+	}
+	if len(p.parts) != c.Total {
+		return nil, fmt.Errorf("chunk: message %s has %d chunks, chunk %d claims %d", c.MessageID, len(p.parts), c.Index, c.Total)
+	}
+	if p.parts[c.Index] != nil {
+		return nil, nil
+	}
+	if err := a.budget.Charge(len(c.Data)); err != nil {
+		if p.received == 0 {
+			delete(a.pending, c.MessageID)
+		}
+		return nil, fmt.Errorf("chunk: %w", err)
+	}
+	p.parts[c.Index] = c.Data
+	p.received++
+	p.size += len(c.Data)
+	if p.received < c.Total {
+		return nil, nil
+	}
+
+	a.drop(c.MessageID)
+	var data []byte
+	for _, part := range p.parts {
+		data = append(data, part...)
+	}
+	var m Message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, &DecodeError{MsgType: MsgTypeChunk, Err: err}
+	}
+	if m.ID != c.MessageID {
+		return nil, fmt.Errorf("chunk: reassembled message has id %q, chunks claim %q", m.ID, c.MessageID)
+	}
+	return &m, nil
+}
+
+// Pending returns the number of messages still missing chunks.
+func (a *ChunkAssembler) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.pending)
+}
+
+// Expire drops the messages whose first chunk arrived more than maxAge ago
+// and returns how many it dropped.
+func (a *ChunkAssembler) Expire(maxAge time.Duration) int {
+	return a.ExpireAt(time.Now(), maxAge)
+}
+
+// ExpireAt is Expire with an explicit clock reading.
+func (a *ChunkAssembler) ExpireAt(now time.Time, maxAge time.Duration) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for id, p := range a.pending {
+		if now.Sub(p.started) > maxAge {
+			a.drop(id)
+			n++
+		}
+	}
+	return n
+}
+
+// Discard drops the chunks received so far for a message, e.g. when the
+// sender gave up on it.
+func (a *ChunkAssembler) Discard(messageID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.drop(messageID)
+}
+
+// oldest returns the ID of the incomplete message started first.
+func (a *ChunkAssembler) oldest() string {
+	var id string
+	var started time.Time
+	for k, p := range a.pending {
+		if id == "" || p.started.Before(started) {
+			id, started = k, p.started
+		}
+	}
+	return id
+}
+
+func (a *ChunkAssembler) drop(messageID string) {
+	if p, ok := a.pending[messageID]; ok {
+		a.budget.Release(p.size)
+		delete(a.pending, messageID)
+	}
+}
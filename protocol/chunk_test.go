@@ -0,0 +1,231 @@
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func chunkPayloads(t *testing.T, msgs []*Message) []ChunkPayload {
+	t.Helper()
+	out := make([]ChunkPayload, len(msgs))
+	for i, m := range msgs {
+		if err := m.ParsePayload(&out[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func TestChunkValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       ChunkPayload
+		wantErr bool
+	}{
+		{"valid", ChunkPayload{MessageID: "m", Index: 0, Total: 1}, false},
+		{"last of many", ChunkPayload{MessageID: "m", Index: MaxChunks - 1, Total: MaxChunks}, false},
+		{"no message id", ChunkPayload{Index: 0, Total: 1}, true},
+		{"no total", ChunkPayload{MessageID: "m"}, true},
+		{"too many chunks", ChunkPayload{MessageID: "m", Total: MaxChunks + 1}, true},
+		{"huge total", ChunkPayload{MessageID: "m", Total: 1 << 50}, true},
+		{"negative index", ChunkPayload{MessageID: "m", Index: -1, Total: 2}, true},
+		{"index past total", ChunkPayload{MessageID: "m", Index: 2, Total: 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	m := NewLogMessage("mon-1", LogLevelInfo, strings.Repeat("x", 1000), 0)
+	chunks, err := SplitMessage(m, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != "" {
+		t.Errorf("SplitMessage set the caller's message ID to %q", m.ID)
+	}
+	payloads := chunkPayloads(t, chunks)
+	id := payloads[0].MessageID
+	if id == "" {
+		t.Error("SplitMessage did not give the message an ID")
+	}
+	for i, c := range payloads {
+		if c.MessageID != id || c.Index != i || c.Total != len(chunks) || len(c.Data) > 100 {
+			t.Errorf("chunk %d = id %q index %d total %d, %d bytes", i, c.MessageID, c.Index, c.Total, len(c.Data))
+		}
+	}
+
+	m.ID = "log-1"
+	chunks, err = SplitMessage(m, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := chunkPayloads(t, chunks)[0]; c.MessageID != "log-1" {
+		t.Errorf("chunk MessageID = %q, want the message's own ID", c.MessageID)
+	}
+
+	if _, err := SplitMessage(m, 0); err == nil {
+		t.Error("SplitMessage accepted a zero chunk size")
+	}
+	big := NewLogMessage("mon-1", LogLevelInfo, strings.Repeat("x", MaxChunks+1), 0)
+	if _, err := SplitMessage(big, 1); err == nil {
+		t.Error("SplitMessage produced more than MaxChunks chunks")
+	}
+}
+
+func TestChunkAssembler(t *testing.T) {
+	orig := NewLogMessage("mon-1", LogLevelInfo, strings.Repeat("abcdefgh", 64), 0)
+	orig.ID = "log-1"
+	msgs, err := SplitMessage(orig, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := chunkPayloads(t, msgs)
+	n := len(chunks)
+
+	reversed := make([]ChunkPayload, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		reversed = append(reversed, chunks[i])
+	}
+	tests := []struct {
+		name  string
+		order []ChunkPayload
+	}{
+		{"in order", chunks},
+		{"reversed", reversed},
+		{"with repeats", append([]ChunkPayload{chunks[0], chunks[0]}, chunks[1:]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewChunkAssembler()
+			var got *Message
+			for i, c := range tt.order {
+				m, err := a.Add(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if m != nil && i != len(tt.order)-1 {
+					t.Fatalf("message complete after %d of %d chunks", i+1, len(tt.order))
+				}
+				got = m
+			}
+			if got == nil {
+				t.Fatal("message not reassembled")
+			}
+			if got.ID != orig.ID || !bytes.Equal(got.Payload, orig.Payload) {
+				t.Errorf("reassembled %s %s, want %s %s", got.ID, got.Payload, orig.ID, orig.Payload)
+			}
+			if a.Pending() != 0 {
+				t.Errorf("Pending() = %d after completion", a.Pending())
+			}
+		})
+	}
+}
+
+func TestChunkAssemblerRejects(t *testing.T) {
+	a := NewChunkAssembler()
+	if _, err := a.Add(ChunkPayload{MessageID: "m", Index: 0, Total: 1 << 50}); err == nil {
+		t.Error("Add() accepted a chunk claiming 1<<50 chunks")
+	}
+	if _, err := a.Add(ChunkPayload{MessageID: "m", Index: 0, Total: 3, Data: []byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Add(ChunkPayload{MessageID: "m", Index: 1, Total: 4, Data: []byte("b")}); err == nil {
+		t.Error("Add() accepted a chunk disagreeing on the total")
+	}
+	if _, err := a.Add(ChunkPayload{MessageID: "n", Index: 0, Total: 1, Data: []byte("not json")}); err == nil {
+		t.Error("Add() accepted chunks that do not decode to a message")
+	}
+	a.Discard("m")
+	if a.Pending() != 0 {
+		t.Errorf("Pending() = %d after Discard, want 0", a.Pending())
+	}
+
+	// Chunks relabelled with another message's ID must not reassemble into
+	// a message that claims the original ID.
+	orig := NewPingMessage()
+	orig.ID = "ping-1"
+	msgs, err := SplitMessage(orig, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got error
+	for _, c := range chunkPayloads(t, msgs) {
+		c.MessageID = "ping-2"
+		_, got = a.Add(c)
+	}
+	if got == nil {
+		t.Error("Add() accepted a message whose ID differs from its chunks'")
+	}
+}
+
+func TestChunkAssemblerExpire(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	budget := NewConnectionBudget(100)
+	a := NewChunkAssembler()
+	a.SetBudget(budget)
+	for i, id := range []string{"a", "b", "c"} {
+		c := ChunkPayload{MessageID: id, Index: 0, Total: 2, Data: []byte("0123456789")}
+		if _, err := a.AddAt(c, start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		at      time.Duration
+		dropped int
+		pending int
+		used    int
+	}{
+		{10 * time.Second, 0, 3, 30},
+		{11*time.Second + 1, 2, 1, 10},
+		{time.Minute, 1, 0, 0},
+	}
+	for _, s := range steps {
+		if got := a.ExpireAt(start.Add(s.at), 10*time.Second); got != s.dropped {
+			t.Errorf("ExpireAt(+%v) = %d, want %d", s.at, got, s.dropped)
+		}
+		if a.Pending() != s.pending || budget.Used() != s.used {
+			t.Errorf("after +%v: Pending() = %d, Used() = %d; want %d, %d", s.at, a.Pending(), budget.Used(), s.pending, s.used)
+		}
+	}
+}
+
+func TestChunkAssemblerEvictsOldest(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	budget := NewConnectionBudget(0)
+	a := NewChunkAssembler()
+	a.SetBudget(budget)
+	add := func(id string, at time.Time) {
+		t.Helper()
+		if _, err := a.AddAt(ChunkPayload{MessageID: id, Index: 0, Total: 2, Data: []byte("x")}, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range maxPendingChunked {
+		add(fmt.Sprintf("m%d", i), start.Add(time.Duration(i)*time.Millisecond))
+	}
+	add("new", start.Add(time.Second))
+
+	if a.Pending() != maxPendingChunked {
+		t.Errorf("Pending() = %d, want %d", a.Pending(), maxPendingChunked)
+	}
+	if budget.Used() != maxPendingChunked {
+		t.Errorf("Used() = %d, want the evicted chunk released", budget.Used())
+	}
+	// The oldest message was evicted, so its last chunk starts it over.
+	if m, err := a.AddAt(ChunkPayload{MessageID: "m0", Index: 1, Total: 2, Data: []byte("y")}, start.Add(2*time.Second)); m != nil || err != nil {
+		t.Errorf("Add() to an evicted message = %v, %v", m, err)
+	}
+	if _, ok := a.pending["m1"]; ok {
+		t.Error("m1 should have been evicted to make room for m0")
+	}
+}
@@ -0,0 +1,135 @@
+package protocol
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// SessionCompressor compresses a whole session over a byte stream with one
+// persistent flate stream per direction, so repeated monitor IDs and field
+// names are compressed against everything sent before them rather than per
+// message. Every write is flushed, which keeps message boundaries intact for
+// the reader without resetting the shared dictionary.
+//
+// Write and Read move raw bytes; WriteMessage and ReadMessage frame one
+// message per line on top of them, and the two styles may be mixed. Writes
+// and reads are each serialized internally and may run concurrently with
+// each other.
+type SessionCompressor struct {
+	wmu sync.Mutex
+	w   *flate.Writer
+
+	rmu    sync.Mutex
+	r      io.ReadCloser
+	br     *bufio.Reader
+	budget *ConnectionBudget
+}
+
+// NewSessionCompressor wraps rw. The level is one of the compress/flate
+// levels, e.g. flate.DefaultCompression.
+func NewSessionCompressor(rw io.ReadWriter, level int) (*SessionCompressor, error) {
+	w, err := flate.NewWriter(rw, level)
+	if err != nil {
+		return nil, err
+	}
+	// flate.NewReader wants an io.ByteReader so it never reads past the end
+	// of the compressed data it needs.
+	r := flate.NewReader(bufio.NewReader(rw))
+	return &SessionCompressor{w: w, r: r, br: bufio.NewReader(r)}, nil
+}
+
+// Write compresses p and flushes it to the underlying stream.
+func (c *SessionCompressor) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	n, err := c.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, c.w.Flush()
+}
+
+// Read decompresses data from the underlying stream.
+func (c *SessionCompressor) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.br.Read(p)
+}
+
+// SetBudget makes ReadMessage charge the message it is decoding against b,
+// releasing it once the message is decoded.
+func (c *SessionCompressor) SetBudget(b *ConnectionBudget) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.budget = b
+}
+
+// WriteMessage encodes m as one line of JSON and writes it.
+func (c *SessionCompressor) WriteMessage(m *Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = c.Write(append(data, '\n'))
+	return err
+}
+
+// ReadMessage decodes the next message written with WriteMessage. A message
+// that inflates to more than MaxDecompressedSize bytes fails with
+// ErrDecompressedTooLarge, and one the budget cannot hold while it is decoded
+// fails with an error wrapping ErrBudgetExceeded; the stream cannot be
+// resynchronized after either, so the session should be closed.
+func (c *SessionCompressor) ReadMessage() (*Message, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	var line []byte
+	defer func() { c.budget.Release(len(line)) }()
+	for {
+		chunk, err := c.br.ReadSlice('\n')
+		if len(line)+len(chunk) > MaxDecompressedSize+1 {
+			return nil, ErrDecompressedTooLarge
+		}
+		if err := c.budget.Charge(len(chunk)); err != nil {
+			return nil, fmt.Errorf("compression: %w", err)
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			if err == io.EOF && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+
+	var m Message
+	if err := json.Unmarshal(line, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Close terminates the compressed stream in both directions. It does not
+// close the underlying stream, and it waits for a Read or ReadMessage in
+// progress, so close the underlying stream first if a reader may be blocked
+// on it.
+func (c *SessionCompressor) Close() error {
+	c.wmu.Lock()
+	err := c.w.Close()
+	c.wmu.Unlock()
+
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if rerr := c.r.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
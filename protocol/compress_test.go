@@ -0,0 +1,266 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionCompressorRoundTrip(t *testing.T) {
+	agentConn, hubConn := net.Pipe()
+	defer agentConn.Close()
+	defer hubConn.Close()
+
+	agent, err := NewSessionCompressor(agentConn, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := NewSessionCompressor(hubConn, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := []*Message{
+		NewHeartbeatMessage("mon-1", "up", 12, ""),
+		NewHeartbeatMessage("mon-1", "down", 0, "connection refused"),
+		NewLogMessage("mon-2", LogLevelInfo, "hello", 0),
+	}
+
+	// net.Pipe is synchronous, so the hub must read while the agent writes.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, m := range sent {
+			if err := agent.WriteMessage(m); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for i, want := range sent {
+		got, err := hub.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage %d: %v", i, err)
+		}
+		if got.Type != want.Type || !bytes.Equal(got.Payload, want.Payload) {
+			t.Errorf("message %d = %s %s, want %s %s", i, got.Type, got.Payload, want.Type, want.Payload)
+		}
+	}
+	wg.Wait()
+}
+
+func TestSessionCompressorConcurrentWriters(t *testing.T) {
+	var buf bytes.Buffer
+	var bufMu sync.Mutex
+	w, err := NewSessionCompressor(lockedReadWriter{&buf, &bufMu}, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, each = 4, 50
+	var wg sync.WaitGroup
+	for g := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range each {
+				if err := w.WriteMessage(NewHeartbeatMessage(fmt.Sprintf("mon-%d-%d", g, i), "up", i, "")); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	r, err := NewSessionCompressor(lockedReadWriter{&buf, &bufMu}, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range writers * each {
+		if _, err := r.ReadMessage(); err != nil {
+			t.Fatalf("ReadMessage %d: %v", i, err)
+		}
+	}
+}
+
+func TestSessionCompressorRawAndMessages(t *testing.T) {
+	var buf bytes.Buffer
+	var bufMu sync.Mutex
+	w, err := NewSessionCompressor(lockedReadWriter{&buf, &bufMu}, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("raw bytes|")); err != nil {
+		t.Fatal(err)
+	}
+	sent := NewHeartbeatMessage("mon-1", "up", 12, "")
+	if err := w.WriteMessage(sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewSessionCompressor(lockedReadWriter{&buf, &bufMu}, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, len("raw bytes|"))
+	if _, err := io.ReadFull(r, raw); err != nil || string(raw) != "raw bytes|" {
+		t.Fatalf("Read() = %q, %v", raw, err)
+	}
+	got, err := r.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != sent.Type || !bytes.Equal(got.Payload, sent.Payload) {
+		t.Errorf("ReadMessage() = %s %s after raw bytes", got.Type, got.Payload)
+	}
+	if _, err := r.ReadMessage(); err != io.EOF {
+		t.Errorf("ReadMessage() at the end = %v, want io.EOF", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+}
+
+func TestSessionCompressorMessageLimit(t *testing.T) {
+	var buf bytes.Buffer
+	var bufMu sync.Mutex
+	w, err := NewSessionCompressor(lockedReadWriter{&buf, &bufMu}, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A line of over MaxDecompressedSize bytes compresses to a few kilobytes.
+	if _, err := w.Write(append(bytes.Repeat([]byte("a"), MaxDecompressedSize+1), '\n')); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 1<<16 {
+		t.Fatalf("compressed bomb is %d bytes", buf.Len())
+	}
+	r, err := NewSessionCompressor(lockedReadWriter{&buf, &bufMu}, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadMessage(); !errors.Is(err, ErrDecompressedTooLarge) {
+		t.Errorf("ReadMessage() = %v, want ErrDecompressedTooLarge", err)
+	}
+}
+
+func TestSessionCompressorBudget(t *testing.T) {
+	var buf bytes.Buffer
+	var bufMu sync.Mutex
+	w, err := NewSessionCompressor(lockedReadWriter{&buf, &bufMu}, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := NewPingMessage()
+	large := NewLogMessage("m", LogLevelInfo, strings.Repeat("x", 4096), 0)
+	for _, m := range []*Message{small, large} {
+		if err := w.WriteMessage(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewSessionCompressor(lockedReadWriter{&buf, &bufMu}, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewConnectionBudget(1024)
+	r.SetBudget(b)
+	if _, err := r.ReadMessage(); err != nil {
+		t.Fatalf("ReadMessage() within budget = %v", err)
+	}
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after decoding", b.Used())
+	}
+	if _, err := r.ReadMessage(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("ReadMessage() over budget = %v, want ErrBudgetExceeded", err)
+	}
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after a failed decode", b.Used())
+	}
+}
+
+type lockedReadWriter struct {
+	buf *bytes.Buffer
+	mu  *sync.Mutex
+}
+
+func (l lockedReadWriter) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Read(p)
+}
+
+func (l lockedReadWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+// sessionBacklog is a realistic stream: many heartbeats for a fixed set of
+// monitors, as an agent sends them between reconnects.
+func sessionBacklog() []*Message {
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var msgs []*Message
+	for i := range 1000 {
+		m := NewHeartbeatMessage(fmt.Sprintf("3f6c2a9e-%04d-4f1e-9c1d-7a0b5e2c8d41", i%50), "up", 20+i%17, "")
+		m.Timestamp = ts.Add(time.Duration(i) * time.Second)
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+// BenchmarkSessionCompression compares one persistent stream for the whole
+// session with compressing every message on its own.
+func BenchmarkSessionCompression(b *testing.B) {
+	msgs := sessionBacklog()
+
+	b.Run("session", func(b *testing.B) {
+		var n int
+		for b.Loop() {
+			var buf bytes.Buffer
+			c, err := NewSessionCompressor(struct {
+				io.Reader
+				io.Writer
+			}{&bytes.Buffer{}, &buf}, flate.DefaultCompression)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, m := range msgs {
+				if err := c.WriteMessage(m); err != nil {
+					b.Fatal(err)
+				}
+			}
+			n = buf.Len()
+		}
+		b.ReportMetric(float64(n)/float64(len(msgs)), "bytes/msg")
+	})
+
+	b.Run("per-message", func(b *testing.B) {
+		var n int
+		for b.Loop() {
+			n = 0
+			for _, m := range msgs {
+				data, _ := json.Marshal(m)
+				var buf bytes.Buffer
+				w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+				w.Write(data)
+				w.Close()
+				n += buf.Len()
+			}
+		}
+		b.ReportMetric(float64(n)/float64(len(msgs)), "bytes/msg")
+	})
+}
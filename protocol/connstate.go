@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ConnState is a stage of an agent connection's lifecycle.
+type ConnState int
+
+// Connection states.
+const (
+	ConnConnecting ConnState = iota
+	ConnAuthenticating
+	ConnReady
+	ConnPaused
+	ConnReconnecting
+	ConnClosing
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnConnecting:
+		return "connecting"
+	case ConnAuthenticating:
+		return "authenticating"
+	case ConnReady:
+		return "ready"
+	case ConnPaused:
+		return "paused"
+	case ConnReconnecting:
+		return "reconnecting"
+	case ConnClosing:
+		return "closing"
+	}
+	return fmt.Sprintf("ConnState(%d)", int(s))
+}
+
+// Event moves a connection from one state to another.
+type Event int
+
+// Connection events.
+const (
+	// EventConnected fires when the transport is up and auth can be sent.
+	EventConnected Event = iota
+	// EventAuthAck fires when the hub accepts the agent's credentials.
+	EventAuthAck
+	// EventAuthError fires when the hub rejects the agent's credentials.
+	EventAuthError
+	// EventPause fires when check execution is suspended.
+	EventPause
+	// EventResume fires when check execution continues after a pause.
+	EventResume
+	// EventDisconnected fires when the transport drops unexpectedly.
+	EventDisconnected
+	// EventClose fires when either side shuts the connection down.
+	EventClose
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventConnected:
+		return "connected"
+	case EventAuthAck:
+		return "auth_ack"
+	case EventAuthError:
+		return "auth_error"
+	case EventPause:
+		return "pause"
+	case EventResume:
+		return "resume"
+	case EventDisconnected:
+		return "disconnected"
+	case EventClose:
+		return "close"
+	}
+	return fmt.Sprintf("Event(%d)", int(e))
+}
+
+type connTransition struct {
+	from  ConnState
+	event Event
+}
+
+// connTransitions is the full table of valid transitions. Anything missing
+// is rejected by ConnStateMachine.Transition.
+var connTransitions = map[connTransition]ConnState{
+	{ConnConnecting, EventConnected}:        ConnAuthenticating,
+	{ConnConnecting, EventDisconnected}:     ConnReconnecting,
+	{ConnConnecting, EventClose}:            ConnClosing,
+	{ConnAuthenticating, EventAuthAck}:      ConnReady,
+	{ConnAuthenticating, EventAuthError}:    ConnClosing,
+	{ConnAuthenticating, EventDisconnected}: ConnReconnecting,
+	{ConnAuthenticating, EventClose}:        ConnClosing,
+	{ConnReady, EventPause}:                 ConnPaused,
+	{ConnReady, EventDisconnected}:          ConnReconnecting,
+	{ConnReady, EventClose}:                 ConnClosing,
+	{ConnPaused, EventResume}:               ConnReady,
+	{ConnPaused, EventDisconnected}:         ConnReconnecting,
+	{ConnPaused, EventClose}:                ConnClosing,
+	{ConnReconnecting, EventConnected}:      ConnAuthenticating,
+	{ConnReconnecting, EventClose}:          ConnClosing,
+}
+
+// EventForMessage returns the event a received message drives, if any.
+func EventForMessage(msgType string) (Event, bool) {
+	switch msgType {
+	case MsgTypeAuthAck:
+		return EventAuthAck, true
+	case MsgTypeAuthError:
+		return EventAuthError, true
+	}
+	return 0, false
+}
+
+// ConnStateMachine enforces the connection lifecycle. The zero value starts
+// in ConnConnecting. It is safe for concurrent use.
+type ConnStateMachine struct {
+	mu    sync.Mutex
+	state ConnState
+}
+
+// State returns the current state.
+func (sm *ConnStateMachine) State() ConnState {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.state
+}
+
+// Transition applies event and returns the new state. An event that is not
+// valid in the current state is rejected and leaves the state unchanged.
+func (sm *ConnStateMachine) Transition(event Event) (ConnState, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	next, ok := connTransitions[connTransition{sm.state, event}]
+	if !ok {
+		return sm.state, fmt.Errorf("connection: invalid event %s in state %s", event, sm.state)
+	}
+	sm.state = next
+	return next, nil
+}
+
+// CanSend reports whether a message of the given type may be sent in the
+// current state. Only auth traffic is allowed before the connection is ready,
+// and only liveness traffic while paused.
+func (sm *ConnStateMachine) CanSend(msgType string) bool {
+	switch sm.State() {
+	case ConnAuthenticating:
+		return msgType == MsgTypeAuth || msgType == MsgTypeAuthAck || msgType == MsgTypeAuthError
+	case ConnReady:
+		return true
+	case ConnPaused:
+		return msgType == MsgTypePing || msgType == MsgTypePong
+	}
+	return false
+}
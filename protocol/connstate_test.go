@@ -0,0 +1,135 @@
+package protocol
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConnStateMachineLifecycle(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   ConnState
+	}{
+		{"auth succeeds", []Event{EventConnected, EventAuthAck}, ConnReady},
+		{"auth fails", []Event{EventConnected, EventAuthError}, ConnClosing},
+		{"pause and resume", []Event{EventConnected, EventAuthAck, EventPause, EventResume}, ConnReady},
+		{"drop while ready", []Event{EventConnected, EventAuthAck, EventDisconnected}, ConnReconnecting},
+		{"reconnect", []Event{EventConnected, EventAuthAck, EventDisconnected, EventConnected, EventAuthAck}, ConnReady},
+		{"drop while paused", []Event{EventConnected, EventAuthAck, EventPause, EventDisconnected}, ConnReconnecting},
+		{"close before connect", []Event{EventClose}, ConnClosing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sm ConnStateMachine
+			for _, e := range tt.events {
+				if _, err := sm.Transition(e); err != nil {
+					t.Fatalf("Transition(%s): %v", e, err)
+				}
+			}
+			if got := sm.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnStateMachineRejectsInvalidEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  []Event
+		invalid Event
+	}{
+		{"auth ack before connect", nil, EventAuthAck},
+		{"pause before ready", []Event{EventConnected}, EventPause},
+		{"resume while ready", []Event{EventConnected, EventAuthAck}, EventResume},
+		{"anything after close", []Event{EventClose}, EventConnected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sm ConnStateMachine
+			for _, e := range tt.prefix {
+				if _, err := sm.Transition(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := sm.State()
+			if _, err := sm.Transition(tt.invalid); err == nil {
+				t.Fatalf("Transition(%s) in %s succeeded", tt.invalid, before)
+			}
+			if sm.State() != before {
+				t.Errorf("rejected event changed state to %s", sm.State())
+			}
+		})
+	}
+}
+
+func TestConnStateMachineCanSend(t *testing.T) {
+	tests := []struct {
+		events  []Event
+		msgType string
+		want    bool
+	}{
+		{nil, MsgTypeAuth, false},
+		{[]Event{EventConnected}, MsgTypeAuth, true},
+		{[]Event{EventConnected}, MsgTypeHeartbeat, false},
+		{[]Event{EventConnected, EventAuthAck}, MsgTypeHeartbeat, true},
+		{[]Event{EventConnected, EventAuthAck, EventPause}, MsgTypePing, true},
+		{[]Event{EventConnected, EventAuthAck, EventPause}, MsgTypeHeartbeat, false},
+		{[]Event{EventClose}, MsgTypePing, false},
+	}
+	for _, tt := range tests {
+		var sm ConnStateMachine
+		for _, e := range tt.events {
+			sm.Transition(e)
+		}
+		if got := sm.CanSend(tt.msgType); got != tt.want {
+			t.Errorf("in %s CanSend(%s) = %v, want %v", sm.State(), tt.msgType, got, tt.want)
+		}
+	}
+}
+
+func TestEventForMessage(t *testing.T) {
+	if e, ok := EventForMessage(MsgTypeAuthAck); !ok || e != EventAuthAck {
+		t.Errorf("EventForMessage(auth_ack) = %s, %v", e, ok)
+	}
+	if e, ok := EventForMessage(MsgTypeAuthError); !ok || e != EventAuthError {
+		t.Errorf("EventForMessage(auth_error) = %s, %v", e, ok)
+	}
+	if _, ok := EventForMessage(MsgTypeHeartbeat); ok {
+		t.Error("heartbeat drives a connection event")
+	}
+}
+
+func TestConnStateMachineConcurrent(t *testing.T) {
+	var sm ConnStateMachine
+	sm.Transition(EventConnected)
+	sm.Transition(EventAuthAck)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Pause and resume race; whichever fails is simply rejected.
+			for range 100 {
+				sm.Transition(EventPause)
+				sm.CanSend(MsgTypeHeartbeat)
+				sm.Transition(EventResume)
+			}
+		}()
+	}
+	wg.Wait()
+	if s := sm.State(); s != ConnReady && s != ConnPaused {
+		t.Errorf("State() = %s after pause/resume races", s)
+	}
+}
+
+func TestConnStateString(t *testing.T) {
+	if ConnReady.String() != "ready" || EventDisconnected.String() != "disconnected" {
+		t.Errorf("names = %s, %s", ConnReady, EventDisconnected)
+	}
+	if ConnState(99).String() != "ConnState(99)" || Event(99).String() != "Event(99)" {
+		t.Errorf("unknown values = %s, %s", ConnState(99), Event(99))
+	}
+}
@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// NetworkConstraints describes outbound restrictions on the agent's network,
+// reported at auth so the hub does not assign checks the agent cannot run.
+type NetworkConstraints struct {
+	// HasProxy means HTTP checks go out through a proxy.
+	HasProxy bool `json:"has_proxy,omitempty"`
+	// AllowedPorts, when not empty, lists the only ports direct connections
+	// may use.
+	AllowedPorts []int `json:"allowed_ports,omitempty"`
+	// EgressBlocked means the agent cannot open direct connections off the
+	// host at all.
+	EgressBlocked bool `json:"egress_blocked,omitempty"`
+}
+
+// CanReach reports whether an agent with the given constraints can perform a
+// task, and if not, why. Checks of the agent's own host (docker, system,
+// service, and any target on a loopback address) are always possible. HTTP
+// checks are possible whenever a proxy is available. Other checks need direct
+// egress on the target port; ping has no port, and port scans need every port.
+func CanReach(constraints NetworkConstraints, task TaskPayload) (bool, string) {
+	switch MonitorType(task.Type) {
+	case MonitorDocker, MonitorSystem, MonitorService:
+		return true, ""
+	}
+
+	host, port := taskEndpoint(task)
+	if isLoopbackHost(host) {
+		return true, ""
+	}
+	if MonitorType(task.Type) == MonitorHTTP && constraints.HasProxy {
+		return true, ""
+	}
+	if constraints.EgressBlocked {
+		return false, "agent has no outbound network access"
+	}
+	if len(constraints.AllowedPorts) == 0 {
+		return true, ""
+	}
+
+	switch MonitorType(task.Type) {
+	case MonitorPing:
+		return true, ""
+	case MonitorPortScan:
+		return false, "port scans need unrestricted outbound ports"
+	}
+	if port == 0 {
+		// Without a known port there is nothing to hold against the list.
+		return true, ""
+	}
+	if !slices.Contains(constraints.AllowedPorts, port) {
+		return false, "outbound port " + strconv.Itoa(port) + " is not allowed"
+	}
+	return true, ""
+}
+
+// taskEndpoint extracts the host and port a task connects to. The port is 0
+// when the monitor type has none or it cannot be determined.
+func taskEndpoint(task TaskPayload) (string, int) {
+	switch MonitorType(task.Type) {
+	case MonitorHTTP:
+		u, err := url.Parse(task.Target)
+		if err != nil {
+			return "", 0
+		}
+		if p, err := strconv.Atoi(u.Port()); err == nil {
+			return u.Hostname(), p
+		}
+		if u.Scheme == "https" {
+			return u.Hostname(), 443
+		}
+		return u.Hostname(), 80
+	case MonitorTLS:
+		return splitHostPort(task.Target, 443)
+	case MonitorDNS:
+		return splitHostPort(task.Target, 53)
+	case MonitorSNMP:
+		return splitHostPort(task.Target, 161)
+	case MonitorPing, MonitorPortScan:
+		return splitHostPort(task.Target, 0)
+	}
+	return splitHostPort(task.Target, 0)
+}
+
+func splitHostPort(target string, defaultPort int) (string, int) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return target, defaultPort
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return host, defaultPort
+	}
+	return host, port
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,74 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanReach(t *testing.T) {
+	none := NetworkConstraints{}
+	proxied := NetworkConstraints{HasProxy: true, EgressBlocked: true}
+	blocked := NetworkConstraints{EgressBlocked: true}
+	webOnly := NetworkConstraints{AllowedPorts: []int{80, 443}}
+	task := func(typ, target string) TaskPayload {
+		return TaskPayload{MonitorID: "m", Type: typ, Target: target}
+	}
+	tests := []struct {
+		name        string
+		constraints NetworkConstraints
+		task        TaskPayload
+		want        bool
+		wantReason  string
+	}{
+		{"unconstrained tcp", none, task("tcp", "db:5432"), true, ""},
+		{"unconstrained port scan", none, task("port_scan", "10.0.0.1"), true, ""},
+
+		{"blocked http", blocked, task("http", "https://example.com"), false, "no outbound network"},
+		{"blocked tcp", blocked, task("tcp", "db:5432"), false, "no outbound network"},
+		{"blocked ping", blocked, task("ping", "10.0.0.1"), false, "no outbound network"},
+		{"blocked local docker", blocked, task("docker", "nginx"), true, ""},
+		{"blocked local system", blocked, task("system", "cpu:90"), true, ""},
+		{"blocked loopback tcp", blocked, task("tcp", "127.0.0.1:6379"), true, ""},
+		{"blocked localhost http", blocked, task("http", "http://localhost:8080/health"), true, ""},
+		{"blocked ipv6 loopback", blocked, task("tcp", "[::1]:5432"), true, ""},
+
+		{"proxy http", proxied, task("http", "https://example.com"), true, ""},
+		{"proxy does not help tcp", proxied, task("tcp", "db:5432"), false, "no outbound network"},
+
+		{"allowed https default port", webOnly, task("http", "https://example.com"), true, ""},
+		{"allowed http default port", webOnly, task("http", "http://example.com"), true, ""},
+		{"disallowed http port", webOnly, task("http", "https://example.com:8443"), false, "port 8443"},
+		{"allowed tls default port", webOnly, task("tls", "example.com"), true, ""},
+		{"disallowed dns default port", webOnly, task("dns", "example.com"), false, "port 53"},
+		{"disallowed snmp default port", webOnly, task("snmp", "10.0.0.5"), false, "port 161"},
+		{"disallowed tcp port", webOnly, task("tcp", "db:5432"), false, "port 5432"},
+		{"allowed tcp port", webOnly, task("tcp", "web:443"), true, ""},
+		{"ping has no port", webOnly, task("ping", "10.0.0.1"), true, ""},
+		{"port scan needs all ports", webOnly, task("port_scan", "10.0.0.1"), false, "port scans"},
+		{"unknown port", webOnly, task("database", "postgres"), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := CanReach(tt.constraints, tt.task)
+			if got != tt.want {
+				t.Errorf("CanReach() = %v (%q), want %v", got, reason, tt.want)
+			}
+			if !strings.Contains(reason, tt.wantReason) || (tt.want && reason != "") {
+				t.Errorf("reason = %q, want it to mention %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestAuthNetworkConstraintsValidate(t *testing.T) {
+	valid := AuthPayload{APIKey: "k", NetworkConstraints: &NetworkConstraints{AllowedPorts: []int{1, 443, 65535}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for _, port := range []int{0, -1, 65536} {
+		p := AuthPayload{APIKey: "k", NetworkConstraints: &NetworkConstraints{AllowedPorts: []int{port}}}
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate() accepted port %d", port)
+		}
+	}
+}
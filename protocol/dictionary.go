@@ -0,0 +1,143 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DictionaryVersion is the version of DefaultDictionary. The hub announces the
+// version it will use in AuthAckPayload.DictionaryVersion; zero means no
+// dictionary. The contents of a version never change, so both sides compress
+// against the same bytes.
+const DictionaryVersion = 1
+
+// defaultDictionary is a preset flate dictionary of strings common in protocol
+// messages. Small messages such as a single heartbeat have too little
+// repetition of their own to compress well; with the dictionary, field names
+// and status values cost a back-reference instead of their full length.
+//
+// flate favours matches near the end of the dictionary, so the most frequent
+// tokens come last.
+var defaultDictionary = []byte(strings.Join([]string{
+	`"type":"discovery_task"`, `"type":"discovery_result"`, `"type":"update_available"`,
+	`"type":"state_report"`, `"type":"agent_info"`, `"type":"cert_info"`,
+	`"type":"step_result"`, `"type":"log"`, `"type":"task_cancel"`,
+	`"type":"auth_ack"`, `"type":"auth"`, `"type":"error"`,
+	`"config_revision":`, `"maintenance_windows":`, `"result_cache_ttl_ms":`,
+	`"cert_expiry_days":`, `"cert_issuer":"`, `"down_reason":{"category":"`,
+	`"timing":{"dns_ms":`, `"connect_ms":`, `"tls_ms":`, `"ttfb_ms":`, `"total_ms":`,
+	`"assertions":[{"name":"`, `"passed":`, `"expected":"`, `"actual":"`,
+	`"interval":`, `"timeout":`, `"target":"https://`, `"target":"`,
+	`"type":"http"`, `"type":"tcp"`, `"type":"ping"`, `"type":"dns"`, `"type":"tls"`,
+	`"type":"task"`, `"type":"heartbeat_batch"`, `"type":"cached_result"`,
+	`"traceparent":"00-`, `"partition_key":"`, `"prev_digest":"`, `"seq":`,
+	`"metadata":{`, `"error_message":"`, `"expected":true`,
+	`"status":"timeout"`, `"status":"error"`, `"status":"down"`,
+	`"latency_ms":`, `"status":"up"`, `"monitor_id":"`,
+	`"type":"ping"}`, `"type":"pong"}`,
+	`{"type":"heartbeat","payload":{"monitor_id":"`, `"timestamp":"`,
+}, ","))
+
+// DefaultDictionary is the preset dictionary of version DictionaryVersion,
+// for use with flate directly. It is a copy: the package compresses against
+// its own, so changing it cannot break compatibility with peers.
+var DefaultDictionary = bytes.Clone(defaultDictionary)
+
+// Dictionary returns a copy of the preset dictionary for a version, for use
+// with flate directly. Version zero means no dictionary and returns nil.
+func Dictionary(version int) ([]byte, error) {
+	dict, err := dictionary(version)
+	return bytes.Clone(dict), err
+}
+
+// dictionary is Dictionary without the copy, for callers that only hand the
+// dictionary to flate, which never modifies it.
+func dictionary(version int) ([]byte, error) {
+	switch version {
+	case 0:
+		return nil, nil
+	case DictionaryVersion:
+		return defaultDictionary, nil
+	}
+	return nil, fmt.Errorf("compression: unknown dictionary version %d", version)
+}
+
+// CompressWithDictionary compresses one message's bytes against the
+// dictionary of the given version.
+func CompressWithDictionary(data []byte, version int) ([]byte, error) {
+	dict, err := dictionary(version)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, flate.BestCompression, dict)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MaxDecompressedSize is the most DecompressWithDictionary will inflate, and
+// the largest message SessionCompressor.ReadMessage accepts, so a small
+// compressed payload from a peer cannot expand without bound.
+const MaxDecompressedSize = 16 << 20
+
+// ErrDecompressedTooLarge is returned when data inflates to more than
+// MaxDecompressedSize bytes.
+var ErrDecompressedTooLarge = errors.New("compression: decompressed data too large")
+
+// DecompressWithDictionary reverses CompressWithDictionary. The version must
+// be the one the data was compressed with. Data inflating to more than
+// MaxDecompressedSize fails with ErrDecompressedTooLarge.
+func DecompressWithDictionary(data []byte, version int) ([]byte, error) {
+	return DecompressWithDictionaryBudget(data, version, nil)
+}
+
+// DecompressWithDictionaryBudget is DecompressWithDictionary with the
+// inflated bytes charged against b while they are decoded, so data that would
+// take the connection over its budget fails with an error wrapping
+// ErrBudgetExceeded. The charge is released before it returns; holding on to
+// the result is the caller's to account for.
+func DecompressWithDictionaryBudget(data []byte, version int, b *ConnectionBudget) ([]byte, error) {
+	dict, err := dictionary(version)
+	if err != nil {
+		return nil, err
+	}
+	r := flate.NewReaderDict(bytes.NewReader(data), dict)
+	defer r.Close()
+	out := chargedBuffer{budget: b}
+	defer func() { b.Release(out.charged) }()
+	if _, err := io.Copy(&out, io.LimitReader(r, MaxDecompressedSize+1)); err != nil {
+		return nil, err
+	}
+	if out.buf.Len() > MaxDecompressedSize {
+		return nil, ErrDecompressedTooLarge
+	}
+	return out.buf.Bytes(), nil
+}
+
+// chargedBuffer is a buffer that charges everything written to it against a
+// budget.
+type chargedBuffer struct {
+	buf     bytes.Buffer
+	budget  *ConnectionBudget
+	charged int
+}
+
+func (w *chargedBuffer) Write(p []byte) (int, error) {
+	if err := w.budget.Charge(len(p)); err != nil {
+		return 0, fmt.Errorf("compression: %w", err)
+	}
+	w.charged += len(p)
+	return w.buf.Write(p)
+}
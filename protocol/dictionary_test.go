@@ -0,0 +1,153 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDictionary(t *testing.T) {
+	dict, err := Dictionary(DictionaryVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dict) == 0 {
+		t.Fatal("current dictionary is empty")
+	}
+	if !bytes.Equal(dict, DefaultDictionary) {
+		t.Error("Dictionary(DictionaryVersion) differs from DefaultDictionary")
+	}
+	dict[0] ^= 0xff
+	again, _ := Dictionary(DictionaryVersion)
+	if again[0] == dict[0] {
+		t.Error("modifying the returned dictionary changed the preset")
+	}
+
+	if none, err := Dictionary(0); none != nil || err != nil {
+		t.Errorf("Dictionary(0) = %q, %v, want nil, nil", none, err)
+	}
+	if _, err := Dictionary(DictionaryVersion + 1); err == nil {
+		t.Error("Dictionary() accepted an unknown version")
+	}
+}
+
+func TestCompressWithDictionaryRoundTrip(t *testing.T) {
+	msgs := []*Message{
+		NewHeartbeatMessage("3f6c2a9e-0001-4f1e-9c1d-7a0b5e2c8d41", "up", 23, ""),
+		NewHeartbeatMessage("3f6c2a9e-0002-4f1e-9c1d-7a0b5e2c8d41", "down", 0, "connection refused"),
+		NewTaskMessage("mon-1", "http", "https://example.com/health", 30, 5),
+		NewPingMessage(),
+	}
+	for _, version := range []int{0, DictionaryVersion} {
+		for _, m := range msgs {
+			data, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compressed, err := CompressWithDictionary(data, version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecompressWithDictionary(compressed, version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("version %d: round trip = %s, want %s", version, got, data)
+			}
+		}
+	}
+
+	data, _ := json.Marshal(msgs[0])
+	compressed, _ := CompressWithDictionary(data, DictionaryVersion)
+	if got, err := DecompressWithDictionary(compressed, 0); err == nil && bytes.Equal(got, data) {
+		t.Error("data decompressed without the dictionary it was compressed with")
+	}
+	if _, err := CompressWithDictionary(data, 99); err == nil {
+		t.Error("CompressWithDictionary() accepted an unknown version")
+	}
+}
+
+func TestDecompressWithDictionaryBudget(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"monitor_id":"mon-1","status":"up"}`), 1000)
+	compressed, err := CompressWithDictionary(data, DictionaryVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewConnectionBudget(len(data))
+	if got, err := DecompressWithDictionaryBudget(compressed, DictionaryVersion, b); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("DecompressWithDictionaryBudget() within budget = %d bytes, %v", len(got), err)
+	}
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after decoding", b.Used())
+	}
+	b = NewConnectionBudget(len(data) / 2)
+	if _, err := DecompressWithDictionaryBudget(compressed, DictionaryVersion, b); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("DecompressWithDictionaryBudget() over budget = %v, want ErrBudgetExceeded", err)
+	}
+	if b.Used() != 0 {
+		t.Errorf("%d bytes still charged after a failed decode", b.Used())
+	}
+}
+
+func TestDictionaryShrinksHeartbeats(t *testing.T) {
+	data, _ := json.Marshal(NewHeartbeatMessage("3f6c2a9e-0001-4f1e-9c1d-7a0b5e2c8d41", "up", 23, ""))
+	plain, _ := CompressWithDictionary(data, 0)
+	withDict, _ := CompressWithDictionary(data, DictionaryVersion)
+	if len(withDict) >= len(plain) {
+		t.Errorf("dictionary did not help: %d bytes with, %d without", len(withDict), len(plain))
+	}
+}
+
+func TestAuthAckDictionaryVersion(t *testing.T) {
+	ack := AuthAckPayload{AgentID: "a", DictionaryVersion: DictionaryVersion}
+	if err := ack.Validate(); err != nil {
+		t.Error(err)
+	}
+	ack.DictionaryVersion = 42
+	if err := ack.Validate(); err == nil {
+		t.Error("Validate() accepted an unknown dictionary version")
+	}
+}
+
+// BenchmarkDictionaryCompression compares compressing each message on its own
+// against the preset dictionary with plain flate.
+func BenchmarkDictionaryCompression(b *testing.B) {
+	msgs := sessionBacklog()
+	encoded := make([][]byte, len(msgs))
+	for i, m := range msgs {
+		encoded[i], _ = json.Marshal(m)
+	}
+
+	b.Run("dictionary", func(b *testing.B) {
+		var n int
+		for b.Loop() {
+			n = 0
+			for _, data := range encoded {
+				c, err := CompressWithDictionary(data, DictionaryVersion)
+				if err != nil {
+					b.Fatal(err)
+				}
+				n += len(c)
+			}
+		}
+		b.ReportMetric(float64(n)/float64(len(msgs)), "bytes/msg")
+	})
+
+	b.Run("plain", func(b *testing.B) {
+		var n int
+		for b.Loop() {
+			n = 0
+			for _, data := range encoded {
+				var buf bytes.Buffer
+				w, _ := flate.NewWriter(&buf, flate.BestCompression)
+				w.Write(data)
+				w.Close()
+				n += buf.Len()
+			}
+		}
+		b.ReportMetric(float64(n)/float64(len(msgs)), "bytes/msg")
+	})
+}
@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// MessageDigest returns the SHA-256 of the message's JSON encoding in hex,
+// including its PrevDigest, so digests chain.
+func MessageDigest(m *Message) (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DigestChain links the messages of a session into a hash chain for
+// tamper-evident audit logs: every message carries the digest of the one
+// before it, so inserting, removing, reordering or altering any message
+// breaks the chain from that point on. It is safe for concurrent use.
+type DigestChain struct {
+	mu   sync.Mutex
+	last string
+}
+
+// Append stamps m with the digest of the previously appended message and
+// makes m the new end of the chain. m must not change afterwards.
+func (c *DigestChain) Append(m *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m.PrevDigest = c.last
+	digest, err := MessageDigest(m)
+	if err != nil {
+		return fmt.Errorf("digest chain: %w", err)
+	}
+	c.last = digest
+	return nil
+}
+
+// Verify checks that msgs form an unbroken chain continuing from the chain's
+// head, and reports the first message that does not link to its predecessor.
+// On success the last message becomes the new head, so a receiver verifies a
+// session batch by batch with its own DigestChain, starting from an empty one
+// at the first message of the session. On failure the head is unchanged.
+// Truncation after the last message cannot be detected from the messages
+// alone; compare the last digest with one stored elsewhere for that.
+func (c *DigestChain) Verify(msgs []*Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.last
+	for i, m := range msgs {
+		if m.PrevDigest != prev {
+			if i == 0 {
+				return fmt.Errorf("digest chain: message 0 does not follow the chain head %q", c.last)
+			}
+			return fmt.Errorf("digest chain: message %d does not follow message %d", i, i-1)
+		}
+		digest, err := MessageDigest(m)
+		if err != nil {
+			return fmt.Errorf("digest chain: message %d: %w", i, err)
+		}
+		prev = digest
+	}
+	c.last = prev
+	return nil
+}
+
+// Last returns the digest of the most recently appended message.
+func (c *DigestChain) Last() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
@@ -0,0 +1,145 @@
+package protocol
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func buildChain(t *testing.T, n int) (*DigestChain, []*Message) {
+	t.Helper()
+	var c DigestChain
+	msgs := make([]*Message, n)
+	for i := range msgs {
+		msgs[i] = NewHeartbeatMessage("mon-1", "up", i, "")
+		if err := c.Append(msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &c, msgs
+}
+
+func TestDigestChain(t *testing.T) {
+	c, msgs := buildChain(t, 5)
+	if msgs[0].PrevDigest != "" {
+		t.Errorf("first message PrevDigest = %q, want empty", msgs[0].PrevDigest)
+	}
+	for i := 1; i < len(msgs); i++ {
+		want, err := MessageDigest(msgs[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msgs[i].PrevDigest != want {
+			t.Errorf("message %d does not link to its predecessor", i)
+		}
+	}
+	last, _ := MessageDigest(msgs[4])
+	if c.Last() != last {
+		t.Errorf("Last() = %q, want %q", c.Last(), last)
+	}
+	var receiver DigestChain
+	if err := receiver.Verify(msgs); err != nil {
+		t.Errorf("Verify() = %v on an intact chain", err)
+	}
+	if receiver.Last() != last {
+		t.Errorf("Verify() left the head at %q, want %q", receiver.Last(), last)
+	}
+	if err := receiver.Verify(nil); err != nil || receiver.Last() != last {
+		t.Errorf("Verify(nil) = %v, head %q", err, receiver.Last())
+	}
+}
+
+func TestDigestChainVerifyBatches(t *testing.T) {
+	_, msgs := buildChain(t, 6)
+	var receiver DigestChain
+	if err := receiver.Verify(msgs[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := receiver.Verify(msgs[2:]); err != nil {
+		t.Errorf("Verify() of the next batch = %v", err)
+	}
+
+	// A batch that skips ahead of the head fails on its first message and
+	// leaves the head where it was.
+	var skipped DigestChain
+	if err := skipped.Verify(msgs[:2]); err != nil {
+		t.Fatal(err)
+	}
+	head := skipped.Last()
+	err := skipped.Verify(msgs[3:])
+	if err == nil || !strings.Contains(err.Error(), "chain head") {
+		t.Errorf("Verify() = %v, want a chain head error", err)
+	}
+	if skipped.Last() != head {
+		t.Error("failed Verify() moved the head")
+	}
+	if err := skipped.Verify(msgs[2:]); err != nil {
+		t.Errorf("Verify() after a failure = %v", err)
+	}
+}
+
+func TestDigestChainSurvivesWire(t *testing.T) {
+	_, msgs := buildChain(t, 3)
+	received := make([]*Message, len(msgs))
+	for i, m := range msgs {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received[i] = new(Message)
+		if err := json.Unmarshal(data, received[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var receiver DigestChain
+	if err := receiver.Verify(received); err != nil {
+		t.Errorf("Verify() after a JSON round trip = %v", err)
+	}
+}
+
+func TestDigestChainTamper(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]*Message) []*Message
+	}{
+		{"altered payload", func(m []*Message) []*Message {
+			m[1].Payload = NewHeartbeatMessage("mon-1", "down", 1, "").Payload
+			return m
+		}},
+		{"altered envelope", func(m []*Message) []*Message {
+			m[2].Timestamp = m[2].Timestamp.Add(1)
+			return m
+		}},
+		{"reordered", func(m []*Message) []*Message {
+			m[1], m[2] = m[2], m[1]
+			return m
+		}},
+		{"removed", func(m []*Message) []*Message {
+			return append(m[:1], m[2:]...)
+		}},
+		{"inserted", func(m []*Message) []*Message {
+			forged := NewHeartbeatMessage("mon-1", "up", 99, "")
+			forged.PrevDigest = m[1].PrevDigest
+			return append(m[:2], append([]*Message{forged}, m[2:]...)...)
+		}},
+		{"first message dropped", func(m []*Message) []*Message {
+			return m[1:]
+		}},
+		{"forged link", func(m []*Message) []*Message {
+			// Rewriting one message and fixing up its successor's link
+			// still breaks the link after that.
+			m[1].Payload = NewHeartbeatMessage("mon-1", "down", 1, "").Payload
+			m[2].PrevDigest, _ = MessageDigest(m[1])
+			return m
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, msgs := buildChain(t, 5)
+			var receiver DigestChain
+			if err := receiver.Verify(tt.tamper(msgs)); err == nil {
+				t.Error("Verify() accepted a tampered chain")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// Failure categories for DownReason.
+const (
+	DownCategoryDNS          = "dns"
+	DownCategoryConnect      = "connect"
+	DownCategoryTLS          = "tls"
+	DownCategoryTimeout      = "timeout"
+	DownCategoryHTTPStatus   = "http_status"
+	DownCategoryBodyMismatch = "body_mismatch"
+	DownCategoryUnknown      = "unknown"
+)
+
+// Errors agents wrap when a check completes but its result is wrong, so
+// CategorizeFailure can tell them apart from transport failures.
+var (
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+	ErrBodyMismatch     = errors.New("response body did not match")
+)
+
+// DownReason explains in structured form why a check failed.
+type DownReason struct {
+	Category string `json:"category"`
+	Detail   string `json:"detail,omitempty"`
+	RawError string `json:"raw_error,omitempty"`
+}
+
+// Validate reports whether the category is one of the known ones.
+func (r DownReason) Validate() error {
+	switch r.Category {
+	case DownCategoryDNS, DownCategoryConnect, DownCategoryTLS, DownCategoryTimeout,
+		DownCategoryHTTPStatus, DownCategoryBodyMismatch, DownCategoryUnknown:
+		return nil
+	}
+	return fmt.Errorf("down_reason: unknown category %q", r.Category)
+}
+
+// NewDownReason builds the down reason for a failed check. A nil error, which
+// should not happen for a failed check, yields DownCategoryUnknown.
+func NewDownReason(monitorType MonitorType, err error, detail string) DownReason {
+	if err == nil {
+		return DownReason{Category: DownCategoryUnknown, Detail: detail}
+	}
+	return DownReason{
+		Category: CategorizeFailure(monitorType, err),
+		Detail:   detail,
+		RawError: err.Error(),
+	}
+}
+
+// CategorizeFailure maps a check error to a failure category. Timeouts are
+// recognized first, then errors whose type identifies the failing phase, and
+// finally the monitor type decides for checks that only exercise one phase.
+// Anything else is DownCategoryUnknown.
+func CategorizeFailure(monitorType MonitorType, err error) string {
+	if err == nil {
+		return ""
+	}
+	if isTimeout(err) {
+		return DownCategoryTimeout
+	}
+	if monitorType == MonitorDNS {
+		return DownCategoryDNS
+	}
+
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, ErrUnexpectedStatus):
+		return DownCategoryHTTPStatus
+	case errors.Is(err, ErrBodyMismatch):
+		return DownCategoryBodyMismatch
+	case errors.As(err, &dnsErr):
+		return DownCategoryDNS
+	case isTLSError(err):
+		return DownCategoryTLS
+	case isConnectError(err):
+		return DownCategoryConnect
+	}
+
+	switch monitorType {
+	case MonitorTLS:
+		return DownCategoryTLS
+	case MonitorTCP, MonitorPing, MonitorPortScan:
+		return DownCategoryConnect
+	}
+	return DownCategoryUnknown
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	return errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+func isConnectError(err error) bool {
+	if isConnectErrno(err) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
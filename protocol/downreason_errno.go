@@ -0,0 +1,15 @@
+//go:build !plan9
+
+package protocol
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isConnectErrno reports whether err carries an errno for a refused, reset or
+// unreachable connection.
+func isConnectErrno(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH)
+}
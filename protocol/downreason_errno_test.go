@@ -0,0 +1,18 @@
+//go:build !plan9
+
+package protocol
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+func TestCategorizeFailureErrno(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.EHOSTUNREACH, syscall.ENETUNREACH} {
+		err := fmt.Errorf("read: %w", errno)
+		if got := CategorizeFailure(MonitorHTTP, err); got != DownCategoryConnect {
+			t.Errorf("CategorizeFailure(%v) = %q, want %q", errno, got, DownCategoryConnect)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package protocol
+
+// isConnectErrno always reports false on Plan 9, which has no errno values;
+// failed dials are still recognized by their *net.OpError.
+func isConnectErrno(err error) bool {
+	return false
+}
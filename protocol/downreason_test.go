@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestCategorizeFailure(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("no route")}
+	tests := []struct {
+		name        string
+		monitorType MonitorType
+		err         error
+		want        string
+	}{
+		{"nil", MonitorHTTP, nil, ""},
+		{"context deadline", MonitorHTTP, context.DeadlineExceeded, DownCategoryTimeout},
+		{"wrapped deadline", MonitorHTTP, fmt.Errorf("get: %w", os.ErrDeadlineExceeded), DownCategoryTimeout},
+		{"dns monitor", MonitorDNS, errors.New("servfail"), DownCategoryDNS},
+		{"dns error", MonitorHTTP, &net.DNSError{Err: "no such host", Name: "example.invalid"}, DownCategoryDNS},
+		{"unexpected status", MonitorHTTP, fmt.Errorf("%w: 503", ErrUnexpectedStatus), DownCategoryHTTPStatus},
+		{"body mismatch", MonitorHTTP, ErrBodyMismatch, DownCategoryBodyMismatch},
+		{"unknown authority", MonitorHTTP, x509.UnknownAuthorityError{}, DownCategoryTLS},
+		{"dial op error", MonitorHTTP, dialErr, DownCategoryConnect},
+		{"tls monitor fallback", MonitorTLS, errors.New("boom"), DownCategoryTLS},
+		{"tcp monitor fallback", MonitorTCP, errors.New("boom"), DownCategoryConnect},
+		{"unknown", MonitorHTTP, errors.New("boom"), DownCategoryUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CategorizeFailure(tt.monitorType, tt.err); got != tt.want {
+				t.Errorf("CategorizeFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDownReason(t *testing.T) {
+	r := NewDownReason(MonitorHTTP, fmt.Errorf("%w: 502", ErrUnexpectedStatus), "got 502")
+	want := DownReason{Category: DownCategoryHTTPStatus, Detail: "got 502", RawError: "unexpected status code: 502"}
+	if r != want {
+		t.Errorf("NewDownReason() = %+v, want %+v", r, want)
+	}
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	r = NewDownReason(MonitorHTTP, nil, "no error")
+	if r.Category != DownCategoryUnknown || r.RawError != "" {
+		t.Errorf("NewDownReason(nil) = %+v, want unknown category", r)
+	}
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDownReasonValidate(t *testing.T) {
+	if err := (DownReason{Category: "gremlins"}).Validate(); err == nil {
+		t.Error("Validate() accepted an unknown category")
+	}
+	if err := (DownReason{}).Validate(); err == nil {
+		t.Error("Validate() accepted an empty category")
+	}
+}
@@ -0,0 +1,107 @@
+package protocol
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LatencyAggregation picks which latency statistic a downsampled heartbeat
+// reports as its LatencyMs.
+type LatencyAggregation int
+
+// Latency aggregations.
+const (
+	LatencyAvg LatencyAggregation = iota
+	LatencyMin
+	LatencyMax
+)
+
+// Downsampler folds frequent heartbeats for one monitor into one heartbeat
+// per window, for agents that check more often than the hub needs. The
+// aggregate carries the worst status seen, with that check's error, and the
+// chosen latency statistic; min, max and average latency and the sample count
+// go into its metadata. It is safe for concurrent use.
+type Downsampler struct {
+	window time.Duration
+	agg    LatencyAggregation
+
+	mu      sync.Mutex
+	start   time.Time
+	worst   HeartbeatPayload
+	count   int
+	sum     int
+	minimum int
+	maximum int
+}
+
+// NewDownsampler creates a downsampler emitting one heartbeat per window.
+func NewDownsampler(window time.Duration, agg LatencyAggregation) *Downsampler {
+	return &Downsampler{window: window, agg: agg}
+}
+
+// Add folds in a heartbeat.
+func (d *Downsampler) Add(hb HeartbeatPayload) {
+	d.AddAt(hb, time.Now())
+}
+
+// AddAt is Add with an explicit clock reading, which starts the window if it
+// is the first heartbeat since the last flush.
+func (d *Downsampler) AddAt(hb HeartbeatPayload, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.count == 0 {
+		d.start = now
+		d.worst = hb
+		d.minimum, d.maximum = hb.LatencyMs, hb.LatencyMs
+	} else if MonitorStatus(hb.Status).severity() >= MonitorStatus(d.worst.Status).severity() {
+		// Ties go to the later heartbeat so the reported error is current.
+		d.worst = hb
+	}
+	d.count++
+	d.sum += hb.LatencyMs
+	d.minimum = min(d.minimum, hb.LatencyMs)
+	d.maximum = max(d.maximum, hb.LatencyMs)
+}
+
+// Due reports whether the current window has ended and should be flushed.
+func (d *Downsampler) Due(now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count > 0 && now.Sub(d.start) >= d.window
+}
+
+// Flush returns the aggregate of the heartbeats added since the last flush
+// and starts a new window. It returns nil if nothing was added.
+func (d *Downsampler) Flush() *HeartbeatPayload {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.count == 0 {
+		return nil
+	}
+
+	avg := d.sum / d.count
+	hb := d.worst
+	switch d.agg {
+	case LatencyMin:
+		hb.LatencyMs = d.minimum
+	case LatencyMax:
+		hb.LatencyMs = d.maximum
+	default:
+		hb.LatencyMs = avg
+	}
+	metadata := make(map[string]string, len(hb.Metadata)+4)
+	for k, v := range hb.Metadata {
+		metadata[k] = v
+	}
+	metadata["samples"] = strconv.Itoa(d.count)
+	metadata["latency_min_ms"] = strconv.Itoa(d.minimum)
+	metadata["latency_max_ms"] = strconv.Itoa(d.maximum)
+	metadata["latency_avg_ms"] = strconv.Itoa(avg)
+	hb.Metadata = metadata
+
+	d.count, d.sum = 0, 0
+	return &hb
+}
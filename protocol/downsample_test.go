@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestDownsampler(t *testing.T) {
+	hbs := []HeartbeatPayload{
+		{MonitorID: "m", Status: "up", LatencyMs: 20},
+		{MonitorID: "m", Status: "timeout", LatencyMs: 5000, ErrorMessage: "deadline"},
+		{MonitorID: "m", Status: "up", LatencyMs: 10, Metadata: map[string]string{"region": "eu"}},
+		{MonitorID: "m", Status: "up", LatencyMs: 30},
+	}
+	tests := []struct {
+		name        string
+		agg         LatencyAggregation
+		wantLatency int
+	}{
+		{"avg", LatencyAvg, 1265},
+		{"min", LatencyMin, 10},
+		{"max", LatencyMax, 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDownsampler(time.Minute, tt.agg)
+			start := time.Unix(1_700_000_000, 0)
+			for i, hb := range hbs {
+				d.AddAt(hb, start.Add(time.Duration(i)*10*time.Second))
+			}
+			got := d.Flush()
+			if got == nil {
+				t.Fatal("Flush() = nil")
+			}
+			if got.Status != "timeout" || got.ErrorMessage != "deadline" {
+				t.Errorf("status %q error %q, want the worst heartbeat's", got.Status, got.ErrorMessage)
+			}
+			if got.LatencyMs != tt.wantLatency {
+				t.Errorf("LatencyMs = %d, want %d", got.LatencyMs, tt.wantLatency)
+			}
+			want := map[string]string{"samples": "4", "latency_min_ms": "10", "latency_max_ms": "5000", "latency_avg_ms": "1265"}
+			if !maps.Equal(got.Metadata, want) {
+				t.Errorf("Metadata = %v, want %v", got.Metadata, want)
+			}
+			if d.Flush() != nil {
+				t.Error("second Flush() returned a heartbeat")
+			}
+		})
+	}
+}
+
+func TestDownsamplerTiesGoToLatest(t *testing.T) {
+	d := NewDownsampler(time.Minute, LatencyAvg)
+	d.Add(HeartbeatPayload{MonitorID: "m", Status: "down", ErrorMessage: "first"})
+	d.Add(HeartbeatPayload{MonitorID: "m", Status: "down", ErrorMessage: "second"})
+	if got := d.Flush(); got.ErrorMessage != "second" {
+		t.Errorf("ErrorMessage = %q, want the latest", got.ErrorMessage)
+	}
+}
+
+func TestDownsamplerMetadataNotShared(t *testing.T) {
+	d := NewDownsampler(time.Minute, LatencyAvg)
+	meta := map[string]string{"region": "eu"}
+	d.Add(HeartbeatPayload{MonitorID: "m", Status: "up", Metadata: meta})
+	got := d.Flush()
+	if got.Metadata["region"] != "eu" {
+		t.Errorf("Metadata = %v, want the heartbeat's own keys kept", got.Metadata)
+	}
+	if len(meta) != 1 {
+		t.Errorf("Flush() modified the input metadata: %v", meta)
+	}
+}
+
+func TestDownsamplerDue(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	d := NewDownsampler(time.Minute, LatencyAvg)
+	if d.Due(start) {
+		t.Error("empty downsampler is due")
+	}
+	d.AddAt(HeartbeatPayload{MonitorID: "m", Status: "up"}, start)
+	if d.Due(start.Add(59 * time.Second)) {
+		t.Error("due before the window ended")
+	}
+	if !d.Due(start.Add(time.Minute)) {
+		t.Error("not due when the window ended")
+	}
+	d.Flush()
+	d.AddAt(HeartbeatPayload{MonitorID: "m", Status: "up"}, start.Add(90*time.Second))
+	if d.Due(start.Add(2 * time.Minute)) {
+		t.Error("new window did not restart at the first heartbeat after the flush")
+	}
+}
@@ -0,0 +1,134 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DrainPayload is sent by hub to ask the agent to shut down cleanly: stop
+// accepting tasks, finish in-flight checks, flush its send queue, confirm
+// with a drained message and disconnect, all within TimeoutMs.
+type DrainPayload struct {
+	TimeoutMs int `json:"timeout_ms"`
+}
+
+// Validate reports whether the drain has a positive timeout.
+func (p DrainPayload) Validate() error {
+	if p.TimeoutMs <= 0 {
+		return fmt.Errorf("drain: timeout_ms must be positive, got %d", p.TimeoutMs)
+	}
+	return nil
+}
+
+// DrainedPayload is sent by agent once it has flushed everything it held.
+// Flushed is the number of buffered messages sent while draining.
+type DrainedPayload struct {
+	Flushed int `json:"flushed,omitempty"`
+}
+
+// NewDrainMessage creates a drain request.
+func NewDrainMessage(timeoutMs int) *Message {
+	return MustNewMessage(MsgTypeDrain, DrainPayload{
+		TimeoutMs: timeoutMs,
+	})
+}
+
+// NewDrainedMessage creates the confirmation that draining finished.
+func NewDrainedMessage(flushed int) *Message {
+	return MustNewMessage(MsgTypeDrained, DrainedPayload{
+		Flushed: flushed,
+	})
+}
+
+// DrainState is the progress of a drain.
+type DrainState int
+
+// Drain states.
+const (
+	DrainIdle DrainState = iota
+	DrainDraining
+	DrainDrained
+	DrainTimedOut
+)
+
+func (s DrainState) String() string {
+	switch s {
+	case DrainIdle:
+		return "idle"
+	case DrainDraining:
+		return "draining"
+	case DrainDrained:
+		return "drained"
+	case DrainTimedOut:
+		return "timed_out"
+	}
+	return fmt.Sprintf("DrainState(%d)", int(s))
+}
+
+// DrainCoordinator tracks one side's view of a drain. The zero value is idle
+// and accepting tasks. It is safe for concurrent use.
+type DrainCoordinator struct {
+	mu       sync.Mutex
+	state    DrainState
+	deadline time.Time
+}
+
+// Begin starts draining with the timeout from p. It fails unless the
+// coordinator is idle.
+func (c *DrainCoordinator) Begin(p DrainPayload, now time.Time) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != DrainIdle {
+		return fmt.Errorf("drain: already %s", c.state)
+	}
+	c.state = DrainDraining
+	c.deadline = now.Add(time.Duration(p.TimeoutMs) * time.Millisecond)
+	return nil
+}
+
+// Complete records that everything was flushed, on the agent once its queue
+// is empty or on the hub when the drained message arrives.
+func (c *DrainCoordinator) Complete() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != DrainDraining {
+		return errors.New("drain: complete without a drain in progress")
+	}
+	c.state = DrainDrained
+	return nil
+}
+
+// Check returns the state at now, moving a drain past its deadline to
+// DrainTimedOut. Once timed out, the connection should simply be closed.
+func (c *DrainCoordinator) Check(now time.Time) DrainState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == DrainDraining && !now.Before(c.deadline) {
+		c.state = DrainTimedOut
+	}
+	return c.state
+}
+
+// Accepting reports whether new tasks may still be taken on, which is only
+// the case before a drain begins.
+func (c *DrainCoordinator) Accepting() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state == DrainIdle
+}
+
+// Deadline returns when the current drain times out, or the zero time when
+// no drain has begun.
+func (c *DrainCoordinator) Deadline() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline
+}
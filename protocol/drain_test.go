@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrainCoordinator(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name      string
+		complete  bool
+		checkAt   time.Duration
+		wantState DrainState
+	}{
+		{"in progress", false, 5 * time.Second, DrainDraining},
+		{"completed", true, 5 * time.Second, DrainDrained},
+		{"timed out", false, 10 * time.Second, DrainTimedOut},
+		{"completed before deadline stays drained", true, time.Minute, DrainDrained},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c DrainCoordinator
+			if !c.Accepting() || c.Check(start) != DrainIdle || !c.Deadline().IsZero() {
+				t.Fatal("zero coordinator is not idle")
+			}
+			if err := c.Begin(DrainPayload{TimeoutMs: 10000}, start); err != nil {
+				t.Fatal(err)
+			}
+			if c.Accepting() {
+				t.Error("still accepting tasks while draining")
+			}
+			if want := start.Add(10 * time.Second); !c.Deadline().Equal(want) {
+				t.Errorf("Deadline() = %v, want %v", c.Deadline(), want)
+			}
+			if tt.complete {
+				if err := c.Complete(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := c.Check(start.Add(tt.checkAt)); got != tt.wantState {
+				t.Errorf("Check() = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestDrainCoordinatorErrors(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	var c DrainCoordinator
+	if err := c.Complete(); err == nil {
+		t.Error("Complete() succeeded without a drain")
+	}
+	if err := c.Begin(DrainPayload{}, now); err == nil {
+		t.Error("Begin() accepted a zero timeout")
+	}
+	if err := c.Begin(DrainPayload{TimeoutMs: 1000}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Begin(DrainPayload{TimeoutMs: 1000}, now); err == nil {
+		t.Error("Begin() succeeded twice")
+	}
+	c.Check(now.Add(time.Second))
+	if err := c.Complete(); err == nil {
+		t.Error("Complete() succeeded after the drain timed out")
+	}
+}
+
+func TestDrainMessages(t *testing.T) {
+	if err := NewDrainMessage(5000).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := NewDrainMessage(0).Validate(); err == nil {
+		t.Error("drain with zero timeout accepted")
+	}
+	var p DrainedPayload
+	if err := NewDrainedMessage(12).ParsePayload(&p); err != nil || p.Flushed != 12 {
+		t.Errorf("drained round trip = %+v, %v", p, err)
+	}
+}
+
+func TestDrainStateString(t *testing.T) {
+	for state, want := range map[DrainState]string{
+		DrainIdle: "idle", DrainDraining: "draining", DrainDrained: "drained", DrainTimedOut: "timed_out", 9: "DrainState(9)",
+	} {
+		if got := state.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
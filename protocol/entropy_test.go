@@ -0,0 +1,51 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPayloadEntropy(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.Read(random)
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	tests := []struct {
+		name     string
+		payload  []byte
+		min, max float64
+	}{
+		{"empty", nil, 0, 0},
+		{"uniform", []byte(strings.Repeat("a", 100)), 0, 0},
+		{"two symbols", []byte("abababab"), 1, 1},
+		{"every byte once", all, 8, 8},
+		{"random", random, 7.9, 8},
+		{"heartbeat json", NewHeartbeatMessage("7f3c2a10-9d1e-4b7a-8c55-0e2f1a6b9c3d", "up", 42, "").Payload, 3.5, 5.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PayloadEntropy(&Message{Payload: json.RawMessage(tt.payload)})
+			if got < tt.min-1e-9 || got > tt.max+1e-9 || math.IsNaN(got) {
+				t.Errorf("PayloadEntropy() = %v, want in [%v, %v]", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestIsAnomalous(t *testing.T) {
+	random := make([]byte, 1024)
+	rand.Read(random)
+	if !IsAnomalous(&Message{Payload: random}, DefaultEntropyThreshold) {
+		t.Error("random payload not flagged")
+	}
+	task := NewTaskMessageWithMetadata("mon-1", "http", "https://example.com/health", 30, 5,
+		map[string]string{"expected_content": "ok", "method": "GET"})
+	if IsAnomalous(task, DefaultEntropyThreshold) {
+		t.Errorf("task payload flagged at %.2f bits per byte", PayloadEntropy(task))
+	}
+}
@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// PayloadEqual reports whether two messages have the same type and carry
+// semantically equal payloads. Field order does not matter, and a field that
+// is omitted compares equal to one set to its zero value, as do empty and
+// absent lists and maps. Payloads of known types are decoded to their
+// concrete type first, so fields the type does not define are ignored;
+// payloads of unknown types are compared as generic JSON.
+func PayloadEqual(a, b *Message) (bool, error) {
+	if a == nil || b == nil {
+		return false, errors.New("payload equal: nil message")
+	}
+	if a.Type != b.Type {
+		return false, nil
+	}
+	na, err := normalizedPayload(a)
+	if err != nil {
+		return false, err
+	}
+	nb, err := normalizedPayload(b)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(na, nb), nil
+}
+
+// normalizedPayload decodes the payload to its concrete type, re-encodes it so
+// the type's own omitempty rules apply, then decodes that as generic JSON with
+// empty values stripped.
+func normalizedPayload(m *Message) (any, error) {
+	raw := []byte(m.Payload)
+	if _, known := payloadTypes[m.Type]; known {
+		p, err := m.decodePayloadOrZero()
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = json.Marshal(p); err != nil {
+			return nil, err
+		}
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, &DecodeError{MsgType: m.Type, Err: err}
+	}
+	return stripEmpty(v), nil
+}
+
+// decodePayloadOrZero is DecodePayload, treating an empty payload as the
+// zero value of the payload type.
+func (m *Message) decodePayloadOrZero() (any, error) {
+	if len(m.Payload) == 0 {
+		if proto := payloadTypes[m.Type]; proto != nil {
+			return proto, nil
+		}
+		return nil, nil
+	}
+	return m.DecodePayload()
+}
+
+// stripEmpty removes null values and empty lists and objects from decoded
+// JSON, recursively, returning nil if nothing is left.
+func stripEmpty(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			if elem = stripEmpty(elem); elem == nil {
+				delete(v, k)
+			} else {
+				v[k] = elem
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = stripEmpty(elem)
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	return v
+}
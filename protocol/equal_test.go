@@ -0,0 +1,55 @@
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPayloadEqual(t *testing.T) {
+	raw := func(typ, payload string) *Message {
+		return &Message{Type: typ, Payload: json.RawMessage(payload)}
+	}
+	tests := []struct {
+		name string
+		a, b *Message
+		want bool
+	}{
+		{"identical", NewHeartbeatMessage("m", "up", 5, ""), NewHeartbeatMessage("m", "up", 5, ""), true},
+		{"different value", NewHeartbeatMessage("m", "up", 5, ""), NewHeartbeatMessage("m", "up", 6, ""), false},
+		{"different type", NewPingMessage(), NewPongMessage(), false},
+		{"field order", raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up"}`), raw(MsgTypeHeartbeat, `{"status":"up","monitor_id":"m"}`), true},
+		{"zero value vs omitted", raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up","latency_ms":0}`), raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up"}`), true},
+		{"empty map vs absent", raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up","metadata":{}}`), raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up"}`), true},
+		{"null vs absent", raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up","timing":null}`), raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up"}`), true},
+		{"unknown field ignored", raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up","future":1}`), raw(MsgTypeHeartbeat, `{"monitor_id":"m","status":"up"}`), true},
+		{"empty payload vs zero payload", &Message{Type: MsgTypePing}, raw(MsgTypePing, `{}`), true},
+		{"unknown type compared as json", raw("custom", `{"a":1,"b":[]}`), raw("custom", `{"a":1}`), true},
+		{"unknown type differs", raw("custom", `{"a":1}`), raw("custom", `{"a":2}`), false},
+		{"map values", NewTaskMessageWithMetadata("m", "http", "t", 1, 1, map[string]string{"a": "1"}),
+			NewTaskMessageWithMetadata("m", "http", "t", 1, 1, map[string]string{"a": "2"}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PayloadEqual(tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("PayloadEqual() = %v, want %v", got, tt.want)
+			}
+			if rev, _ := PayloadEqual(tt.b, tt.a); rev != got {
+				t.Error("PayloadEqual() is not symmetric")
+			}
+		})
+	}
+}
+
+func TestPayloadEqualErrors(t *testing.T) {
+	if _, err := PayloadEqual(nil, NewPingMessage()); err == nil {
+		t.Error("PayloadEqual() accepted a nil message")
+	}
+	bad := &Message{Type: MsgTypeHeartbeat, Payload: json.RawMessage(`{"latency_ms":"slow"}`)}
+	if _, err := PayloadEqual(bad, NewHeartbeatMessage("m", "up", 1, "")); err == nil {
+		t.Error("PayloadEqual() accepted an undecodable payload")
+	}
+}
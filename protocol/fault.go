@@ -0,0 +1,142 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Faults the hub can ask an agent to simulate.
+const (
+	// FaultForceDown reports the monitor down regardless of the check result.
+	FaultForceDown = "force_down"
+	// FaultAddLatency adds LatencyMs to every check result.
+	FaultAddLatency = "add_latency"
+	// FaultDropHeartbeats suppresses the monitor's heartbeats entirely.
+	FaultDropHeartbeats = "drop_heartbeats"
+)
+
+// InjectFaultPayload is sent by hub to make the agent simulate a failure of a
+// monitor for DurationMs, for testing alerting end to end.
+type InjectFaultPayload struct {
+	MonitorID  string `json:"monitor_id"`
+	Fault      string `json:"fault"`
+	DurationMs int    `json:"duration_ms"`
+	LatencyMs  int    `json:"latency_ms,omitempty"`
+}
+
+// Validate reports whether the fault request is well formed.
+func (p InjectFaultPayload) Validate() error {
+	if p.MonitorID == "" {
+		return errors.New("inject_fault: monitor_id is required")
+	}
+	switch p.Fault {
+	case FaultForceDown, FaultDropHeartbeats:
+	case FaultAddLatency:
+		if p.LatencyMs <= 0 {
+			return fmt.Errorf("inject_fault: add_latency needs a positive latency_ms, got %d", p.LatencyMs)
+		}
+	default:
+		return fmt.Errorf("inject_fault: unknown fault %q", p.Fault)
+	}
+	if p.DurationMs <= 0 {
+		return fmt.Errorf("inject_fault: duration_ms must be positive, got %d", p.DurationMs)
+	}
+	return nil
+}
+
+// NewInjectFaultMessage creates a fault injection message. latencyMs only
+// applies to FaultAddLatency.
+func NewInjectFaultMessage(monitorID, fault string, durationMs, latencyMs int) *Message {
+	return MustNewMessage(MsgTypeInjectFault, InjectFaultPayload{
+		MonitorID:  monitorID,
+		Fault:      fault,
+		DurationMs: durationMs,
+		LatencyMs:  latencyMs,
+	})
+}
+
+type activeFault struct {
+	fault InjectFaultPayload
+	until time.Time
+}
+
+// FaultInjector applies injected faults to an agent's heartbeats. Each monitor
+// has at most one active fault; injecting another replaces it. It is safe for
+// concurrent use.
+type FaultInjector struct {
+	mu      sync.Mutex
+	faults  map[string]activeFault
+	aliases map[int]string
+}
+
+// NewFaultInjector creates an injector without active faults.
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{faults: make(map[string]activeFault)}
+}
+
+// Inject activates a fault from now for its duration.
+func (f *FaultInjector) Inject(p InjectFaultPayload, now time.Time) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[p.MonitorID] = activeFault{
+		fault: p,
+		until: now.Add(time.Duration(p.DurationMs) * time.Millisecond),
+	}
+	return nil
+}
+
+// SetAliases gives the injector the connection's alias table, so faults
+// injected by monitor ID also apply to heartbeats that carry only an alias.
+func (f *FaultInjector) SetAliases(table map[int]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aliases = table
+}
+
+// Clear ends any fault on the monitor early.
+func (f *FaultInjector) Clear(monitorID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.faults, monitorID)
+}
+
+// Apply returns the heartbeat altered by the monitor's active fault, if any,
+// and whether it should be sent at all. Altered heartbeats carry an
+// "injected_fault" metadata entry so they are never mistaken for real
+// results.
+func (f *FaultInjector) Apply(hb HeartbeatPayload, now time.Time) (HeartbeatPayload, bool) {
+	f.mu.Lock()
+	key := hb.monitorIDIn(f.aliases)
+	active, ok := f.faults[key]
+	if ok && !now.Before(active.until) {
+		delete(f.faults, key)
+		ok = false
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		return hb, true
+	}
+
+	switch active.fault.Fault {
+	case FaultDropHeartbeats:
+		return hb, false
+	case FaultForceDown:
+		hb.Status = string(StatusDown)
+		hb.ErrorMessage = "injected fault: " + FaultForceDown
+	case FaultAddLatency:
+		hb.LatencyMs += active.fault.LatencyMs
+	}
+	metadata := make(map[string]string, len(hb.Metadata)+1)
+	for k, v := range hb.Metadata {
+		metadata[k] = v
+	}
+	metadata["injected_fault"] = active.fault.Fault
+	hb.Metadata = metadata
+	return hb, true
+}
@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInjectFaultValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       InjectFaultPayload
+		wantErr bool
+	}{
+		{"force down", InjectFaultPayload{MonitorID: "m", Fault: FaultForceDown, DurationMs: 1000}, false},
+		{"drop", InjectFaultPayload{MonitorID: "m", Fault: FaultDropHeartbeats, DurationMs: 1000}, false},
+		{"latency", InjectFaultPayload{MonitorID: "m", Fault: FaultAddLatency, DurationMs: 1000, LatencyMs: 300}, false},
+		{"latency without amount", InjectFaultPayload{MonitorID: "m", Fault: FaultAddLatency, DurationMs: 1000}, true},
+		{"no monitor", InjectFaultPayload{Fault: FaultForceDown, DurationMs: 1000}, true},
+		{"unknown fault", InjectFaultPayload{MonitorID: "m", Fault: "explode", DurationMs: 1000}, true},
+		{"no duration", InjectFaultPayload{MonitorID: "m", Fault: FaultForceDown}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFaultInjectorApply(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	hb := HeartbeatPayload{MonitorID: "m", Status: "up", LatencyMs: 20, Metadata: map[string]string{"region": "eu"}}
+	tests := []struct {
+		name        string
+		fault       InjectFaultPayload
+		wantSend    bool
+		wantStatus  string
+		wantLatency int
+	}{
+		{"force down", InjectFaultPayload{MonitorID: "m", Fault: FaultForceDown, DurationMs: 1000}, true, "down", 20},
+		{"add latency", InjectFaultPayload{MonitorID: "m", Fault: FaultAddLatency, DurationMs: 1000, LatencyMs: 300}, true, "up", 320},
+		{"drop", InjectFaultPayload{MonitorID: "m", Fault: FaultDropHeartbeats, DurationMs: 1000}, false, "up", 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFaultInjector()
+			if err := f.Inject(tt.fault, now); err != nil {
+				t.Fatal(err)
+			}
+			got, send := f.Apply(hb, now.Add(500*time.Millisecond))
+			if send != tt.wantSend || got.Status != tt.wantStatus || got.LatencyMs != tt.wantLatency {
+				t.Errorf("Apply() = %+v, %v", got, send)
+			}
+			if send && got.Metadata["injected_fault"] != tt.fault.Fault {
+				t.Errorf("Metadata = %v, want injected_fault marker", got.Metadata)
+			}
+			if len(hb.Metadata) != 1 {
+				t.Errorf("Apply() modified the input metadata: %v", hb.Metadata)
+			}
+
+			// The fault ends after its duration.
+			got, send = f.Apply(hb, now.Add(time.Second))
+			if !send || got.Status != "up" || got.LatencyMs != 20 || got.Metadata["injected_fault"] != "" {
+				t.Errorf("Apply() after expiry = %+v, %v", got, send)
+			}
+		})
+	}
+}
+
+func TestFaultInjectorScope(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	f := NewFaultInjector()
+	if err := f.Inject(InjectFaultPayload{MonitorID: "a", Fault: FaultForceDown, DurationMs: 60000}, now); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.Apply(HeartbeatPayload{MonitorID: "b", Status: "up"}, now); got.Status != "up" {
+		t.Error("fault applied to another monitor")
+	}
+	if err := f.Inject(InjectFaultPayload{MonitorID: "a", Fault: FaultAddLatency, DurationMs: 60000, LatencyMs: 5}, now); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.Apply(HeartbeatPayload{MonitorID: "a", Status: "up"}, now); got.Status != "up" || got.LatencyMs != 5 {
+		t.Errorf("second fault did not replace the first: %+v", got)
+	}
+	f.Clear("a")
+	if got, _ := f.Apply(HeartbeatPayload{MonitorID: "a", Status: "up"}, now); got.LatencyMs != 0 {
+		t.Error("Clear did not end the fault")
+	}
+	if err := f.Inject(InjectFaultPayload{MonitorID: "a"}, now); err == nil {
+		t.Error("Inject accepted an invalid fault")
+	}
+}
+
+func TestFaultInjectorAliases(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	f := NewFaultInjector()
+	if err := f.Inject(InjectFaultPayload{MonitorID: "a", Fault: FaultDropHeartbeats, DurationMs: 60000}, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, send := f.Apply(HeartbeatPayload{Alias: 1, Status: "up"}, now); !send {
+		t.Error("fault applied to an alias without an alias table")
+	}
+	f.SetAliases(map[int]string{1: "a", 2: "b"})
+	if _, send := f.Apply(HeartbeatPayload{Alias: 1, Status: "up"}, now); send {
+		t.Error("fault not applied to the monitor's aliased heartbeat")
+	}
+	if _, send := f.Apply(HeartbeatPayload{Alias: 2, Status: "up"}, now); !send {
+		t.Error("fault applied to another monitor's alias")
+	}
+	if _, send := f.Apply(HeartbeatPayload{MonitorID: "b", Alias: 1, Status: "up"}, now); !send {
+		t.Error("alias overrode the heartbeat's monitor ID")
+	}
+}
@@ -0,0 +1,100 @@
+package protocol
+
+import "sync"
+
+// labelOther replaces any label value outside the known set, so a peer
+// sending arbitrary strings cannot create new time series.
+const labelOther = "other"
+
+var (
+	labelStatuses     = setOf(StatusUp, StatusDown, StatusTimeout, StatusError)
+	labelMonitorTypes = setOf(MonitorHTTP, MonitorTCP, MonitorPing, MonitorDNS, MonitorTLS, MonitorDocker,
+		MonitorDatabase, MonitorSystem, MonitorService, MonitorPortScan, MonitorSNMP)
+	labelLogLevels = setOf(LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError)
+
+	metricCodesMu sync.RWMutex
+	metricCodes   = make(map[string]bool)
+)
+
+func setOf[T ~string](values ...T) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[string(v)] = true
+	}
+	return set
+}
+
+// RegisterMetricCodes adds error codes that MetricLabels reports as they
+// are. The protocol does not define error codes, so without registration
+// every code is reported as "other".
+func RegisterMetricCodes(codes ...string) {
+	metricCodesMu.Lock()
+	defer metricCodesMu.Unlock()
+	for _, code := range codes {
+		metricCodes[code] = true
+	}
+}
+
+func knownLabel(known map[string]bool, v string) string {
+	if known[v] {
+		return v
+	}
+	return labelOther
+}
+
+// MetricLabels returns labels describing m that are safe to use on metrics.
+// Only low-cardinality fields are included: the message type, check status,
+// monitor type, error code, log level and queue health. Monitor IDs, targets,
+// agent IDs and free-text errors are deliberately left out because each
+// distinct value would create a new time series. Values outside the ones this
+// package defines, or error codes not registered with RegisterMetricCodes,
+// become "other".
+//
+// A payload that fails to decode contributes no labels beyond the type. A nil
+// message has no labels.
+func MetricLabels(m *Message) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	msgType := labelOther
+	if _, known := payloadTypes[m.Type]; known {
+		msgType = m.Type
+	}
+	labels := map[string]string{"type": msgType}
+
+	switch m.Type {
+	case MsgTypeHeartbeat:
+		var p HeartbeatPayload
+		if m.ParsePayload(&p) == nil && p.Status != "" {
+			labels["status"] = knownLabel(labelStatuses, p.Status)
+		}
+	case MsgTypeCachedResult:
+		var p CachedResultPayload
+		if m.ParsePayload(&p) == nil && p.Status != "" {
+			labels["status"] = knownLabel(labelStatuses, p.Status)
+		}
+	case MsgTypeTask:
+		var p TaskPayload
+		if m.ParsePayload(&p) == nil && p.Type != "" {
+			labels["monitor_type"] = knownLabel(labelMonitorTypes, p.Type)
+		}
+	case MsgTypeError:
+		var p ErrorPayload
+		if m.ParsePayload(&p) == nil && p.Code != "" {
+			metricCodesMu.RLock()
+			labels["code"] = knownLabel(metricCodes, p.Code)
+			metricCodesMu.RUnlock()
+		}
+	case MsgTypeLog:
+		var p LogPayload
+		if m.ParsePayload(&p) == nil && p.Level != "" {
+			labels["level"] = knownLabel(labelLogLevels, p.Level)
+		}
+	case MsgTypeAgentInfo:
+		var p AgentInfoPayload
+		if m.ParsePayload(&p) == nil {
+			labels["queue_health"] = p.QueueHealth()
+		}
+	}
+	return labels
+}
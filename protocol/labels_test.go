@@ -0,0 +1,88 @@
+package protocol
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestMetricLabels(t *testing.T) {
+	RegisterMetricCodes("rate_limited")
+	tests := []struct {
+		name string
+		msg  *Message
+		want map[string]string
+	}{
+		{
+			"heartbeat keeps status only",
+			NewHeartbeatMessage("mon-8f3a", "down", 120, "connection refused to 10.0.0.7"),
+			map[string]string{"type": "heartbeat", "status": "down"},
+		},
+		{
+			"task keeps monitor type only",
+			MustNewMessage(MsgTypeTask, TaskPayload{MonitorID: "mon-1", Type: "http", Target: "https://secret.example.com"}),
+			map[string]string{"type": "task", "monitor_type": "http"},
+		},
+		{
+			"error keeps code only",
+			MustNewMessage(MsgTypeError, ErrorPayload{Code: "rate_limited", Message: "agent 42 sent too much"}),
+			map[string]string{"type": "error", "code": "rate_limited"},
+		},
+		{
+			"agent info keeps queue health",
+			NewAgentInfoMessage(AgentInfoPayload{QueueDepth: 10, QueueCapacity: 100}),
+			map[string]string{"type": "agent_info", "queue_health": QueueHealthOK},
+		},
+		{
+			"log keeps level",
+			NewLogMessage("mon-1", LogLevelWarn, "disk nearly full on /dev/sda1", 0),
+			map[string]string{"type": "log", "level": LogLevelWarn},
+		},
+		{
+			"auth drops credentials",
+			MustNewMessage(MsgTypeAuth, AuthPayload{APIKey: "secret", Version: "1.0.0"}),
+			map[string]string{"type": "auth"},
+		},
+		{
+			"unknown type",
+			&Message{Type: "x-abc123"},
+			map[string]string{"type": "other"},
+		},
+		{
+			"unknown status",
+			NewHeartbeatMessage("mon-1", "down-req-98234", 0, ""),
+			map[string]string{"type": "heartbeat", "status": "other"},
+		},
+		{
+			"unknown monitor type",
+			MustNewMessage(MsgTypeTask, TaskPayload{MonitorID: "mon-1", Type: "http-" + "9f2c"}),
+			map[string]string{"type": "task", "monitor_type": "other"},
+		},
+		{
+			"unregistered code",
+			NewErrorMessage("user 42 not found", "nope"),
+			map[string]string{"type": "error", "code": "other"},
+		},
+		{
+			"unknown level",
+			MustNewMessage(MsgTypeLog, LogPayload{Level: "trace-7", Message: "x"}),
+			map[string]string{"type": "log", "level": "other"},
+		},
+		{
+			"nil message",
+			nil,
+			map[string]string{},
+		},
+		{
+			"undecodable payload keeps type",
+			&Message{Type: MsgTypeHeartbeat, Payload: []byte(`{"status":`)},
+			map[string]string{"type": "heartbeat"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MetricLabels(tt.msg); !maps.Equal(got, tt.want) {
+				t.Errorf("MetricLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
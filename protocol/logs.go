@@ -0,0 +1,141 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Log levels for log messages.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// LogPayload is sent by agent to stream a log line, optionally tied to a
+// monitor. Suppressed counts earlier lines for the same monitor that were
+// dropped by throttling since the last one sent.
+type LogPayload struct {
+	MonitorID  string `json:"monitor_id,omitempty"`
+	Level      string `json:"level"`
+	Message    string `json:"message"`
+	Suppressed int    `json:"suppressed,omitempty"`
+}
+
+// Validate reports whether the log line is well formed.
+func (p LogPayload) Validate() error {
+	switch p.Level {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		return fmt.Errorf("log: unknown level %q", p.Level)
+	}
+	if p.Message == "" {
+		return errors.New("log: message is required")
+	}
+	if p.Suppressed < 0 {
+		return fmt.Errorf("log: suppressed must be non-negative, got %d", p.Suppressed)
+	}
+	return nil
+}
+
+// NewLogMessage creates a log message.
+func NewLogMessage(monitorID, level, message string, suppressed int) *Message {
+	return MustNewMessage(MsgTypeLog, LogPayload{
+		MonitorID:  monitorID,
+		Level:      level,
+		Message:    message,
+		Suppressed: suppressed,
+	})
+}
+
+type throttleKey struct {
+	monitorID string
+	level     string
+}
+
+// LogThrottle rate-limits log lines with a token bucket per monitor and level,
+// so one chatty monitor cannot drown the others and a flood of debug lines
+// never uses up the budget for errors. It counts what it drops so the agent
+// can report it. It is safe for concurrent use.
+type LogThrottle struct {
+	rate  float64
+	burst int
+
+	mu         sync.Mutex
+	buckets    map[throttleKey]*RateLimiter
+	suppressed map[string]int
+}
+
+// NewLogThrottle creates a throttle allowing rate lines per second with bursts
+// of burst lines, for each monitor and level separately.
+func NewLogThrottle(rate float64, burst int) *LogThrottle {
+	return &LogThrottle{
+		rate:       rate,
+		burst:      burst,
+		buckets:    make(map[throttleKey]*RateLimiter),
+		suppressed: make(map[string]int),
+	}
+}
+
+// Allow reports whether a log line for the monitor at the given level may be
+// sent now.
+func (t *LogThrottle) Allow(monitorID, level string) bool {
+	return t.AllowAt(monitorID, level, time.Now())
+}
+
+// AllowAt is Allow with an explicit clock reading.
+func (t *LogThrottle) AllowAt(monitorID, level string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := throttleKey{monitorID, level}
+	bucket, ok := t.buckets[key]
+	if !ok {
+		bucket = NewRateLimiter(t.rate, t.burst)
+		t.buckets[key] = bucket
+	}
+	if bucket.AllowAt(now) {
+		return true
+	}
+	t.suppressed[monitorID]++
+	return false
+}
+
+// TakeSuppressed returns how many lines for the monitor were dropped since the
+// last call and resets the count, ready for LogPayload.Suppressed.
+func (t *LogThrottle) TakeSuppressed(monitorID string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.suppressed[monitorID]
+	delete(t.suppressed, monitorID)
+	return n
+}
+
+// SuppressedSummary returns the current dropped-line counts per monitor.
+func (t *LogThrottle) SuppressedSummary() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	summary := make(map[string]int, len(t.suppressed))
+	for id, n := range t.suppressed {
+		summary[id] = n
+	}
+	return summary
+}
+
+// Forget drops all state for a monitor, e.g. after its task is cancelled.
+func (t *LogThrottle) Forget(monitorID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.buckets {
+		if key.monitorID == monitorID {
+			delete(t.buckets, key)
+		}
+	}
+	delete(t.suppressed, monitorID)
+}
@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestLogValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     LogPayload
+		wantErr bool
+	}{
+		{"valid", LogPayload{Level: LogLevelInfo, Message: "started"}, false},
+		{"valid with monitor", LogPayload{MonitorID: "mon-1", Level: LogLevelError, Message: "dial failed", Suppressed: 3}, false},
+		{"unknown level", LogPayload{Level: "trace", Message: "x"}, true},
+		{"no level", LogPayload{Message: "x"}, true},
+		{"no message", LogPayload{Level: LogLevelWarn}, true},
+		{"negative suppressed", LogPayload{Level: LogLevelWarn, Message: "x", Suppressed: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.log.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogThrottle(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	th := NewLogThrottle(1, 2)
+
+	allowed := 0
+	for range 5 {
+		if th.AllowAt("mon-1", LogLevelDebug, now) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("allowed %d debug lines, want the burst of 2", allowed)
+	}
+	// Levels and monitors have their own buckets.
+	if !th.AllowAt("mon-1", LogLevelError, now) {
+		t.Error("debug flood used up the error budget")
+	}
+	if !th.AllowAt("mon-2", LogLevelDebug, now) {
+		t.Error("one monitor's flood throttled another")
+	}
+	if !th.AllowAt("mon-1", LogLevelDebug, now.Add(time.Second)) {
+		t.Error("bucket did not refill")
+	}
+
+	if got := th.SuppressedSummary(); !maps.Equal(got, map[string]int{"mon-1": 3}) {
+		t.Errorf("SuppressedSummary() = %v", got)
+	}
+	if got := th.TakeSuppressed("mon-1"); got != 3 {
+		t.Errorf("TakeSuppressed() = %d, want 3", got)
+	}
+	if got := th.TakeSuppressed("mon-1"); got != 0 {
+		t.Errorf("TakeSuppressed() after reset = %d, want 0", got)
+	}
+}
+
+func TestLogThrottleForget(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	th := NewLogThrottle(1, 1)
+	th.AllowAt("mon-1", LogLevelInfo, now)
+	th.AllowAt("mon-1", LogLevelInfo, now)
+	th.AllowAt("mon-2", LogLevelInfo, now)
+
+	th.Forget("mon-1")
+	if got := th.SuppressedSummary(); len(got) != 0 {
+		t.Errorf("SuppressedSummary() after Forget = %v", got)
+	}
+	if !th.AllowAt("mon-1", LogLevelInfo, now) {
+		t.Error("forgotten monitor did not get a fresh bucket")
+	}
+	if th.AllowAt("mon-2", LogLevelInfo, now) {
+		t.Error("Forget reset another monitor's bucket")
+	}
+}
@@ -0,0 +1,30 @@
+package protocol
+
+import "time"
+
+// Window is a planned maintenance period during which a monitor is expected
+// to be down. Start is inclusive and End exclusive.
+type Window struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Contains reports whether t falls inside the window.
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// IsExpectedDown reports whether a non-up status at the given time falls into
+// one of the maintenance windows. Agents set HeartbeatPayload.Expected from it
+// so the hub can suppress alerts for planned outages.
+func IsExpectedDown(status MonitorStatus, windows []Window, at time.Time) bool {
+	if status.IsUp() {
+		return false
+	}
+	for _, w := range windows {
+		if w.Contains(at) {
+			return true
+		}
+	}
+	return false
+}
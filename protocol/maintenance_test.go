@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsExpectedDown(t *testing.T) {
+	start := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	windows := []Window{
+		{Start: start, End: start.Add(time.Hour)},
+		{Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+	}
+
+	tests := []struct {
+		name   string
+		status MonitorStatus
+		at     time.Time
+		want   bool
+	}{
+		{"down at window start", StatusDown, start, true},
+		{"down inside window", StatusDown, start.Add(30 * time.Minute), true},
+		{"timeout inside second window", StatusTimeout, start.Add(24*time.Hour + time.Minute), true},
+		{"error inside window", StatusError, start.Add(time.Minute), true},
+		{"down at window end", StatusDown, start.Add(time.Hour), false},
+		{"down before window", StatusDown, start.Add(-time.Second), false},
+		{"down between windows", StatusDown, start.Add(12 * time.Hour), false},
+		{"up inside window", StatusUp, start.Add(time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsExpectedDown(tt.status, windows, tt.at); got != tt.want {
+				t.Errorf("IsExpectedDown(%s, %v) = %v, want %v", tt.status, tt.at, got, tt.want)
+			}
+		})
+	}
+	if IsExpectedDown(StatusDown, nil, start) {
+		t.Error("expected down without any windows")
+	}
+}
+
+func TestTaskMaintenanceWindowsValidate(t *testing.T) {
+	start := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	task := TaskPayload{MonitorID: "m", Type: "http", MaintenanceWindows: []Window{{Start: start, End: start}}}
+	if err := task.Validate(); err == nil {
+		t.Error("Validate() accepted an empty maintenance window")
+	}
+	task.MaintenanceWindows[0].End = start.Add(time.Minute)
+	if err := task.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	task.MaintenanceWindows[0].End = time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := task.Validate(); err == nil {
+		t.Error("Validate() accepted a window JSON cannot encode")
+	}
+}
+
+func TestMonitorStatusIsUp(t *testing.T) {
+	for _, s := range []MonitorStatus{StatusDown, StatusTimeout, StatusError, "bogus", ""} {
+		if s.IsUp() {
+			t.Errorf("%q.IsUp() = true", s)
+		}
+	}
+	if !StatusUp.IsUp() {
+		t.Error("up is not up")
+	}
+}
@@ -2,30 +2,65 @@ package protocol
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
 	"time"
 )
 
 // Message types for WebSocket communication.
 const (
-	MsgTypeAuth      = "auth"
-	MsgTypeAuthAck   = "auth_ack"
-	MsgTypeAuthError = "auth_error"
-	MsgTypeTask      = "task"
-	MsgTypeHeartbeat = "heartbeat"
-	MsgTypePing      = "ping"
-	MsgTypePong      = "pong"
+	MsgTypeAuth            = "auth"
+	MsgTypeAuthAck         = "auth_ack"
+	MsgTypeAuthError       = "auth_error"
+	MsgTypeTask            = "task"
+	MsgTypeHeartbeat       = "heartbeat"
+	MsgTypePing            = "ping"
+	MsgTypePong            = "pong"
 	MsgTypeTaskCancel      = "task_cancel"
 	MsgTypeError           = "error"
 	MsgTypeUpdateAvailable = "update_available"
 	MsgTypeDiscoveryTask   = "discovery_task"
 	MsgTypeDiscoveryResult = "discovery_result"
+	MsgTypeCachedResult    = "cached_result"
+	MsgTypeReassign        = "reassign"
+	MsgTypeReassignAck     = "reassign_ack"
+	MsgTypeAgentInfo       = "agent_info"
+	MsgTypeStateReport     = "state_report"
+	MsgTypeHeartbeatBatch  = "heartbeat_batch"
+	MsgTypeStepResult      = "step_result"
+	MsgTypeLog             = "log"
+	MsgTypeCertInfo        = "cert_info"
+	MsgTypeInjectFault     = "inject_fault"
+	MsgTypeTaskBatch       = "task_batch"
+	MsgTypeTaskBatchAck    = "task_batch_ack"
+	MsgTypeDrain           = "drain"
+	MsgTypeDrained         = "drained"
+	MsgTypeReceipt         = "receipt"
+	MsgTypeTaskReject      = "task_reject"
+	MsgTypeTransition      = "transition"
+	MsgTypeTransitionAck   = "transition_ack"
+	MsgTypeHistoryBatch    = "history_batch"
+	MsgTypeChunk           = "chunk"
+	MsgTypeUpdateStatus    = "update_status"
+	MsgTypeListTasks       = "list_tasks"
+	MsgTypeTaskList        = "task_list"
 )
 
 // Message represents a WebSocket message envelope.
 type Message struct {
-	Type      string          `json:"type"`
-	Payload   json.RawMessage `json:"payload,omitempty"`
-	Timestamp time.Time       `json:"timestamp"`
+	ID           string          `json:"id,omitempty"`
+	Type         string          `json:"type"`
+	Payload      json.RawMessage `json:"payload,omitempty"`
+	Timestamp    time.Time       `json:"timestamp"`
+	TraceParent  string          `json:"traceparent,omitempty"`
+	TraceState   string          `json:"tracestate,omitempty"`
+	PartitionKey string          `json:"partition_key,omitempty"`
+	Seq          uint64          `json:"seq,omitempty"`
+	AfterSeq     uint64          `json:"after_seq,omitempty"`
+	PrevDigest   string          `json:"prev_digest,omitempty"`
+	BroadcastID  string          `json:"broadcast_id,omitempty"`
+	TTLMs        int64           `json:"ttl_ms,omitempty"`
 }
 
 // NewMessage creates a new message with the current timestamp.
@@ -54,6 +89,43 @@ func (m *Message) ParsePayload(v any) error {
 	return json.Unmarshal(m.Payload, v)
 }
 
+// ErrUnknownMessageType is returned for messages whose type this package does
+// not define.
+var ErrUnknownMessageType = errors.New("unknown message type")
+
+// DecodeError is returned when a payload does not decode into the type
+// expected for its message type.
+type DecodeError struct {
+	MsgType string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %s payload: %v", e.MsgType, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodePayload unmarshals the payload into the concrete payload type for the
+// message type and returns it by value, e.g. a HeartbeatPayload for a
+// heartbeat. Types that carry no payload decode to nil.
+func (m *Message) DecodePayload() (any, error) {
+	proto, ok := payloadTypes[m.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMessageType, m.Type)
+	}
+	if proto == nil {
+		return nil, nil
+	}
+	ptr := reflect.New(reflect.TypeOf(proto))
+	if err := m.ParsePayload(ptr.Interface()); err != nil {
+		return nil, &DecodeError{MsgType: m.Type, Err: err}
+	}
+	return ptr.Elem().Interface(), nil
+}
+
 // MustNewMessage creates a new message and panics on error.
 // Use only when payload is guaranteed to be serializable.
 func MustNewMessage(msgType string, payload any) *Message {
@@ -66,15 +138,23 @@ func MustNewMessage(msgType string, payload any) *Message {
 
 // AuthPayload is sent by agent to authenticate.
 type AuthPayload struct {
-	APIKey      string            `json:"api_key"`
-	Version     string            `json:"version,omitempty"`
-	Fingerprint map[string]string `json:"fingerprint,omitempty"`
+	APIKey             string              `json:"api_key"`
+	Version            string              `json:"version,omitempty"`
+	Fingerprint        map[string]string   `json:"fingerprint,omitempty"`
+	DesiredMessageRate int                 `json:"desired_message_rate,omitempty"`
+	NetworkConstraints *NetworkConstraints `json:"network_constraints,omitempty"`
+	Capabilities       []string            `json:"capabilities,omitempty"`
+	SchemaHash         string              `json:"schema_hash,omitempty"`
 }
 
 // AuthAckPayload is sent by hub to confirm authentication.
 type AuthAckPayload struct {
-	AgentID   string `json:"agent_id"`
-	AgentName string `json:"agent_name"`
+	AgentID            string `json:"agent_id"`
+	AgentName          string `json:"agent_name"`
+	GrantedMessageRate int    `json:"granted_message_rate,omitempty"`
+	SessionNonce       []byte `json:"session_nonce,omitempty"`
+	DictionaryVersion  int    `json:"dictionary_version,omitempty"`
+	SchemaHash         string `json:"schema_hash,omitempty"`
 }
 
 // AuthErrorPayload is sent by hub when authentication fails.
@@ -84,23 +164,38 @@ type AuthErrorPayload struct {
 
 // TaskPayload describes a monitoring task for the agent.
 type TaskPayload struct {
-	MonitorID string            `json:"monitor_id"`
-	Type      string            `json:"type"`
-	Target    string            `json:"target"`
-	Interval  int               `json:"interval"`
-	Timeout   int               `json:"timeout"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
+	MonitorID          string            `json:"monitor_id"`
+	Type               string            `json:"type"`
+	Target             string            `json:"target"`
+	Interval           int               `json:"interval"`
+	Timeout            int               `json:"timeout"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	ResultCacheTTLMs   int               `json:"result_cache_ttl_ms,omitempty"`
+	MaintenanceWindows []Window          `json:"maintenance_windows,omitempty"`
+	Alias              int               `json:"alias,omitempty"`
+	SendData           string            `json:"send_data,omitempty"`
+	ExpectData         string            `json:"expect_data,omitempty"`
+	ConfigRevision     int               `json:"config_revision,omitempty"`
+	MaxChecksPerMinute int               `json:"max_checks_per_minute,omitempty"`
+	SLO                *SLOTarget        `json:"slo,omitempty"`
 }
 
 // HeartbeatPayload is sent by agent with check results.
 type HeartbeatPayload struct {
-	MonitorID      string            `json:"monitor_id"`
-	Status         string            `json:"status"`
-	LatencyMs      int               `json:"latency_ms,omitempty"`
-	ErrorMessage   string            `json:"error_message,omitempty"`
-	CertExpiryDays *int              `json:"cert_expiry_days,omitempty"`
-	CertIssuer     string            `json:"cert_issuer,omitempty"`
-	Metadata       map[string]string `json:"metadata,omitempty"`
+	MonitorID       string            `json:"monitor_id,omitempty"`
+	Alias           int               `json:"alias,omitempty"`
+	Status          string            `json:"status"`
+	LatencyMs       int               `json:"latency_ms,omitempty"`
+	ErrorMessage    string            `json:"error_message,omitempty"`
+	CertExpiryDays  *int              `json:"cert_expiry_days,omitempty"`
+	CertIssuer      string            `json:"cert_issuer,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Timing          *TimingBreakdown  `json:"timing,omitempty"`
+	Expected        bool              `json:"expected,omitempty"`
+	DownReason      *DownReason       `json:"down_reason,omitempty"`
+	Banner          string            `json:"banner,omitempty"`
+	Assertions      []Assertion       `json:"assertions,omitempty"`
+	ThrottledChecks int               `json:"throttled_checks,omitempty"`
 }
 
 // TaskCancelPayload tells the agent to stop monitoring a specific monitor.
@@ -115,11 +210,13 @@ type ErrorPayload struct {
 }
 
 // UpdateAvailablePayload is sent by hub when a newer agent version exists.
+// Mandatory updates must be applied; others are left to the agent's policy.
 type UpdateAvailablePayload struct {
 	Version     string `json:"version"`
 	DownloadURL string `json:"download_url"`
 	SHA256      string `json:"sha256"`
 	Signature   string `json:"signature,omitempty"`
+	Mandatory   bool   `json:"mandatory,omitempty"`
 }
 
 // Helper functions to create common messages.
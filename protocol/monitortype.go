@@ -0,0 +1,19 @@
+package protocol
+
+// MonitorType is the kind of check a task runs.
+type MonitorType string
+
+// Monitor types understood by the agent.
+const (
+	MonitorHTTP     MonitorType = "http"
+	MonitorTCP      MonitorType = "tcp"
+	MonitorPing     MonitorType = "ping"
+	MonitorDNS      MonitorType = "dns"
+	MonitorTLS      MonitorType = "tls"
+	MonitorDocker   MonitorType = "docker"
+	MonitorDatabase MonitorType = "database"
+	MonitorSystem   MonitorType = "system"
+	MonitorService  MonitorType = "service"
+	MonitorPortScan MonitorType = "port_scan"
+	MonitorSNMP     MonitorType = "snmp"
+)
@@ -0,0 +1,96 @@
+package protocol
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PerKeyOrderer dispatches messages so that those sharing a key, typically a
+// monitor ID, are handled one at a time in submission order, while messages
+// for different keys are handled concurrently. It is safe for concurrent use.
+type PerKeyOrderer struct {
+	mu     sync.Mutex
+	queues map[string][]orderedItem
+	budget *ConnectionBudget
+	wg     sync.WaitGroup
+}
+
+type orderedItem struct {
+	msg     *Message
+	handler func(*Message)
+	size    int
+}
+
+// NewPerKeyOrderer creates an idle orderer.
+func NewPerKeyOrderer() *PerKeyOrderer {
+	return &PerKeyOrderer{queues: make(map[string][]orderedItem)}
+}
+
+// SetBudget makes the orderer charge queued messages against b, releasing
+// each once its handler returns. Submit always queues and charges even past
+// the cap; TrySubmit refuses a message the budget cannot hold. Call it before
+// the first submission.
+func (o *PerKeyOrderer) SetBudget(b *ConnectionBudget) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.budget = b
+}
+
+// Submit queues m for handler. It never blocks on the handler; a goroutine
+// per active key drains that key's queue and exits once it is empty.
+func (o *PerKeyOrderer) Submit(key string, m *Message, handler func(*Message)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	size := MessageSize(m)
+	o.budget.hold(size)
+	o.enqueue(key, orderedItem{msg: m, handler: handler, size: size})
+}
+
+// TrySubmit is Submit, except that it fails without queueing m if the budget
+// cannot hold it.
+func (o *PerKeyOrderer) TrySubmit(key string, m *Message, handler func(*Message)) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	size := MessageSize(m)
+	if err := o.budget.Charge(size); err != nil {
+		return fmt.Errorf("orderer: %w", err)
+	}
+	o.enqueue(key, orderedItem{msg: m, handler: handler, size: size})
+	return nil
+}
+
+func (o *PerKeyOrderer) enqueue(key string, item orderedItem) {
+	queue, active := o.queues[key]
+	o.queues[key] = append(queue, item)
+	if !active {
+		o.wg.Add(1)
+		go o.drain(key)
+	}
+}
+
+// Wait blocks until every submitted message has been handled.
+func (o *PerKeyOrderer) Wait() {
+	o.wg.Wait()
+}
+
+func (o *PerKeyOrderer) drain(key string) {
+	defer o.wg.Done()
+	for {
+		o.mu.Lock()
+		queue := o.queues[key]
+		if len(queue) == 0 {
+			delete(o.queues, key)
+			o.mu.Unlock()
+			return
+		}
+		item := queue[0]
+		o.queues[key] = queue[1:]
+		budget := o.budget
+		o.mu.Unlock()
+
+		item.handler(item.msg)
+		budget.Release(item.size)
+	}
+}
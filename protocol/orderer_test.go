@@ -0,0 +1,88 @@
+package protocol
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPerKeyOrdererSerializesSameKey(t *testing.T) {
+	const keys, perKey = 8, 200
+	o := NewPerKeyOrderer()
+
+	var mu sync.Mutex
+	got := make(map[string][]uint64)
+	active := make(map[string]*atomic.Int32)
+	for k := range keys {
+		active[fmt.Sprint(k)] = new(atomic.Int32)
+	}
+
+	// Submit from several goroutines per key would make the expected order
+	// ambiguous, so each key has a single submitter; keys race each other.
+	var wg sync.WaitGroup
+	for k := range keys {
+		key := fmt.Sprint(k)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perKey {
+				o.Submit(key, &Message{Seq: uint64(i)}, func(m *Message) {
+					if n := active[key].Add(1); n != 1 {
+						t.Errorf("key %s: %d handlers running at once", key, n)
+					}
+					mu.Lock()
+					got[key] = append(got[key], m.Seq)
+					mu.Unlock()
+					active[key].Add(-1)
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	o.Wait()
+
+	for key, seqs := range got {
+		if len(seqs) != perKey || !slices.IsSorted(seqs) {
+			t.Errorf("key %s handled %d messages, sorted %v", key, len(seqs), slices.IsSorted(seqs))
+		}
+	}
+}
+
+func TestPerKeyOrdererRunsKeysConcurrently(t *testing.T) {
+	o := NewPerKeyOrderer()
+	release := make(chan struct{})
+	otherRan := make(chan struct{})
+
+	o.Submit("slow", &Message{}, func(*Message) { <-release })
+	o.Submit("fast", &Message{}, func(*Message) { close(otherRan) })
+
+	select {
+	case <-otherRan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked key held up a different key")
+	}
+	close(release)
+	o.Wait()
+}
+
+func TestPerKeyOrdererSubmitDoesNotBlock(t *testing.T) {
+	o := NewPerKeyOrderer()
+	release := make(chan struct{})
+	o.Submit("k", &Message{}, func(*Message) { <-release })
+
+	done := make(chan struct{})
+	go func() {
+		o.Submit("k", &Message{}, func(*Message) {})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit blocked behind a running handler")
+	}
+	close(release)
+	o.Wait()
+}
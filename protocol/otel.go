@@ -0,0 +1,113 @@
+package protocol
+
+import "time"
+
+// OpenTelemetry severity numbers used by ToOTelLog.
+const (
+	OTelSeverityDebug = 5
+	OTelSeverityInfo  = 9
+	OTelSeverityWarn  = 13
+	OTelSeverityError = 17
+)
+
+// OTelLogRecord is a message mapped onto the OpenTelemetry log data model,
+// ready to hand to an exporter.
+type OTelLogRecord struct {
+	Timestamp      time.Time      `json:"timestamp"`
+	SeverityNumber int            `json:"severity_number"`
+	SeverityText   string         `json:"severity_text"`
+	Body           string         `json:"body"`
+	Attributes     map[string]any `json:"attributes"`
+}
+
+// ToOTelLog maps a message to a log record. Severity comes from the level of
+// log messages and otherwise from the message: errors are ERROR, failed
+// checks WARN and everything else INFO. Attributes are the MetricLabels of
+// the message under a "watchdog." prefix, plus the monitor ID and latency
+// where the payload has them; free text such as error messages goes in the
+// body only, so attributes stay safe to index.
+func ToOTelLog(m *Message) OTelLogRecord {
+	rec := OTelLogRecord{
+		Timestamp:      m.Timestamp,
+		SeverityNumber: OTelSeverityInfo,
+		Body:           m.Type,
+		Attributes:     make(map[string]any),
+	}
+	for k, v := range MetricLabels(m) {
+		if k == "type" {
+			k = "message.type"
+		}
+		rec.Attributes["watchdog."+k] = v
+	}
+
+	payload, _ := m.DecodePayload()
+	switch p := payload.(type) {
+	case HeartbeatPayload:
+		setMonitorAttrs(rec.Attributes, p.MonitorID, p.LatencyMs)
+		if !MonitorStatus(p.Status).IsUp() && !p.Expected {
+			rec.SeverityNumber = OTelSeverityWarn
+		}
+		rec.Body = p.Status
+		if p.ErrorMessage != "" {
+			rec.Body = p.Status + ": " + p.ErrorMessage
+		}
+	case CachedResultPayload:
+		setMonitorAttrs(rec.Attributes, p.MonitorID, p.LatencyMs)
+		if !MonitorStatus(p.Status).IsUp() {
+			rec.SeverityNumber = OTelSeverityWarn
+		}
+		rec.Body = p.Status
+	case LogPayload:
+		setMonitorAttrs(rec.Attributes, p.MonitorID, 0)
+		rec.SeverityNumber = logSeverity(p.Level)
+		rec.Body = p.Message
+	case ErrorPayload:
+		rec.SeverityNumber = OTelSeverityError
+		rec.Body = p.Message
+	case AuthErrorPayload:
+		rec.SeverityNumber = OTelSeverityError
+		rec.Body = p.Error
+	case StepResultPayload:
+		setMonitorAttrs(rec.Attributes, "", p.LatencyMs)
+		if !p.Success {
+			rec.SeverityNumber = OTelSeverityWarn
+			rec.Body = p.Error
+		}
+	}
+
+	rec.SeverityText = severityText(rec.SeverityNumber)
+	return rec
+}
+
+func setMonitorAttrs(attrs map[string]any, monitorID string, latencyMs int) {
+	if monitorID != "" {
+		attrs["watchdog.monitor.id"] = monitorID
+	}
+	if latencyMs > 0 {
+		attrs["watchdog.latency_ms"] = latencyMs
+	}
+}
+
+func logSeverity(level string) int {
+	switch level {
+	case LogLevelDebug:
+		return OTelSeverityDebug
+	case LogLevelWarn:
+		return OTelSeverityWarn
+	case LogLevelError:
+		return OTelSeverityError
+	}
+	return OTelSeverityInfo
+}
+
+func severityText(n int) string {
+	switch n {
+	case OTelSeverityDebug:
+		return "DEBUG"
+	case OTelSeverityWarn:
+		return "WARN"
+	case OTelSeverityError:
+		return "ERROR"
+	}
+	return "INFO"
+}
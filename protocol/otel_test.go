@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestToOTelLog(t *testing.T) {
+	expected := MustNewMessage(MsgTypeHeartbeat, HeartbeatPayload{MonitorID: "mon-1", Status: "down", Expected: true})
+	tests := []struct {
+		name         string
+		msg          *Message
+		wantSeverity string
+		wantBody     string
+		wantAttrs    map[string]any
+	}{
+		{
+			name:         "up heartbeat",
+			msg:          NewHeartbeatMessage("mon-1", "up", 42, ""),
+			wantSeverity: "INFO",
+			wantBody:     "up",
+			wantAttrs: map[string]any{"watchdog.message.type": "heartbeat", "watchdog.status": "up",
+				"watchdog.monitor.id": "mon-1", "watchdog.latency_ms": 42},
+		},
+		{
+			name:         "failed heartbeat",
+			msg:          NewHeartbeatMessage("mon-1", "down", 0, "connection refused"),
+			wantSeverity: "WARN",
+			wantBody:     "down: connection refused",
+			wantAttrs: map[string]any{"watchdog.message.type": "heartbeat", "watchdog.status": "down",
+				"watchdog.monitor.id": "mon-1"},
+		},
+		{
+			name:         "expected downtime",
+			msg:          expected,
+			wantSeverity: "INFO",
+			wantBody:     "down",
+			wantAttrs: map[string]any{"watchdog.message.type": "heartbeat", "watchdog.status": "down",
+				"watchdog.monitor.id": "mon-1"},
+		},
+		{
+			name:         "cached failure",
+			msg:          NewCachedResultMessage("mon-2", "timeout", 5000, "deadline", time.Unix(1_700_000_000, 0)),
+			wantSeverity: "WARN",
+			wantBody:     "timeout",
+			wantAttrs: map[string]any{"watchdog.message.type": "cached_result", "watchdog.status": "timeout",
+				"watchdog.monitor.id": "mon-2", "watchdog.latency_ms": 5000},
+		},
+		{
+			name:         "debug log",
+			msg:          NewLogMessage("mon-1", LogLevelDebug, "resolved 3 addresses", 0),
+			wantSeverity: "DEBUG",
+			wantBody:     "resolved 3 addresses",
+			wantAttrs: map[string]any{"watchdog.message.type": "log", "watchdog.level": "debug",
+				"watchdog.monitor.id": "mon-1"},
+		},
+		{
+			name:         "error log without monitor",
+			msg:          NewLogMessage("", LogLevelError, "queue full", 0),
+			wantSeverity: "ERROR",
+			wantBody:     "queue full",
+			wantAttrs:    map[string]any{"watchdog.message.type": "log", "watchdog.level": "error"},
+		},
+		{
+			name:         "protocol error",
+			msg:          NewErrorMessage("rate_limited", "slow down"),
+			wantSeverity: "ERROR",
+			wantBody:     "slow down",
+			wantAttrs:    map[string]any{"watchdog.message.type": "error", "watchdog.code": "rate_limited"},
+		},
+		{
+			name:         "auth error",
+			msg:          NewAuthErrorMessage("invalid api key"),
+			wantSeverity: "ERROR",
+			wantBody:     "invalid api key",
+			wantAttrs:    map[string]any{"watchdog.message.type": "auth_error"},
+		},
+		{
+			name:         "ping",
+			msg:          NewPingMessage(),
+			wantSeverity: "INFO",
+			wantBody:     "ping",
+			wantAttrs:    map[string]any{"watchdog.message.type": "ping"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ToOTelLog(tt.msg)
+			if rec.SeverityText != tt.wantSeverity || severityText(rec.SeverityNumber) != tt.wantSeverity {
+				t.Errorf("severity = %d %q, want %q", rec.SeverityNumber, rec.SeverityText, tt.wantSeverity)
+			}
+			if rec.Body != tt.wantBody {
+				t.Errorf("Body = %q, want %q", rec.Body, tt.wantBody)
+			}
+			if !maps.Equal(rec.Attributes, tt.wantAttrs) {
+				t.Errorf("Attributes = %v, want %v", rec.Attributes, tt.wantAttrs)
+			}
+			if !rec.Timestamp.Equal(tt.msg.Timestamp) {
+				t.Errorf("Timestamp = %v, want the message's", rec.Timestamp)
+			}
+		})
+	}
+}
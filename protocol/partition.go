@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"encoding/json"
+	"hash/fnv"
+)
+
+// ResolvePartitionKey returns the key a clustered hub routes m by: the
+// envelope's PartitionKey if set, otherwise the payload's monitor_id.
+// Heartbeats that carry only an alias are keyed by the alias, which is stable
+// for a monitor on one connection; run them through ResolveAliases first to
+// key them by monitor ID. Messages with no key, such as pings, yield "".
+func (m *Message) ResolvePartitionKey() string {
+	if m.PartitionKey != "" {
+		return m.PartitionKey
+	}
+	var p struct {
+		MonitorID string `json:"monitor_id"`
+		Alias     int    `json:"alias"`
+	}
+	if len(m.Payload) == 0 || json.Unmarshal(m.Payload, &p) != nil {
+		return ""
+	}
+	if p.MonitorID == "" && p.Alias > 0 {
+		return aliasKey(p.Alias)
+	}
+	return p.MonitorID
+}
+
+// PartitionFor returns the partition in [0, partitions) that owns m, using
+// jump consistent hashing on its partition key. The same key always maps to
+// the same partition, and growing from n to n+1 partitions moves only about
+// 1/(n+1) of the keys. Messages without a key go to partition 0; give them
+// the connection's key with ConnectionPartitionKey so keyless traffic spreads
+// across partitions instead.
+func PartitionFor(m *Message, partitions int) int {
+	key := m.ResolvePartitionKey()
+	if partitions <= 1 || key == "" {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return jumpHash(mix64(h.Sum64()), partitions)
+}
+
+// ConnectionPartitionKey returns a transform that sets the partition key of
+// messages without a key of their own, such as pings, to connID, normally the
+// ID of the connection they arrived on. Messages with a key pass through
+// unchanged.
+func ConnectionPartitionKey(connID string) Transform {
+	return func(m *Message) (*Message, error) {
+		if m.ResolvePartitionKey() != "" {
+			return m, nil
+		}
+		out := *m
+		out.PartitionKey = connID
+		return &out, nil
+	}
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach.
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestResolvePartitionKey(t *testing.T) {
+	explicit := NewHeartbeatMessage("mon-1", "up", 1, "")
+	explicit.PartitionKey = "tenant-7"
+	tests := []struct {
+		name string
+		msg  *Message
+		want string
+	}{
+		{"explicit key wins", explicit, "tenant-7"},
+		{"monitor id from payload", NewHeartbeatMessage("mon-1", "up", 1, ""), "mon-1"},
+		{"task", NewTaskMessage("mon-2", "http", "https://example.com", 30, 5), "mon-2"},
+		{"alias-only heartbeat", NewAliasedHeartbeatMessage(3, "up", 1, ""), "alias:3"},
+		{"keyless ping", NewPingMessage(), ""},
+		{"non-object payload", &Message{Type: MsgTypePing, Payload: []byte(`"x"`)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.ResolvePartitionKey(); got != tt.want {
+				t.Errorf("ResolvePartitionKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionFor(t *testing.T) {
+	hb := NewHeartbeatMessage("mon-1", "up", 1, "")
+	if got := PartitionFor(hb, 1); got != 0 {
+		t.Errorf("single partition = %d, want 0", got)
+	}
+	p := PartitionFor(hb, 16)
+	if p < 0 || p >= 16 {
+		t.Fatalf("partition %d out of range", p)
+	}
+	if got := PartitionFor(NewPingMessage(), 16); got != 0 {
+		t.Errorf("keyless message = %d, want 0", got)
+	}
+
+	// Aliased heartbeats stay together, and land with the monitor once the
+	// alias is resolved.
+	aliased := NewAliasedHeartbeatMessage(3, "up", 1, "")
+	if PartitionFor(aliased, 16) != PartitionFor(NewAliasedHeartbeatMessage(3, "down", 0, ""), 16) {
+		t.Error("heartbeats with the same alias went to different partitions")
+	}
+	resolved, err := ResolveAliases(map[int]string{3: "mon-1"})(aliased)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := PartitionFor(resolved, 16); got != p {
+		t.Errorf("resolved aliased heartbeat = %d, want the monitor's partition %d", got, p)
+	}
+}
+
+func TestConnectionPartitionKey(t *testing.T) {
+	const partitions = 8
+	hb := NewHeartbeatMessage("mon-1", "up", 1, "")
+	keyed, _ := ConnectionPartitionKey("conn-1")(hb)
+	if keyed != hb {
+		t.Error("ConnectionPartitionKey changed a message with its own key")
+	}
+
+	seen := make(map[int]bool)
+	for i := range 200 {
+		key := ConnectionPartitionKey(fmt.Sprintf("conn-%d", i))
+		ping, _ := key(NewPingMessage())
+		pong, _ := key(NewPongMessage())
+		p := PartitionFor(ping, partitions)
+		if p != PartitionFor(pong, partitions) {
+			t.Fatalf("conn-%d: keyless messages on one connection went to different partitions", i)
+		}
+		seen[p] = true
+	}
+	if len(seen) != partitions {
+		t.Errorf("keyless traffic reached %d of %d partitions", len(seen), partitions)
+	}
+}
+
+func TestPartitionForMinimalMovement(t *testing.T) {
+	const keys = 2000
+	var moved int
+	for i := range keys {
+		m := NewHeartbeatMessage(fmt.Sprintf("mon-%d", i), "up", 1, "")
+		before, after := PartitionFor(m, 10), PartitionFor(m, 11)
+		if before != after {
+			if after != 10 {
+				t.Fatalf("key moved from %d to %d, not to the new partition", before, after)
+			}
+			moved++
+		}
+	}
+	if moved < keys/22 || moved > keys*3/22 {
+		t.Errorf("%d of %d keys moved, want about %d", moved, keys, keys/11)
+	}
+}
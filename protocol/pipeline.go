@@ -0,0 +1,112 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Transform rewrites a message on its way in or out. Returning an error stops
+// the pipeline; returning a nil message drops it, and the transforms after
+// it do not run. The built-in transforms return a modified copy and never
+// change the message passed in, so one message, such as a broadcast, can go
+// through several connections' pipelines at once.
+type Transform func(*Message) (*Message, error)
+
+// Pipeline runs transforms in order. Connections typically keep one pipeline
+// for outgoing messages and another for incoming ones.
+type Pipeline struct {
+	transforms []Transform
+}
+
+// NewPipeline creates a pipeline running the given transforms in order.
+func NewPipeline(transforms ...Transform) *Pipeline {
+	return &Pipeline{transforms: transforms}
+}
+
+// Use appends transforms to the end of the pipeline.
+func (p *Pipeline) Use(transforms ...Transform) {
+	p.transforms = append(p.transforms, transforms...)
+}
+
+// Apply runs m through every transform and returns the result. It stops at
+// the first transform that fails, and returns nil without an error if a
+// transform dropped the message.
+func (p *Pipeline) Apply(m *Message) (*Message, error) {
+	for i, t := range p.transforms {
+		if m == nil {
+			return nil, nil
+		}
+		var err error
+		if m, err = t(m); err != nil {
+			return nil, fmt.Errorf("pipeline: transform %d: %w", i, err)
+		}
+	}
+	return m, nil
+}
+
+// RedactedValue replaces payload fields removed by Redact.
+const RedactedValue = "[REDACTED]"
+
+// Redact returns a transform that replaces the named top-level payload
+// fields, e.g. "api_key", with RedactedValue. The original message is left
+// untouched. Payloads that are not JSON objects pass through unchanged.
+func Redact(fields ...string) Transform {
+	return func(m *Message) (*Message, error) {
+		var obj map[string]json.RawMessage
+		if len(m.Payload) == 0 || json.Unmarshal(m.Payload, &obj) != nil || obj == nil {
+			return m, nil
+		}
+
+		redacted := false
+		for _, f := range fields {
+			if _, ok := obj[f]; ok {
+				obj[f] = json.RawMessage(`"` + RedactedValue + `"`)
+				redacted = true
+			}
+		}
+		if !redacted {
+			return m, nil
+		}
+
+		payload, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		out := *m
+		out.Payload = payload
+		return &out, nil
+	}
+}
+
+// StampTimestamp returns a transform that sets the message timestamp from
+// now, e.g. to apply server-side time on receipt.
+func StampTimestamp(now func() time.Time) Transform {
+	return func(m *Message) (*Message, error) {
+		out := *m
+		out.Timestamp = now()
+		return &out, nil
+	}
+}
+
+// AssignID returns a transform that gives messages without an ID one from
+// newID. Pass NewMessageID for random IDs.
+func AssignID(newID func() string) Transform {
+	return func(m *Message) (*Message, error) {
+		if m.ID != "" {
+			return m, nil
+		}
+		out := *m
+		out.ID = newID()
+		return &out, nil
+	}
+}
+
+// NewMessageID returns a random 128-bit message ID in hex.
+func NewMessageID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,138 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipelineAppliesInOrder(t *testing.T) {
+	var order []string
+	step := func(name string) Transform {
+		return func(m *Message) (*Message, error) {
+			order = append(order, name)
+			return m, nil
+		}
+	}
+	p := NewPipeline(step("a"), step("b"))
+	p.Use(step("c"))
+	if _, err := p.Apply(&Message{Type: MsgTypePing}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != "a" || order[1] != "b" || order[2] != "c" {
+		t.Errorf("order = %v, want [a b c]", order)
+	}
+}
+
+func TestPipelineStopsOnError(t *testing.T) {
+	errBoom := errors.New("boom")
+	ran := false
+	p := NewPipeline(
+		func(*Message) (*Message, error) { return nil, errBoom },
+		func(m *Message) (*Message, error) { ran = true; return m, nil },
+	)
+	if _, err := p.Apply(&Message{}); !errors.Is(err, errBoom) {
+		t.Errorf("Apply() error = %v, want it to wrap %v", err, errBoom)
+	}
+	if ran {
+		t.Error("a transform ran after an earlier one failed")
+	}
+}
+
+func TestPipelineDropsNilMessage(t *testing.T) {
+	ran := false
+	p := NewPipeline(
+		func(*Message) (*Message, error) { return nil, nil },
+		func(m *Message) (*Message, error) { ran = true; return m, m.Validate() },
+	)
+	if m, err := p.Apply(NewPingMessage()); m != nil || err != nil {
+		t.Errorf("Apply() = %v, %v, want the message dropped", m, err)
+	}
+	if ran {
+		t.Error("a transform ran after the message was dropped")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	orig := MustNewMessage(MsgTypeAuth, AuthPayload{APIKey: "secret", Version: "1.0.0"})
+	origPayload := string(orig.Payload)
+
+	out, err := Redact("api_key", "missing")(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p map[string]string
+	if err := json.Unmarshal(out.Payload, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p["api_key"] != RedactedValue || p["version"] != "1.0.0" {
+		t.Errorf("redacted payload = %v", p)
+	}
+	if string(orig.Payload) != origPayload {
+		t.Error("Redact modified the original message")
+	}
+
+	ping := &Message{Type: MsgTypePing}
+	if out, _ := Redact("api_key")(ping); out != ping {
+		t.Error("Redact copied a message with nothing to redact")
+	}
+}
+
+func TestBuiltinTransformsDoNotModifyInput(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	transforms := []struct {
+		name string
+		tr   Transform
+		ok   func(*Message) bool
+	}{
+		{"StampTimestamp", StampTimestamp(func() time.Time { return now }), func(m *Message) bool { return m.Timestamp.Equal(now) }},
+		{"AssignID", AssignID(func() string { return "id-1" }), func(m *Message) bool { return m.ID == "id-1" }},
+		{"AssignSeq", AssignSeq(&Sequencer{}), func(m *Message) bool { return m.Seq == 1 }},
+		{"Redact", Redact("monitor_id"), func(m *Message) bool { return string(m.Payload) != "" }},
+	}
+	for _, tt := range transforms {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := NewHeartbeatMessage("mon-1", "up", 1, "")
+			before := *orig
+			out, err := tt.tr(orig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok(out) {
+				t.Errorf("transform did not apply: %+v", out)
+			}
+			if orig.ID != before.ID || orig.Seq != before.Seq || !orig.Timestamp.Equal(before.Timestamp) ||
+				string(orig.Payload) != string(before.Payload) {
+				t.Errorf("input modified: %+v, was %+v", orig, before)
+			}
+		})
+	}
+}
+
+func TestSharedMessageThroughConcurrentPipelines(t *testing.T) {
+	shared := NewHeartbeatMessage("mon-1", "up", 1, "")
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := NewPipeline(AssignID(NewMessageID), AssignSeq(&Sequencer{}), StampTimestamp(time.Now))
+			if _, err := p.Apply(shared); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if shared.ID != "" || shared.Seq != 0 {
+		t.Errorf("shared message was modified: id %q seq %d", shared.ID, shared.Seq)
+	}
+}
+
+func TestNewMessageID(t *testing.T) {
+	a, b := NewMessageID(), NewMessageID()
+	if len(a) != 32 || a == b {
+		t.Errorf("NewMessageID() = %q, %q", a, b)
+	}
+}
@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Priority orders outgoing messages in a PreemptiveSender.
+type Priority int
+
+// Send priorities, from lowest to highest.
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// PreemptiveSender sends large messages as chunks so that a higher-priority
+// message never waits for a lower-priority one to finish: between any two
+// chunks, the sender switches to the highest-priority message queued and
+// resumes the interrupted one afterwards. Messages that fit in one chunk are
+// sent as they are.
+//
+// Enqueue is safe for concurrent use; SendNext and Flush must be called from a
+// single sending goroutine.
+type PreemptiveSender struct {
+	chunkSize int
+	send      func(*Message) error
+
+	mu     sync.Mutex
+	queues [PriorityHigh + 1][]*Message
+}
+
+// NewPreemptiveSender creates a sender that writes frames of at most
+// chunkSize bytes of message data through send. The chunk size must be
+// positive.
+func NewPreemptiveSender(chunkSize int, send func(*Message) error) (*PreemptiveSender, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("preemptive sender: chunk size must be positive, got %d", chunkSize)
+	}
+	return &PreemptiveSender{chunkSize: chunkSize, send: send}, nil
+}
+
+// Enqueue queues m at the given priority, splitting it into chunks if it is
+// larger than the chunk size.
+func (s *PreemptiveSender) Enqueue(m *Message, p Priority) error {
+	if p < PriorityLow || p > PriorityHigh {
+		return fmt.Errorf("preemptive sender: invalid priority %d", p)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	frames := []*Message{m}
+	if len(data) > s.chunkSize {
+		if frames, err = SplitMessage(m, s.chunkSize); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues[p] = append(s.queues[p], frames...)
+	return nil
+}
+
+// SendNext sends the next frame of the highest-priority queued message. It
+// reports false when nothing was queued. A frame that fails to send stays
+// queued.
+func (s *PreemptiveSender) SendNext() (bool, error) {
+	s.mu.Lock()
+	var next *Message
+	p := PriorityHigh
+	for ; p >= PriorityLow; p-- {
+		if len(s.queues[p]) > 0 {
+			next = s.queues[p][0]
+			break
+		}
+	}
+	s.mu.Unlock()
+	if next == nil {
+		return false, nil
+	}
+
+	if err := s.send(next); err != nil {
+		return true, err
+	}
+	s.mu.Lock()
+	s.queues[p] = s.queues[p][1:]
+	s.mu.Unlock()
+	return true, nil
+}
+
+// Flush sends frames until the queues are empty or a send fails.
+func (s *PreemptiveSender) Flush() error {
+	for {
+		sent, err := s.SendNext()
+		if err != nil || !sent {
+			return err
+		}
+	}
+}
+
+// Queued returns the number of frames waiting to be sent.
+func (s *PreemptiveSender) Queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustPreemptiveSender(t *testing.T, chunkSize int, send func(*Message) error) *PreemptiveSender {
+	t.Helper()
+	s, err := NewPreemptiveSender(chunkSize, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPreemptiveSender(t *testing.T) {
+	var sent []*Message
+	s := mustPreemptiveSender(t, 64, func(m *Message) error {
+		sent = append(sent, m)
+		return nil
+	})
+
+	large := NewLogMessage("mon-1", LogLevelInfo, strings.Repeat("x", 500), 0)
+	if err := s.Enqueue(large, PriorityLow); err != nil {
+		t.Fatal(err)
+	}
+	frames := s.Queued()
+	if frames < 2 {
+		t.Fatalf("Queued() = %d, want the large message split into chunks", frames)
+	}
+	// Send part of the large message, then queue an urgent one.
+	for range 2 {
+		if ok, err := s.SendNext(); !ok || err != nil {
+			t.Fatalf("SendNext() = %v, %v", ok, err)
+		}
+	}
+	urgent := NewPingMessage()
+	if err := s.Enqueue(urgent, PriorityHigh); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != frames+1 {
+		t.Fatalf("sent %d frames, want %d", len(sent), frames+1)
+	}
+	if sent[2] != urgent {
+		t.Errorf("frame 2 is %s, want the urgent ping to preempt the chunks", sent[2].Type)
+	}
+	a := NewChunkAssembler()
+	var got *Message
+	for i, m := range sent {
+		if i == 2 {
+			continue
+		}
+		if m.Type != MsgTypeChunk {
+			t.Fatalf("frame %d is %s, want chunk", i, m.Type)
+		}
+		var c ChunkPayload
+		if err := m.ParsePayload(&c); err != nil {
+			t.Fatal(err)
+		}
+		m, err := a.Add(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = m
+	}
+	if got == nil || got.ID == "" || string(got.Payload) != string(large.Payload) {
+		t.Errorf("interrupted message not reassembled: %v", got)
+	}
+	if ok, err := s.SendNext(); ok || err != nil {
+		t.Errorf("SendNext() on an empty sender = %v, %v", ok, err)
+	}
+}
+
+func TestPreemptiveSenderPriorityOrder(t *testing.T) {
+	var sent []string
+	s := mustPreemptiveSender(t, 1024, func(m *Message) error {
+		sent = append(sent, m.Type)
+		return nil
+	})
+	queue := []struct {
+		m *Message
+		p Priority
+	}{
+		{NewLogMessage("", LogLevelInfo, "low", 0), PriorityLow},
+		{NewHeartbeatMessage("mon-1", "up", 10, ""), PriorityNormal},
+		{NewPingMessage(), PriorityHigh},
+		{NewPongMessage(), PriorityNormal},
+	}
+	for _, q := range queue {
+		if err := s.Enqueue(q.m, q.p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{MsgTypePing, MsgTypeHeartbeat, MsgTypePong, MsgTypeLog}
+	if strings.Join(sent, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+}
+
+func TestPreemptiveSenderErrors(t *testing.T) {
+	fail := errors.New("connection closed")
+	s := mustPreemptiveSender(t, 1024, func(*Message) error { return fail })
+	for _, size := range []int{0, -1} {
+		if _, err := NewPreemptiveSender(size, func(*Message) error { return nil }); err == nil {
+			t.Errorf("NewPreemptiveSender() accepted chunk size %d", size)
+		}
+	}
+
+	for _, p := range []Priority{PriorityLow - 1, PriorityHigh + 1} {
+		if err := s.Enqueue(NewPingMessage(), p); err == nil {
+			t.Errorf("Enqueue() accepted priority %d", p)
+		}
+	}
+	if err := s.Enqueue(NewPingMessage(), PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); !errors.Is(err, fail) {
+		t.Errorf("Flush() = %v, want the send error", err)
+	}
+	if s.Queued() != 1 {
+		t.Errorf("Queued() = %d, want the failed frame kept", s.Queued())
+	}
+}
@@ -0,0 +1,53 @@
+package protocol
+
+import (
+	"sync"
+	"time"
+)
+
+// RateBudget caps how often the agent runs one monitor's check, so a
+// misconfigured interval cannot starve every other monitor. It refills
+// continuously at the per-minute rate and allows a full minute's worth at
+// once. Skipped checks are counted for HeartbeatPayload.ThrottledChecks. It
+// is safe for concurrent use.
+type RateBudget struct {
+	limiter *RateLimiter
+
+	mu        sync.Mutex
+	throttled int
+}
+
+// NewRateBudget creates a budget from TaskPayload.MaxChecksPerMinute. Zero
+// means no budget and allows every check.
+func NewRateBudget(maxChecksPerMinute int) *RateBudget {
+	return &RateBudget{
+		limiter: NewRateLimiter(float64(maxChecksPerMinute)/60, maxChecksPerMinute),
+	}
+}
+
+// Allow reports whether a check may run now.
+func (b *RateBudget) Allow() bool {
+	return b.AllowAt(time.Now())
+}
+
+// AllowAt is Allow with an explicit clock reading.
+func (b *RateBudget) AllowAt(now time.Time) bool {
+	if b.limiter.AllowAt(now) {
+		return true
+	}
+	b.mu.Lock()
+	b.throttled++
+	b.mu.Unlock()
+	return false
+}
+
+// TakeThrottled returns how many checks were skipped since the last call and
+// resets the count, ready for the next heartbeat.
+func (b *RateBudget) TakeThrottled() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := b.throttled
+	b.throttled = 0
+	return n
+}
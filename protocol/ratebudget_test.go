@@ -0,0 +1,58 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateBudget(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	b := NewRateBudget(6)
+
+	allowed := 0
+	for range 10 {
+		if b.AllowAt(start) {
+			allowed++
+		}
+	}
+	if allowed != 6 {
+		t.Errorf("allowed %d checks at once, want a minute's worth of 6", allowed)
+	}
+	if got := b.TakeThrottled(); got != 4 {
+		t.Errorf("TakeThrottled() = %d, want 4", got)
+	}
+	if got := b.TakeThrottled(); got != 0 {
+		t.Errorf("TakeThrottled() after reset = %d, want 0", got)
+	}
+
+	// Six per minute refills one check every ten seconds.
+	if b.AllowAt(start.Add(9 * time.Second)) {
+		t.Error("allowed a check before the budget refilled")
+	}
+	if !b.AllowAt(start.Add(10 * time.Second)) {
+		t.Error("rejected a check after the budget refilled")
+	}
+}
+
+func TestRateBudgetUnlimited(t *testing.T) {
+	b := NewRateBudget(0)
+	for range 1000 {
+		if !b.Allow() {
+			t.Fatal("zero budget throttled a check")
+		}
+	}
+	if b.TakeThrottled() != 0 {
+		t.Error("zero budget counted throttled checks")
+	}
+}
+
+func TestMaxChecksPerMinuteValidate(t *testing.T) {
+	task := TaskPayload{MonitorID: "m", Type: "http", MaxChecksPerMinute: -1}
+	if err := task.Validate(); err == nil {
+		t.Error("Validate() accepted a negative max_checks_per_minute")
+	}
+	hb := HeartbeatPayload{MonitorID: "m", Status: "up", ThrottledChecks: -1}
+	if err := hb.Validate(); err == nil {
+		t.Error("Validate() accepted negative throttled_checks")
+	}
+}
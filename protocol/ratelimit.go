@@ -0,0 +1,103 @@
+package protocol
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NegotiateMessageRate returns the rate the hub grants an agent that asked
+// for desired messages per second, given the hub's own per-agent limit. Zero
+// means no preference on either side.
+func NegotiateMessageRate(desired, hubMax int) int {
+	switch {
+	case hubMax <= 0:
+		return max(desired, 0)
+	case desired <= 0:
+		return hubMax
+	}
+	return min(desired, hubMax)
+}
+
+// RateLimiter is a token bucket that refills at a fixed rate up to a burst
+// size. Agents pace sends to the rate granted in auth_ack with it; the hub can
+// use the same limiter to enforce it. It is safe for concurrent use.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rate events per second with bursts
+// of up to burst events. The bucket starts full. A non-positive rate means no
+// limit, matching a zero granted rate in auth_ack.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(max(burst, 1)),
+		tokens: float64(max(burst, 1)),
+	}
+}
+
+// Allow reports whether an event may happen now, consuming a token if so.
+func (l *RateLimiter) Allow() bool {
+	return l.AllowAt(time.Now())
+}
+
+// AllowAt is Allow with an explicit clock reading.
+func (l *RateLimiter) AllowAt(now time.Time) bool {
+	if l.rate <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(now)
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Wait blocks until an event may happen or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	l.refill(time.Now())
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Hand back the token reserved above.
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+}
@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNegotiateMessageRate(t *testing.T) {
+	tests := []struct {
+		desired, hubMax, want int
+	}{
+		{0, 0, 0},
+		{50, 0, 50},
+		{-5, 0, 0},
+		{0, 100, 100},
+		{50, 100, 50},
+		{200, 100, 100},
+	}
+	for _, tt := range tests {
+		if got := NegotiateMessageRate(tt.desired, tt.hubMax); got != tt.want {
+			t.Errorf("NegotiateMessageRate(%d, %d) = %d, want %d", tt.desired, tt.hubMax, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterAllowAt(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	l := NewRateLimiter(2, 3)
+
+	for i := range 3 {
+		if !l.AllowAt(start) {
+			t.Fatalf("burst event %d rejected", i)
+		}
+	}
+	if l.AllowAt(start) {
+		t.Fatal("allowed an event beyond the burst")
+	}
+	// Two tokens per second: one refills after half a second.
+	if l.AllowAt(start.Add(400 * time.Millisecond)) {
+		t.Error("allowed an event before a token refilled")
+	}
+	if !l.AllowAt(start.Add(500 * time.Millisecond)) {
+		t.Error("rejected an event after a token refilled")
+	}
+	// A long pause refills to the burst size and no further.
+	later := start.Add(time.Minute)
+	for i := range 3 {
+		if !l.AllowAt(later) {
+			t.Fatalf("event %d after refill rejected", i)
+		}
+	}
+	if l.AllowAt(later) {
+		t.Error("refill exceeded the burst size")
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	l := NewRateLimiter(0, 1)
+	for range 100 {
+		if !l.Allow() {
+			t.Fatal("unlimited limiter rejected an event")
+		}
+	}
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(100, 1)
+	ctx := context.Background()
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("second Wait returned after %v, want about 10ms", elapsed)
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	l := NewRateLimiter(0.1, 1)
+	if !l.Allow() {
+		t.Fatal("first event rejected")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() = %v, want DeadlineExceeded", err)
+	}
+	// The reserved token is handed back, so the canceled wait does not push
+	// later events further out.
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens > 0.01 || tokens < -0.01 {
+		t.Errorf("tokens after canceled Wait = %v, want about 0", tokens)
+	}
+}
@@ -0,0 +1,141 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ReassignPayload is sent by hub to the agent taking over a set of monitors.
+// With Handoff set, the agent acknowledges with a reassign_ack once it is
+// running the monitors, and only then does the hub cancel them on the agent
+// that previously owned them.
+type ReassignPayload struct {
+	MonitorIDs []string `json:"monitor_ids"`
+	Handoff    bool     `json:"handoff"`
+}
+
+// Validate reports whether the reassignment is well formed.
+func (p ReassignPayload) Validate() error {
+	if len(p.MonitorIDs) == 0 {
+		return errors.New("reassign: monitor_ids must not be empty")
+	}
+	for i, id := range p.MonitorIDs {
+		if id == "" {
+			return fmt.Errorf("reassign: monitor_ids[%d] is empty", i)
+		}
+	}
+	return nil
+}
+
+// ReassignAckPayload is sent by agent once it is running reassigned monitors.
+type ReassignAckPayload struct {
+	MonitorIDs []string `json:"monitor_ids"`
+}
+
+// NewReassignMessage creates a reassignment message.
+func NewReassignMessage(monitorIDs []string, handoff bool) *Message {
+	return MustNewMessage(MsgTypeReassign, ReassignPayload{
+		MonitorIDs: monitorIDs,
+		Handoff:    handoff,
+	})
+}
+
+// NewReassignAckMessage creates a reassignment acknowledgment message.
+func NewReassignAckMessage(monitorIDs []string) *Message {
+	return MustNewMessage(MsgTypeReassignAck, ReassignAckPayload{
+		MonitorIDs: monitorIDs,
+	})
+}
+
+// HandoffPhase is the progress of a single monitor through a handoff.
+type HandoffPhase int
+
+const (
+	// HandoffPending means the new agent has not acknowledged the monitor yet.
+	HandoffPending HandoffPhase = iota
+	// HandoffReady means the new agent is running the monitor and the old
+	// agent can be told to cancel it.
+	HandoffReady
+)
+
+// HandoffCoordinator tracks the two-phase handoff of monitors between agents
+// on the hub side. It is safe for concurrent use.
+type HandoffCoordinator struct {
+	mu     sync.Mutex
+	phases map[string]HandoffPhase
+}
+
+// NewHandoffCoordinator creates an empty coordinator.
+func NewHandoffCoordinator() *HandoffCoordinator {
+	return &HandoffCoordinator{phases: make(map[string]HandoffPhase)}
+}
+
+// Begin records the monitors of a reassignment as pending. It fails if the
+// payload is invalid, is not a handoff, since only handoffs are acked, or a
+// monitor is already being handed off.
+func (c *HandoffCoordinator) Begin(p ReassignPayload) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if !p.Handoff {
+		return errors.New("reassign: only handoff reassignments are coordinated")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range p.MonitorIDs {
+		if _, ok := c.phases[id]; ok {
+			return fmt.Errorf("reassign: monitor %s is already being handed off", id)
+		}
+	}
+	for _, id := range p.MonitorIDs {
+		c.phases[id] = HandoffPending
+	}
+	return nil
+}
+
+// Ack marks acknowledged monitors as ready and returns the ones whose
+// task_cancel can now be sent to the old agent. Monitors that are unknown or
+// were already acknowledged are ignored.
+func (c *HandoffCoordinator) Ack(p ReassignAckPayload) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ready []string
+	for _, id := range p.MonitorIDs {
+		if phase, ok := c.phases[id]; ok && phase == HandoffPending {
+			c.phases[id] = HandoffReady
+			ready = append(ready, id)
+		}
+	}
+	return ready
+}
+
+// Complete forgets a monitor once its cancellation has been sent to the old
+// agent.
+func (c *HandoffCoordinator) Complete(monitorID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.phases, monitorID)
+}
+
+// Abort drops the given monitors from the coordinator without completing
+// their handoff, e.g. when the new agent disconnects mid-way.
+func (c *HandoffCoordinator) Abort(monitorIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range monitorIDs {
+		delete(c.phases, id)
+	}
+}
+
+// Phase returns the handoff phase of a monitor and whether it is being
+// handed off at all.
+func (c *HandoffCoordinator) Phase(monitorID string) (HandoffPhase, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	phase, ok := c.phases[monitorID]
+	return phase, ok
+}
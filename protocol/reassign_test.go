@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestReassignValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       ReassignPayload
+		wantErr bool
+	}{
+		{"valid", ReassignPayload{MonitorIDs: []string{"a", "b"}, Handoff: true}, false},
+		{"empty", ReassignPayload{}, true},
+		{"blank id", ReassignPayload{MonitorIDs: []string{"a", ""}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandoffCoordinatorTwoPhase(t *testing.T) {
+	c := NewHandoffCoordinator()
+	if err := c.Begin(ReassignPayload{MonitorIDs: []string{"a", "b", "c"}, Handoff: true}); err != nil {
+		t.Fatal(err)
+	}
+	if phase, ok := c.Phase("a"); !ok || phase != HandoffPending {
+		t.Fatalf("Phase(a) = %v, %v; want pending", phase, ok)
+	}
+
+	// A partial ack releases only the acknowledged monitors, and a repeated
+	// or unknown ack releases nothing.
+	if ready := c.Ack(ReassignAckPayload{MonitorIDs: []string{"a", "x"}}); !slices.Equal(ready, []string{"a"}) {
+		t.Errorf("Ack(a, x) = %v, want [a]", ready)
+	}
+	if ready := c.Ack(ReassignAckPayload{MonitorIDs: []string{"a"}}); len(ready) != 0 {
+		t.Errorf("repeated Ack(a) = %v, want none", ready)
+	}
+	if phase, _ := c.Phase("a"); phase != HandoffReady {
+		t.Errorf("Phase(a) = %v, want ready", phase)
+	}
+
+	c.Complete("a")
+	if _, ok := c.Phase("a"); ok {
+		t.Error("completed monitor is still tracked")
+	}
+
+	c.Abort("b", "c")
+	if ready := c.Ack(ReassignAckPayload{MonitorIDs: []string{"b", "c"}}); len(ready) != 0 {
+		t.Errorf("Ack after Abort = %v, want none", ready)
+	}
+}
+
+func TestHandoffCoordinatorRejectsOverlap(t *testing.T) {
+	c := NewHandoffCoordinator()
+	if err := c.Begin(ReassignPayload{MonitorIDs: []string{"a"}, Handoff: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Begin(ReassignPayload{MonitorIDs: []string{"b", "a"}, Handoff: true}); err == nil {
+		t.Fatal("Begin accepted a monitor already being handed off")
+	}
+	// The rejected batch must not have been partially applied.
+	if _, ok := c.Phase("b"); ok {
+		t.Error("rejected Begin left monitor b pending")
+	}
+	if err := c.Begin(ReassignPayload{}); err == nil {
+		t.Error("Begin accepted an invalid payload")
+	}
+	if err := c.Begin(ReassignPayload{MonitorIDs: []string{"c"}}); err == nil {
+		t.Error("Begin accepted a reassignment that will never be acked")
+	}
+	if _, ok := c.Phase("c"); ok {
+		t.Error("non-handoff reassignment left monitor c pending")
+	}
+}
+
+func TestHandoffCoordinatorConcurrentAck(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	c := NewHandoffCoordinator()
+	if err := c.Begin(ReassignPayload{MonitorIDs: ids, Handoff: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var released []string
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ready := c.Ack(ReassignAckPayload{MonitorIDs: ids})
+			mu.Lock()
+			released = append(released, ready...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(released)
+	if !slices.Equal(released, ids) {
+		t.Errorf("released %v, want each monitor exactly once", released)
+	}
+}
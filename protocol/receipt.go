@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrInvalidReceipt is returned by VerifyReceipt when a receipt's signature
+// does not match its contents.
+var ErrInvalidReceipt = errors.New("receipt signature is invalid")
+
+// ReceiptPayload is sent by hub to acknowledge a critical message with a
+// signature the agent can keep as proof of delivery. ForSeq and ForDigest
+// identify the message by its Seq and MessageDigest; Signature is the
+// base64-encoded ed25519 signature over both.
+type ReceiptPayload struct {
+	ForSeq    uint64 `json:"for_seq"`
+	ForDigest string `json:"for_digest"`
+	Signature string `json:"signature"`
+}
+
+// Validate reports whether the receipt identifies a message and is signed.
+func (p ReceiptPayload) Validate() error {
+	if p.ForDigest == "" {
+		return errors.New("receipt: for_digest is required")
+	}
+	if p.Signature == "" {
+		return errors.New("receipt: signature is required")
+	}
+	return nil
+}
+
+// receiptSigningInput is the byte string a receipt signature covers. The
+// prefix keeps receipt signatures from being valid for anything else signed
+// with the same key.
+func receiptSigningInput(forSeq uint64, forDigest string) []byte {
+	return []byte("watchdog-receipt:v1:" + strconv.FormatUint(forSeq, 10) + ":" + forDigest)
+}
+
+// NewReceiptMessage creates a receipt for the message with the given
+// sequence number and digest, signed with the hub's key.
+func NewReceiptMessage(forSeq uint64, forDigest string, key ed25519.PrivateKey) (*Message, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("receipt: signing key must be %d bytes, got %d", ed25519.PrivateKeySize, len(key))
+	}
+	sig := ed25519.Sign(key, receiptSigningInput(forSeq, forDigest))
+	return NewMessage(MsgTypeReceipt, ReceiptPayload{
+		ForSeq:    forSeq,
+		ForDigest: forDigest,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	})
+}
+
+// VerifyReceipt checks a receipt against the hub's ed25519 public key. A
+// receipt whose signature does not match returns ErrInvalidReceipt.
+func VerifyReceipt(r ReceiptPayload, key []byte) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("receipt: public key must be %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidReceipt, err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), receiptSigningInput(r.ForSeq, r.ForDigest), sig) {
+		return ErrInvalidReceipt
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestReceipt(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, otherPriv, _ := ed25519.GenerateKey(nil)
+
+	critical := NewHeartbeatMessage("mon-1", "down", 0, "connection refused")
+	critical.Seq = 7
+	digest, err := MessageDigest(critical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewReceiptMessage(critical.Seq, digest, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var receipt ReceiptPayload
+	if err := m.ParsePayload(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyReceipt(receipt, pub); err != nil {
+		t.Fatalf("VerifyReceipt() = %v on a genuine receipt", err)
+	}
+
+	forged, _ := NewReceiptMessage(critical.Seq, digest, otherPriv)
+	var forgedReceipt ReceiptPayload
+	forged.ParsePayload(&forgedReceipt)
+
+	wrongDigest := digest[:len(digest)-1] + "0"
+	if wrongDigest == digest {
+		wrongDigest = digest[:len(digest)-1] + "1"
+	}
+	sig, _ := base64.StdEncoding.DecodeString(receipt.Signature)
+	sig[0] ^= 1
+	tests := []struct {
+		name   string
+		modify func(*ReceiptPayload)
+		key    []byte
+	}{
+		{"wrong seq", func(r *ReceiptPayload) { r.ForSeq = 8 }, pub},
+		{"wrong digest", func(r *ReceiptPayload) { r.ForDigest = wrongDigest }, pub},
+		{"flipped signature bit", func(r *ReceiptPayload) { r.Signature = base64.StdEncoding.EncodeToString(sig) }, pub},
+		{"signature not base64", func(r *ReceiptPayload) { r.Signature = "not base64!" }, pub},
+		{"truncated signature", func(r *ReceiptPayload) { r.Signature = r.Signature[:20] }, pub},
+		{"signed by another key", func(r *ReceiptPayload) { *r = forgedReceipt }, pub},
+		{"verified with another key", func(*ReceiptPayload) {}, otherPub},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := receipt
+			tt.modify(&r)
+			if err := VerifyReceipt(r, tt.key); !errors.Is(err, ErrInvalidReceipt) {
+				t.Errorf("VerifyReceipt() = %v, want ErrInvalidReceipt", err)
+			}
+		})
+	}
+}
+
+func TestReceiptKeyAndPayloadErrors(t *testing.T) {
+	if _, err := NewReceiptMessage(1, "d", ed25519.PrivateKey("short")); err == nil {
+		t.Error("NewReceiptMessage() accepted a short key")
+	}
+	if err := VerifyReceipt(ReceiptPayload{ForDigest: "d", Signature: "s"}, []byte("short")); err == nil || errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("VerifyReceipt() with a short key = %v, want a key size error", err)
+	}
+	if err := (ReceiptPayload{Signature: "s"}).Validate(); err == nil {
+		t.Error("Validate() accepted a receipt without a digest")
+	}
+	if err := (ReceiptPayload{ForDigest: "d"}).Validate(); err == nil {
+		t.Error("Validate() accepted an unsigned receipt")
+	}
+}
@@ -0,0 +1,108 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ReconcilePolicy decides how heartbeats from several agents checking the same
+// monitor combine into one status.
+type ReconcilePolicy string
+
+// Reconciliation policies.
+const (
+	// ReconcileMajority reports up when more than half the agents see it up.
+	ReconcileMajority ReconcilePolicy = "majority"
+	// ReconcileAnyUp reports up when at least one agent sees it up.
+	ReconcileAnyUp ReconcilePolicy = "any_up"
+	// ReconcileAllUp reports up only when every agent sees it up.
+	ReconcileAllUp ReconcilePolicy = "all_up"
+)
+
+// ReconcileHeartbeats merges heartbeats that several agents reported for the
+// same monitor into one. When the result is up, its latency is the average
+// over the agents that saw the monitor up. Otherwise its status is the most
+// common failing status, the worst one on a tie and the alphabetically first
+// if still tied, and its latency, error and down reason come from the
+// heartbeats with that status. The metadata records how many agents saw the
+// monitor up.
+//
+// Aliases are scoped to one connection, so every heartbeat must carry its
+// monitor ID; resolve aliases with HeartbeatPayload.ResolveAlias first.
+func ReconcileHeartbeats(hbs []HeartbeatPayload, policy ReconcilePolicy) (HeartbeatPayload, error) {
+	if len(hbs) == 0 {
+		return HeartbeatPayload{}, errors.New("reconcile: at least one heartbeat is required")
+	}
+	monitorID := hbs[0].MonitorID
+	for _, hb := range hbs {
+		if hb.MonitorID == "" {
+			return HeartbeatPayload{}, errors.New("reconcile: heartbeats must carry a monitor_id")
+		}
+		if hb.MonitorID != monitorID {
+			return HeartbeatPayload{}, fmt.Errorf("reconcile: heartbeats for different monitors %s and %s", monitorID, hb.MonitorID)
+		}
+	}
+
+	up := 0
+	failing := make(map[MonitorStatus]int)
+	for _, hb := range hbs {
+		if status := MonitorStatus(hb.Status); status.IsUp() {
+			up++
+		} else {
+			failing[status]++
+		}
+	}
+
+	var isUp bool
+	switch policy {
+	case ReconcileMajority:
+		isUp = up*2 > len(hbs)
+	case ReconcileAnyUp:
+		isUp = up > 0
+	case ReconcileAllUp:
+		isUp = up == len(hbs)
+	default:
+		return HeartbeatPayload{}, fmt.Errorf("reconcile: unknown policy %q", policy)
+	}
+
+	status := StatusUp
+	if !isUp {
+		status = ""
+		for s, n := range failing {
+			if status == "" || n > failing[status] {
+				status = s
+				continue
+			}
+			if n < failing[status] {
+				continue
+			}
+			if sev := s.severity(); sev > status.severity() || sev == status.severity() && s < status {
+				status = s
+			}
+		}
+	}
+
+	result := HeartbeatPayload{
+		MonitorID: monitorID,
+		Status:    string(status),
+		Metadata: map[string]string{
+			"agents_up":    strconv.Itoa(up),
+			"agents_total": strconv.Itoa(len(hbs)),
+		},
+	}
+	var latency, matched int
+	for _, hb := range hbs {
+		if MonitorStatus(hb.Status) != status {
+			continue
+		}
+		if matched == 0 {
+			result.ErrorMessage = hb.ErrorMessage
+			result.DownReason = hb.DownReason
+		}
+		latency += hb.LatencyMs
+		matched++
+	}
+	result.LatencyMs = latency / matched
+	return result, nil
+}
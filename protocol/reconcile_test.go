@@ -0,0 +1,78 @@
+package protocol
+
+import "testing"
+
+func TestReconcileHeartbeats(t *testing.T) {
+	up := func(latency int) HeartbeatPayload {
+		return HeartbeatPayload{MonitorID: "m", Status: "up", LatencyMs: latency}
+	}
+	failed := func(status, msg string, latency int) HeartbeatPayload {
+		return HeartbeatPayload{MonitorID: "m", Status: status, ErrorMessage: msg, LatencyMs: latency}
+	}
+	tests := []struct {
+		name        string
+		hbs         []HeartbeatPayload
+		policy      ReconcilePolicy
+		wantStatus  string
+		wantLatency int
+		wantError   string
+		wantUp      string
+	}{
+		{"majority up", []HeartbeatPayload{up(10), up(30), failed("down", "refused", 0)}, ReconcileMajority, "up", 20, "", "2"},
+		{"majority tie is down", []HeartbeatPayload{up(10), failed("down", "refused", 4)}, ReconcileMajority, "down", 4, "refused", "1"},
+		{"any up", []HeartbeatPayload{up(10), failed("down", "refused", 0), failed("down", "refused", 0)}, ReconcileAnyUp, "up", 10, "", "1"},
+		{"all up fails on one", []HeartbeatPayload{up(10), up(10), failed("timeout", "deadline", 5000)}, ReconcileAllUp, "timeout", 5000, "deadline", "2"},
+		{"all up", []HeartbeatPayload{up(10), up(20)}, ReconcileAllUp, "up", 15, "", "2"},
+		{"most common failure wins", []HeartbeatPayload{failed("timeout", "t1", 100), failed("timeout", "t2", 300), failed("down", "d", 0)}, ReconcileMajority, "timeout", 200, "t1", "0"},
+		{"worst failure breaks ties", []HeartbeatPayload{failed("timeout", "t", 100), failed("down", "d", 0)}, ReconcileMajority, "down", 0, "d", "0"},
+		{"single", []HeartbeatPayload{up(7)}, ReconcileMajority, "up", 7, "", "1"},
+	}
+	// Map iteration order varies, so repeat to catch unstable tie-breaks.
+	for range 50 {
+		got, err := ReconcileHeartbeats([]HeartbeatPayload{failed("weird", "w", 0), failed("error", "e", 0)}, ReconcileMajority)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "error" {
+			t.Fatalf("equally severe failures reconciled to %q, want the alphabetically first", got.Status)
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReconcileHeartbeats(tt.hbs, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus || got.LatencyMs != tt.wantLatency || got.ErrorMessage != tt.wantError {
+				t.Errorf("got status %q latency %d error %q, want %q %d %q",
+					got.Status, got.LatencyMs, got.ErrorMessage, tt.wantStatus, tt.wantLatency, tt.wantError)
+			}
+			if got.Metadata["agents_up"] != tt.wantUp {
+				t.Errorf("agents_up = %q, want %q", got.Metadata["agents_up"], tt.wantUp)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("reconciled heartbeat invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestReconcileHeartbeatsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		hbs    []HeartbeatPayload
+		policy ReconcilePolicy
+	}{
+		{"empty", nil, ReconcileMajority},
+		{"mixed monitors", []HeartbeatPayload{{MonitorID: "a", Status: "up"}, {MonitorID: "b", Status: "up"}}, ReconcileMajority},
+		{"unknown policy", []HeartbeatPayload{{MonitorID: "a", Status: "up"}}, "quorum"},
+		{"alias only", []HeartbeatPayload{{Alias: 1, Status: "up"}, {Alias: 1, Status: "up"}}, ReconcileMajority},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReconcileHeartbeats(tt.hbs, tt.policy); err == nil {
+				t.Error("ReconcileHeartbeats() succeeded")
+			}
+		})
+	}
+}
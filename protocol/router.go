@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNoHandler is returned by Router.Dispatch for message types without a
+// registered handler.
+var ErrNoHandler = errors.New("no handler for message type")
+
+// HandlerFunc handles one received message.
+type HandlerFunc func(*Message) error
+
+// Router dispatches received messages to handlers by message type. It is safe
+// for concurrent use.
+type Router struct {
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+}
+
+// NewRouter creates a router without handlers.
+func NewRouter() *Router {
+	return &Router{handlers: make(map[string]HandlerFunc)}
+}
+
+// Handle registers h for msgType, replacing any earlier handler.
+func (r *Router) Handle(msgType string, h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[msgType] = h
+}
+
+// Dispatch calls the handler registered for the message's type and returns
+// its error.
+func (r *Router) Dispatch(m *Message) error {
+	r.mu.RLock()
+	h, ok := r.handlers[m.Type]
+	r.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w %q", ErrNoHandler, m.Type)
+	}
+	return h(m)
+}
+
+// HandleTyped registers a handler that receives the payload already decoded
+// into T, e.g. HandleTyped(r, MsgTypeHeartbeat, func(p HeartbeatPayload)
+// error {...}). A payload that does not decode makes Dispatch return a
+// *DecodeError without calling h.
+func HandleTyped[T any](r *Router, msgType string, h func(T) error) {
+	r.Handle(msgType, func(m *Message) error {
+		var payload T
+		if err := m.ParsePayload(&payload); err != nil {
+			return &DecodeError{MsgType: m.Type, Err: err}
+		}
+		return h(payload)
+	})
+}
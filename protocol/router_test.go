@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRouterDispatch(t *testing.T) {
+	r := NewRouter()
+	var got []string
+	r.Handle(MsgTypePing, func(m *Message) error {
+		got = append(got, "ping")
+		return nil
+	})
+	handlerErr := errors.New("boom")
+	r.Handle(MsgTypePong, func(m *Message) error { return handlerErr })
+
+	if err := r.Dispatch(NewPingMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Errorf("ping handler called %d times", len(got))
+	}
+	if err := r.Dispatch(NewPongMessage()); !errors.Is(err, handlerErr) {
+		t.Errorf("Dispatch() = %v, want the handler's error", err)
+	}
+	if err := r.Dispatch(NewTaskCancelMessage("m")); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("Dispatch() = %v, want ErrNoHandler", err)
+	}
+
+	r.Handle(MsgTypePing, func(m *Message) error {
+		got = append(got, "replaced")
+		return nil
+	})
+	r.Dispatch(NewPingMessage())
+	if got[len(got)-1] != "replaced" {
+		t.Error("Handle did not replace the earlier handler")
+	}
+}
+
+func TestHandleTyped(t *testing.T) {
+	r := NewRouter()
+	var got HeartbeatPayload
+	HandleTyped(r, MsgTypeHeartbeat, func(p HeartbeatPayload) error {
+		got = p
+		return nil
+	})
+
+	if err := r.Dispatch(NewHeartbeatMessage("mon-1", "up", 12, "")); err != nil {
+		t.Fatal(err)
+	}
+	if got.MonitorID != "mon-1" || got.LatencyMs != 12 {
+		t.Errorf("handler got %+v", got)
+	}
+
+	got = HeartbeatPayload{}
+	err := r.Dispatch(&Message{Type: MsgTypeHeartbeat, Payload: []byte(`{"latency_ms":"slow"}`)})
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.MsgType != MsgTypeHeartbeat {
+		t.Errorf("Dispatch() = %v, want a *DecodeError", err)
+	}
+	if got.MonitorID != "" || got.LatencyMs != 0 {
+		t.Error("handler called for an undecodable payload")
+	}
+}
+
+func TestRouterConcurrent(t *testing.T) {
+	r := NewRouter()
+	r.Handle(MsgTypePing, func(*Message) error { return nil })
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				r.Dispatch(NewPingMessage())
+			}
+		})
+		wg.Go(func() { r.Handle(MsgTypePong, func(*Message) error { return nil }) })
+	}
+	wg.Wait()
+}
@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"math"
+	"sync"
+)
+
+// criticalTypes are never sampled away: losing any of them breaks the
+// session or loses configuration.
+var criticalTypes = map[string]bool{
+	MsgTypeAuth:            true,
+	MsgTypeAuthAck:         true,
+	MsgTypeAuthError:       true,
+	MsgTypeTask:            true,
+	MsgTypeTaskCancel:      true,
+	MsgTypeError:           true,
+	MsgTypeUpdateAvailable: true,
+	MsgTypeReassign:        true,
+	MsgTypeReassignAck:     true,
+	MsgTypeStateReport:     true,
+	MsgTypeInjectFault:     true,
+	MsgTypeTaskBatch:       true,
+	MsgTypeTaskBatchAck:    true,
+	MsgTypeDrain:           true,
+	MsgTypeDrained:         true,
+	MsgTypeReceipt:         true,
+	MsgTypeTaskReject:      true,
+	MsgTypeTransition:      true,
+	MsgTypeTransitionAck:   true,
+	MsgTypeChunk:           true,
+}
+
+// Sampler sheds load by processing only a fraction of high-volume message
+// types. Rates are per message type, from 0 (drop all) to 1 (keep all); types
+// without a rate are always processed, as are critical types regardless of
+// the rate table. Sampling is deterministic: a rate of 0.1 processes exactly
+// the first of every ten messages. It is safe for concurrent use.
+type Sampler struct {
+	mu     sync.Mutex
+	rates  map[string]float64
+	credit map[string]float64
+}
+
+// NewSampler creates a sampler from a table of per-type rates. Rates outside
+// 0 to 1 are clamped, and a NaN rate is ignored so its type is always
+// processed.
+func NewSampler(rates map[string]float64) *Sampler {
+	s := &Sampler{
+		rates:  make(map[string]float64, len(rates)),
+		credit: make(map[string]float64, len(rates)),
+	}
+	for msgType, rate := range rates {
+		if math.IsNaN(rate) {
+			continue
+		}
+		s.rates[msgType] = min(max(rate, 0), 1)
+		s.credit[msgType] = 1
+	}
+	return s
+}
+
+// ShouldProcess reports whether the next message of the given type should be
+// processed.
+func (s *Sampler) ShouldProcess(msgType string) bool {
+	if criticalTypes[msgType] {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rate, ok := s.rates[msgType]
+	if !ok || rate >= 1 {
+		return true
+	}
+	// Allow for float rounding so e.g. ten additions of 0.1 reach one.
+	keep := s.credit[msgType] >= 1-1e-9
+	if keep {
+		s.credit[msgType]--
+	}
+	s.credit[msgType] += rate
+	return keep
+}
@@ -0,0 +1,98 @@
+package protocol
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestSamplerRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		msgType string
+		rate    float64
+		want    int
+	}{
+		{"keep a tenth", MsgTypeHeartbeat, 0.1, 10},
+		{"keep a quarter", MsgTypeLog, 0.25, 25},
+		{"keep all", MsgTypeHeartbeat, 1, 100},
+		{"drop all", MsgTypeHeartbeat, 0, 1},
+		{"rate above one", MsgTypeHeartbeat, 3, 100},
+		{"negative rate", MsgTypeHeartbeat, -1, 1},
+		{"NaN rate", MsgTypeHeartbeat, math.NaN(), 100},
+		{"critical ignores rate", MsgTypeTask, 0, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSampler(map[string]float64{tt.msgType: tt.rate})
+			kept := 0
+			for range 100 {
+				if s.ShouldProcess(tt.msgType) {
+					kept++
+				}
+			}
+			// A zero rate still keeps the very first message: the credit
+			// starts full so sampling never begins with a gap.
+			if kept != tt.want {
+				t.Errorf("kept %d of 100, want %d", kept, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplerIsDeterministic(t *testing.T) {
+	s := NewSampler(map[string]float64{MsgTypeHeartbeat: 0.5})
+	for i := range 10 {
+		if got, want := s.ShouldProcess(MsgTypeHeartbeat), i%2 == 0; got != want {
+			t.Fatalf("message %d: ShouldProcess = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSamplerUnlistedTypesPass(t *testing.T) {
+	s := NewSampler(map[string]float64{MsgTypeHeartbeat: 0})
+	for range 10 {
+		if !s.ShouldProcess(MsgTypeLog) {
+			t.Fatal("a type without a rate was sampled")
+		}
+	}
+}
+
+func TestSamplerNeverDropsCriticalTypes(t *testing.T) {
+	rates := make(map[string]float64)
+	for msgType := range criticalTypes {
+		rates[msgType] = 0
+	}
+	s := NewSampler(rates)
+	for msgType := range criticalTypes {
+		for range 5 {
+			if !s.ShouldProcess(msgType) {
+				t.Errorf("critical type %s was dropped", msgType)
+			}
+		}
+	}
+}
+
+func TestSamplerConcurrent(t *testing.T) {
+	s := NewSampler(map[string]float64{MsgTypeHeartbeat: 0.1})
+	var mu sync.Mutex
+	kept := 0
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if s.ShouldProcess(MsgTypeHeartbeat) {
+					mu.Lock()
+					kept++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if kept != 100 {
+		t.Errorf("kept %d of 1000 at rate 0.1, want exactly 100", kept)
+	}
+}
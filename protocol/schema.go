@@ -0,0 +1,331 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is the version of the wire protocol defined by this package.
+const ProtocolVersion = "1.0.0"
+
+// payloadTypes maps every message type to the payload it carries. Types
+// without a payload, such as ping and pong, map to nil. New message types
+// must be added here so they appear in the schema.
+var payloadTypes = map[string]any{
+	MsgTypeAuth:            AuthPayload{},
+	MsgTypeAuthAck:         AuthAckPayload{},
+	MsgTypeAuthError:       AuthErrorPayload{},
+	MsgTypeTask:            TaskPayload{},
+	MsgTypeHeartbeat:       HeartbeatPayload{},
+	MsgTypePing:            nil,
+	MsgTypePong:            nil,
+	MsgTypeTaskCancel:      TaskCancelPayload{},
+	MsgTypeError:           ErrorPayload{},
+	MsgTypeUpdateAvailable: UpdateAvailablePayload{},
+	MsgTypeDiscoveryTask:   DiscoveryTaskPayload{},
+	MsgTypeDiscoveryResult: DiscoveryResultPayload{},
+	MsgTypeCachedResult:    CachedResultPayload{},
+	MsgTypeReassign:        ReassignPayload{},
+	MsgTypeReassignAck:     ReassignAckPayload{},
+	MsgTypeAgentInfo:       AgentInfoPayload{},
+	MsgTypeStateReport:     StateReportPayload{},
+	MsgTypeHeartbeatBatch:  HeartbeatBatchPayload{},
+	MsgTypeStepResult:      StepResultPayload{},
+	MsgTypeLog:             LogPayload{},
+	MsgTypeCertInfo:        CertInfoPayload{},
+	MsgTypeInjectFault:     InjectFaultPayload{},
+	MsgTypeTaskBatch:       TaskBatchPayload{},
+	MsgTypeTaskBatchAck:    BatchResult{},
+	MsgTypeDrain:           DrainPayload{},
+	MsgTypeDrained:         DrainedPayload{},
+	MsgTypeReceipt:         ReceiptPayload{},
+	MsgTypeTaskReject:      TaskRejectPayload{},
+	MsgTypeTransition:      TransitionPayload{},
+	MsgTypeTransitionAck:   TransitionAckPayload{},
+	MsgTypeHistoryBatch:    HistoryBatchPayload{},
+	MsgTypeChunk:           ChunkPayload{},
+	MsgTypeUpdateStatus:    UpdateStatusPayload{},
+	MsgTypeListTasks:       ListTasksPayload{},
+	MsgTypeTaskList:        TaskListPayload{},
+}
+
+// Field is one wire field of a payload. Nested fields use dotted names and
+// elements of lists are marked with "[]", e.g. "devices[].ip".
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Schema describes the payload fields of every message type in one protocol
+// version.
+type Schema struct {
+	Version  string             `json:"version"`
+	Messages map[string][]Field `json:"messages"`
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[string]Schema)
+)
+
+// BuildSchema derives a schema from a map of message types to payload values,
+// in the same shape as the package's own registry.
+func BuildSchema(version string, payloads map[string]any) Schema {
+	s := Schema{Version: version, Messages: make(map[string][]Field, len(payloads))}
+	for msgType, payload := range payloads {
+		var fields []Field
+		if payload != nil {
+			fields = collectFields(reflect.TypeOf(payload), "", fields)
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		s.Messages[msgType] = fields
+	}
+	return s
+}
+
+// CurrentSchema returns the schema of the payloads defined by this package.
+func CurrentSchema() Schema {
+	return BuildSchema(ProtocolVersion, payloadTypes)
+}
+
+// ErrSchemaMismatch is returned by VerifySchemaHash when the peer was built
+// against different payload definitions.
+var ErrSchemaMismatch = errors.New("schema hash mismatch")
+
+// Hash returns a digest of the message types and their fields. The version is
+// not included, so two builds agree exactly when their wire contracts do.
+func (s Schema) Hash() string {
+	types := make([]string, 0, len(s.Messages))
+	for msgType := range s.Messages {
+		types = append(types, msgType)
+	}
+	sort.Strings(types)
+
+	h := sha256.New()
+	for _, msgType := range types {
+		fmt.Fprintf(h, "%s\n", msgType)
+		for _, f := range s.Messages[msgType] {
+			fmt.Fprintf(h, "\t%s %s\n", f.Name, f.Type)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ComputeSchemaHash returns the hash of the payload definitions compiled into
+// this binary, for AuthPayload.SchemaHash and AuthAckPayload.SchemaHash.
+func ComputeSchemaHash() string {
+	return CurrentSchema().Hash()
+}
+
+// VerifySchemaHash compares the schema hash a peer sent at auth with our own.
+// A mismatch wraps ErrSchemaMismatch; lenient peers log it, strict ones
+// reject the connection. A peer that sent no hash predates schema hashes and
+// is not checked.
+func VerifySchemaHash(remote string) error {
+	if remote == "" {
+		return nil
+	}
+	if local := ComputeSchemaHash(); remote != local {
+		return fmt.Errorf("%w: peer %s, local %s", ErrSchemaMismatch, remote, local)
+	}
+	return nil
+}
+
+// RegisterSchema makes the schema of another protocol version available to
+// SchemaDiff. The version must be a semantic version and is stored in
+// canonical form, so "v1.1.0" and "1.1.0" name the same schema. The current
+// version is always registered.
+func RegisterSchema(s Schema) error {
+	if s.Version == "" {
+		return errors.New("schema: version is required")
+	}
+	v, err := ParseVersion(s.Version)
+	if err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	s.Version = v.String()
+	if s.Version == ProtocolVersion {
+		return fmt.Errorf("schema: version %s is the current version", s.Version)
+	}
+
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	if _, ok := schemas[s.Version]; ok {
+		return fmt.Errorf("schema: version %s is already registered", s.Version)
+	}
+	schemas[s.Version] = s
+	return nil
+}
+
+// LookupSchema returns the registered schema for a protocol version. A
+// version that is not valid semver is never registered.
+func LookupSchema(version string) (Schema, bool) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return Schema{}, false
+	}
+	version = v.String()
+	if version == ProtocolVersion {
+		return CurrentSchema(), true
+	}
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	s, ok := schemas[version]
+	return s, ok
+}
+
+// FieldChange describes a payload field that differs between two versions.
+type FieldChange struct {
+	MsgType string `json:"msg_type"`
+	Field   string `json:"field"`
+	OldType string `json:"old_type,omitempty"`
+	NewType string `json:"new_type,omitempty"`
+}
+
+// SchemaChanges lists the differences between two protocol versions.
+type SchemaChanges struct {
+	From          string        `json:"from"`
+	To            string        `json:"to"`
+	AddedTypes    []string      `json:"added_types,omitempty"`
+	RemovedTypes  []string      `json:"removed_types,omitempty"`
+	AddedFields   []FieldChange `json:"added_fields,omitempty"`
+	RemovedFields []FieldChange `json:"removed_fields,omitempty"`
+	ChangedFields []FieldChange `json:"changed_fields,omitempty"`
+}
+
+// Empty reports whether the two versions have the same wire contract.
+func (c SchemaChanges) Empty() bool {
+	return len(c.AddedTypes) == 0 && len(c.RemovedTypes) == 0 &&
+		len(c.AddedFields) == 0 && len(c.RemovedFields) == 0 && len(c.ChangedFields) == 0
+}
+
+// String renders the changes one per line: "+" for additions, "-" for
+// removals and "~" for type changes.
+func (c SchemaChanges) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "schema %s -> %s\n", c.From, c.To)
+	for _, t := range c.AddedTypes {
+		fmt.Fprintf(&b, "+ message %s\n", t)
+	}
+	for _, t := range c.RemovedTypes {
+		fmt.Fprintf(&b, "- message %s\n", t)
+	}
+	for _, f := range c.AddedFields {
+		fmt.Fprintf(&b, "+ %s.%s (%s)\n", f.MsgType, f.Field, f.NewType)
+	}
+	for _, f := range c.RemovedFields {
+		fmt.Fprintf(&b, "- %s.%s (%s)\n", f.MsgType, f.Field, f.OldType)
+	}
+	for _, f := range c.ChangedFields {
+		fmt.Fprintf(&b, "~ %s.%s: %s -> %s\n", f.MsgType, f.Field, f.OldType, f.NewType)
+	}
+	return b.String()
+}
+
+// SchemaDiff reports what changed from protocol version v1 to v2. A version
+// that is not registered is treated as having no message types at all.
+func SchemaDiff(v1, v2 string) SchemaChanges {
+	from, _ := LookupSchema(v1)
+	to, _ := LookupSchema(v2)
+	changes := SchemaChanges{From: v1, To: v2}
+
+	for msgType, newFields := range to.Messages {
+		oldFields, ok := from.Messages[msgType]
+		if !ok {
+			changes.AddedTypes = append(changes.AddedTypes, msgType)
+			continue
+		}
+
+		old := make(map[string]string, len(oldFields))
+		for _, f := range oldFields {
+			old[f.Name] = f.Type
+		}
+		for _, f := range newFields {
+			oldType, ok := old[f.Name]
+			switch {
+			case !ok:
+				changes.AddedFields = append(changes.AddedFields, FieldChange{MsgType: msgType, Field: f.Name, NewType: f.Type})
+			case oldType != f.Type:
+				changes.ChangedFields = append(changes.ChangedFields, FieldChange{MsgType: msgType, Field: f.Name, OldType: oldType, NewType: f.Type})
+			}
+			delete(old, f.Name)
+		}
+		for name, oldType := range old {
+			changes.RemovedFields = append(changes.RemovedFields, FieldChange{MsgType: msgType, Field: name, OldType: oldType})
+		}
+	}
+	for msgType := range from.Messages {
+		if _, ok := to.Messages[msgType]; !ok {
+			changes.RemovedTypes = append(changes.RemovedTypes, msgType)
+		}
+	}
+
+	sort.Strings(changes.AddedTypes)
+	sort.Strings(changes.RemovedTypes)
+	sortFieldChanges(changes.AddedFields)
+	sortFieldChanges(changes.RemovedFields)
+	sortFieldChanges(changes.ChangedFields)
+	return changes
+}
+
+func sortFieldChanges(fc []FieldChange) {
+	sort.Slice(fc, func(i, j int) bool {
+		if fc[i].MsgType != fc[j].MsgType {
+			return fc[i].MsgType < fc[j].MsgType
+		}
+		return fc[i].Field < fc[j].Field
+	})
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// collectFields appends the JSON fields of t, flattening nested structs so a
+// change inside a nested type shows up as a change to the payload.
+func collectFields(t reflect.Type, prefix string, fields []Field) []Field {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		name = prefix + name
+
+		ft := sf.Type
+		elem := ft
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		switch {
+		case elem.Kind() == reflect.Struct && elem != timeType:
+			fields = collectFields(elem, name+".", fields)
+		case elem.Kind() == reflect.Slice && structElem(elem.Elem()):
+			fields = collectFields(elem.Elem(), name+"[].", fields)
+		default:
+			fields = append(fields, Field{Name: name, Type: ft.String()})
+		}
+	}
+	return fields
+}
+
+func structElem(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
@@ -0,0 +1,179 @@
+package protocol
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type schemaTestNested struct {
+	IP   string `json:"ip"`
+	Port int    `json:"port,omitempty"`
+}
+
+type schemaTestPayload struct {
+	Name    string             `json:"name"`
+	Skipped string             `json:"-"`
+	Inner   *schemaTestNested  `json:"inner,omitempty"`
+	Devices []schemaTestNested `json:"devices"`
+}
+
+// registerTestSchema registers s for the duration of the test.
+func registerTestSchema(t *testing.T, s Schema) error {
+	t.Helper()
+	err := RegisterSchema(s)
+	if err == nil {
+		t.Cleanup(func() {
+			v, _ := ParseVersion(s.Version)
+			schemasMu.Lock()
+			delete(schemas, v.String())
+			schemasMu.Unlock()
+		})
+	}
+	return err
+}
+
+func TestBuildSchemaFlattensNestedFields(t *testing.T) {
+	s := BuildSchema("9.0.0", map[string]any{"x": schemaTestPayload{}, "empty": nil})
+
+	var names []string
+	for _, f := range s.Messages["x"] {
+		names = append(names, f.Name)
+	}
+	want := []string{"devices[].ip", "devices[].port", "inner.ip", "inner.port", "name"}
+	if !slices.Equal(names, want) {
+		t.Errorf("fields = %v, want %v", names, want)
+	}
+	if fields, ok := s.Messages["empty"]; !ok || len(fields) != 0 {
+		t.Errorf("payload-less type = %v, %v; want present with no fields", fields, ok)
+	}
+}
+
+func TestCurrentSchemaCoversEveryType(t *testing.T) {
+	s := CurrentSchema()
+	if s.Version != ProtocolVersion {
+		t.Errorf("Version = %q, want %q", s.Version, ProtocolVersion)
+	}
+	for msgType := range payloadTypes {
+		if _, ok := s.Messages[msgType]; !ok {
+			t.Errorf("schema is missing %s", msgType)
+		}
+	}
+}
+
+func TestSchemaDiff(t *testing.T) {
+	old := CurrentSchema()
+	old.Version = "0.90.0"
+	old.Messages = map[string][]Field{
+		MsgTypeHeartbeat: {{Name: "monitor_id", Type: "string"}, {Name: "latency_ms", Type: "string"}, {Name: "legacy", Type: "bool"}},
+		"retired":        nil,
+	}
+	if err := registerTestSchema(t, old); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := SchemaDiff("0.90.0", ProtocolVersion)
+	if !slices.Contains(changes.AddedTypes, MsgTypeTask) {
+		t.Errorf("AddedTypes = %v, want it to include task", changes.AddedTypes)
+	}
+	if !slices.Equal(changes.RemovedTypes, []string{"retired"}) {
+		t.Errorf("RemovedTypes = %v, want [retired]", changes.RemovedTypes)
+	}
+	if !slices.Contains(changes.RemovedFields, FieldChange{MsgType: MsgTypeHeartbeat, Field: "legacy", OldType: "bool"}) {
+		t.Errorf("RemovedFields = %v, want heartbeat.legacy", changes.RemovedFields)
+	}
+	if !slices.Contains(changes.ChangedFields, FieldChange{MsgType: MsgTypeHeartbeat, Field: "latency_ms", OldType: "string", NewType: "int"}) {
+		t.Errorf("ChangedFields = %v, want heartbeat.latency_ms string -> int", changes.ChangedFields)
+	}
+	if changes.Empty() {
+		t.Error("Empty() = true for differing schemas")
+	}
+	if out := changes.String(); !strings.Contains(out, "~ heartbeat.latency_ms: string -> int") {
+		t.Errorf("String() = %q", out)
+	}
+
+	if same := SchemaDiff(ProtocolVersion, ProtocolVersion); !same.Empty() {
+		t.Errorf("diff of a version with itself = %v", same)
+	}
+}
+
+func TestSchemaVersionsAreCanonical(t *testing.T) {
+	if err := registerTestSchema(t, Schema{Version: "v0.91.0"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"0.91.0", "v0.91.0"} {
+		s, ok := LookupSchema(v)
+		if !ok || s.Version != "0.91.0" {
+			t.Errorf("LookupSchema(%q) = %q, %v; want the canonical 0.91.0", v, s.Version, ok)
+		}
+	}
+	if err := registerTestSchema(t, Schema{Version: "0.91.0"}); err == nil {
+		t.Error("the same version registered twice under different spellings")
+	}
+
+	tests := []struct {
+		name    string
+		version string
+	}{
+		{"empty", ""},
+		{"not semver", "latest"},
+		{"current", ProtocolVersion},
+		{"current with prefix", "v" + ProtocolVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := registerTestSchema(t, Schema{Version: tt.version}); err == nil {
+				t.Errorf("RegisterSchema(%q) succeeded", tt.version)
+			}
+		})
+	}
+	if _, ok := LookupSchema("latest"); ok {
+		t.Error("LookupSchema accepted an invalid version")
+	}
+}
+
+func TestSchemaHash(t *testing.T) {
+	base := BuildSchema("9.0.0", map[string]any{"x": schemaTestPayload{}})
+	if got := ComputeSchemaHash(); got != CurrentSchema().Hash() || len(got) != 64 {
+		t.Errorf("ComputeSchemaHash() = %q, want the hex SHA-256 of the current schema", got)
+	}
+
+	tests := []struct {
+		name string
+		s    Schema
+		same bool
+	}{
+		{"same payloads", BuildSchema("9.0.0", map[string]any{"x": schemaTestPayload{}}), true},
+		{"different version", BuildSchema("9.1.0", map[string]any{"x": schemaTestPayload{}}), true},
+		{"field added", BuildSchema("9.0.0", map[string]any{"x": struct {
+			schemaTestPayload
+			Extra string `json:"extra"`
+		}{}}), false},
+		{"field type changed", BuildSchema("9.0.0", map[string]any{"x": struct {
+			Name    int                `json:"name"`
+			Inner   *schemaTestNested  `json:"inner,omitempty"`
+			Devices []schemaTestNested `json:"devices"`
+		}{}}), false},
+		{"type added", BuildSchema("9.0.0", map[string]any{"x": schemaTestPayload{}, "y": nil}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.Hash() == base.Hash(); got != tt.same {
+				t.Errorf("hash equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestVerifySchemaHash(t *testing.T) {
+	if err := VerifySchemaHash(""); err != nil {
+		t.Errorf("VerifySchemaHash(\"\") = %v, want nil for peers without hashes", err)
+	}
+	if err := VerifySchemaHash(ComputeSchemaHash()); err != nil {
+		t.Errorf("VerifySchemaHash(local) = %v", err)
+	}
+	if err := VerifySchemaHash(strings.Repeat("0", 64)); !errors.Is(err, ErrSchemaMismatch) {
+		t.Errorf("VerifySchemaHash(other) = %v, want ErrSchemaMismatch", err)
+	}
+}
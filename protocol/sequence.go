@@ -0,0 +1,249 @@
+package protocol
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sequencer hands out increasing sequence numbers for Message.Seq, starting
+// at 1. The zero value is ready to use and safe for concurrent use.
+type Sequencer struct {
+	last atomic.Uint64
+}
+
+// Next returns the next sequence number.
+func (s *Sequencer) Next() uint64 {
+	return s.last.Add(1)
+}
+
+// AssignSeq returns a transform that stamps messages without a sequence
+// number from s.
+func AssignSeq(s *Sequencer) Transform {
+	return func(m *Message) (*Message, error) {
+		if m.Seq != 0 {
+			return m, nil
+		}
+		out := *m
+		out.Seq = s.Next()
+		return &out, nil
+	}
+}
+
+// maxTrackedSeqs bounds how many processed sequence numbers a
+// DependencyBuffer remembers. A message depending on an older one than that is
+// held until it expires.
+const maxTrackedSeqs = 4096
+
+// DependencyBuffer holds messages whose AfterSeq names a message that has not
+// been processed yet and releases them once it has. Messages released
+// together come out in the order they must be processed, so a message is
+// always followed by the ones waiting on it. It is safe for concurrent use.
+type DependencyBuffer struct {
+	timeout time.Duration
+
+	mu        sync.Mutex
+	processed *boundedSet[uint64]
+	waiting   map[uint64][]heldMessage
+	held      int
+	budget    *ConnectionBudget
+}
+
+type heldMessage struct {
+	msg   *Message
+	since time.Time
+	size  int
+}
+
+// NewDependencyBuffer creates a buffer that gives up waiting for a dependency
+// after timeout.
+func NewDependencyBuffer(timeout time.Duration) *DependencyBuffer {
+	return &DependencyBuffer{
+		timeout:   timeout,
+		processed: newBoundedSet[uint64](maxTrackedSeqs),
+		waiting:   make(map[uint64][]heldMessage),
+	}
+}
+
+// SetBudget makes the buffer charge held messages against b, releasing each
+// once it comes out. Push always holds and charges even past the cap; TryPush
+// refuses a message the budget cannot hold. Call it before the first push.
+func (b *DependencyBuffer) SetBudget(budget *ConnectionBudget) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.budget = budget
+}
+
+// Push adds a received message and returns every message that is now ready
+// for processing, which may be none if m has to wait. Returned messages count
+// as processed.
+func (b *DependencyBuffer) Push(m *Message) []*Message {
+	return b.PushAt(m, time.Now())
+}
+
+// PushAt is Push with an explicit clock reading.
+func (b *DependencyBuffer) PushAt(m *Message, now time.Time) []*Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.mustWait(m) {
+		size := MessageSize(m)
+		b.budget.hold(size)
+		b.wait(m, now, size)
+		return nil
+	}
+	return b.release(nil, m)
+}
+
+// TryPush is Push, except that it fails without holding m if m has to wait
+// and the budget cannot hold it.
+func (b *DependencyBuffer) TryPush(m *Message) ([]*Message, error) {
+	return b.TryPushAt(m, time.Now())
+}
+
+// TryPushAt is TryPush with an explicit clock reading.
+func (b *DependencyBuffer) TryPushAt(m *Message, now time.Time) ([]*Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.mustWait(m) {
+		size := MessageSize(m)
+		if err := b.budget.Charge(size); err != nil {
+			return nil, fmt.Errorf("dependency buffer: %w", err)
+		}
+		b.wait(m, now, size)
+		return nil, nil
+	}
+	return b.release(nil, m), nil
+}
+
+func (b *DependencyBuffer) mustWait(m *Message) bool {
+	return m.AfterSeq != 0 && !b.processed.has(m.AfterSeq)
+}
+
+func (b *DependencyBuffer) wait(m *Message, now time.Time, size int) {
+	b.waiting[m.AfterSeq] = append(b.waiting[m.AfterSeq], heldMessage{msg: m, since: now, size: size})
+	b.held++
+}
+
+// Expire returns messages that have waited longer than the timeout for their
+// dependency, together with anything waiting on them. As with Push, a message
+// never comes out before the one it depends on. The caller decides whether to
+// process them anyway or drop them; either way they count as processed.
+func (b *DependencyBuffer) Expire(now time.Time) []*Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// A message whose dependency expired too is left in place: releasing the
+	// dependency brings it along, in the right order. Only the roots, whose
+	// dependency is not among the expired messages, are taken out here.
+	expiredSeqs := make(map[uint64]bool)
+	for _, held := range b.waiting {
+		for _, h := range held {
+			if now.Sub(h.since) >= b.timeout && h.msg.Seq != 0 {
+				expiredSeqs[h.msg.Seq] = true
+			}
+		}
+	}
+	var roots []*Message
+	for seq := range b.waiting {
+		if !expiredSeqs[seq] {
+			roots = append(roots, b.takeExpired(seq, now)...)
+		}
+	}
+	slices.SortFunc(roots, func(x, y *Message) int { return cmp.Compare(x.Seq, y.Seq) })
+
+	var ready []*Message
+	for _, m := range roots {
+		ready = b.release(ready, m)
+	}
+
+	// Whatever expired and is still held waits on itself through a cycle of
+	// AfterSeq, which only forged input produces. Break each cycle at its
+	// lowest sequence number.
+	for {
+		var next *Message
+		var nextSeq uint64
+		for seq, held := range b.waiting {
+			for _, h := range held {
+				if now.Sub(h.since) >= b.timeout && (next == nil || h.msg.Seq < next.Seq) {
+					next, nextSeq = h.msg, seq
+				}
+			}
+		}
+		if next == nil {
+			return ready
+		}
+		b.remove(nextSeq, next)
+		ready = b.release(ready, next)
+	}
+}
+
+// takeExpired removes and returns the messages waiting on seq that have timed
+// out.
+func (b *DependencyBuffer) takeExpired(seq uint64, now time.Time) []*Message {
+	var expired []*Message
+	kept := b.waiting[seq][:0]
+	for _, h := range b.waiting[seq] {
+		if now.Sub(h.since) >= b.timeout {
+			expired = append(expired, h.msg)
+			b.unhold(h)
+		} else {
+			kept = append(kept, h)
+		}
+	}
+	if len(kept) == 0 {
+		delete(b.waiting, seq)
+	} else {
+		b.waiting[seq] = kept
+	}
+	return expired
+}
+
+// remove drops m from the messages waiting on seq.
+func (b *DependencyBuffer) remove(seq uint64, m *Message) {
+	b.waiting[seq] = slices.DeleteFunc(b.waiting[seq], func(h heldMessage) bool {
+		if h.msg != m {
+			return false
+		}
+		b.unhold(h)
+		return true
+	})
+	if len(b.waiting[seq]) == 0 {
+		delete(b.waiting, seq)
+	}
+}
+
+func (b *DependencyBuffer) unhold(h heldMessage) {
+	b.held--
+	b.budget.Release(h.size)
+}
+
+// Held returns the number of messages waiting for a dependency.
+func (b *DependencyBuffer) Held() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.held
+}
+
+// release appends m and, transitively, everything waiting on it to ready.
+func (b *DependencyBuffer) release(ready []*Message, m *Message) []*Message {
+	queue := []*Message{m}
+	for len(queue) > 0 {
+		m, queue = queue[0], queue[1:]
+		ready = append(ready, m)
+		if m.Seq == 0 {
+			continue
+		}
+		b.processed.add(m.Seq)
+		for _, h := range b.waiting[m.Seq] {
+			queue = append(queue, h.msg)
+			b.unhold(h)
+		}
+		delete(b.waiting, m.Seq)
+	}
+	return ready
+}
@@ -0,0 +1,205 @@
+package protocol
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func seqMsg(seq, after uint64) *Message {
+	m := NewPingMessage()
+	m.Seq, m.AfterSeq = seq, after
+	return m
+}
+
+func seqs(msgs []*Message) []uint64 {
+	out := make([]uint64, len(msgs))
+	for i, m := range msgs {
+		out[i] = m.Seq
+	}
+	return out
+}
+
+func TestSequencer(t *testing.T) {
+	var s Sequencer
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var got []uint64
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				n := s.Next()
+				mu.Lock()
+				got = append(got, n)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	slices.Sort(got)
+	for i, n := range got {
+		if n != uint64(i+1) {
+			t.Fatalf("sequence numbers not unique and contiguous: got[%d] = %d", i, n)
+		}
+	}
+}
+
+func TestAssignSeq(t *testing.T) {
+	var s Sequencer
+	assign := AssignSeq(&s)
+	in := NewPingMessage()
+	out, err := assign(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Seq != 1 || in.Seq != 0 {
+		t.Errorf("out.Seq = %d, in.Seq = %d, want 1 and 0", out.Seq, in.Seq)
+	}
+	if kept, _ := assign(seqMsg(42, 0)); kept.Seq != 42 {
+		t.Errorf("existing Seq overwritten with %d", kept.Seq)
+	}
+}
+
+func TestDependencyBufferPush(t *testing.T) {
+	tests := []struct {
+		name string
+		push []*Message
+		want [][]uint64 // ready after each push
+		held int
+	}{
+		{
+			name: "independent",
+			push: []*Message{seqMsg(1, 0), seqMsg(2, 0)},
+			want: [][]uint64{{1}, {2}},
+		},
+		{
+			name: "in order",
+			push: []*Message{seqMsg(1, 0), seqMsg(2, 1), seqMsg(3, 2)},
+			want: [][]uint64{{1}, {2}, {3}},
+		},
+		{
+			name: "held then released",
+			push: []*Message{seqMsg(2, 1), seqMsg(1, 0)},
+			want: [][]uint64{{}, {1, 2}},
+		},
+		{
+			name: "chain arriving backwards",
+			push: []*Message{seqMsg(4, 3), seqMsg(3, 2), seqMsg(2, 1), seqMsg(1, 0)},
+			want: [][]uint64{{}, {}, {}, {1, 2, 3, 4}},
+		},
+		{
+			name: "fan out",
+			push: []*Message{seqMsg(2, 1), seqMsg(3, 1), seqMsg(4, 2), seqMsg(1, 0)},
+			want: [][]uint64{{}, {}, {}, {1, 2, 3, 4}},
+		},
+		{
+			name: "missing dependency",
+			push: []*Message{seqMsg(5, 4), seqMsg(6, 5)},
+			want: [][]uint64{{}, {}},
+			held: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewDependencyBuffer(time.Minute)
+			for i, m := range tt.push {
+				if got := seqs(b.Push(m)); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("Push(seq %d) = %v, want %v", m.Seq, got, tt.want[i])
+				}
+			}
+			if got := b.Held(); got != tt.held {
+				t.Errorf("Held() = %d, want %d", got, tt.held)
+			}
+		})
+	}
+}
+
+func TestDependencyBufferExpire(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	b := NewDependencyBuffer(time.Second)
+	b.PushAt(seqMsg(5, 4), start)
+	b.PushAt(seqMsg(9, 8), start.Add(800*time.Millisecond))
+
+	if got := b.Expire(start.Add(500 * time.Millisecond)); len(got) != 0 {
+		t.Fatalf("Expire() before the timeout = %v", seqs(got))
+	}
+	if got := seqs(b.Expire(start.Add(time.Second))); !slices.Equal(got, []uint64{5}) {
+		t.Errorf("Expire() = %v, want [5]", got)
+	}
+	if b.Held() != 1 {
+		t.Errorf("Held() = %d, want 1", b.Held())
+	}
+	// Expired messages count as processed, so their dependents go straight
+	// through.
+	if got := seqs(b.PushAt(seqMsg(6, 5), start.Add(time.Second))); !slices.Equal(got, []uint64{6}) {
+		t.Errorf("dependent of an expired message = %v, want [6]", got)
+	}
+	if got := seqs(b.Expire(start.Add(2 * time.Second))); !slices.Equal(got, []uint64{9}) {
+		t.Errorf("second Expire() = %v, want [9]", got)
+	}
+	if b.Held() != 0 {
+		t.Errorf("Held() = %d, want 0", b.Held())
+	}
+}
+
+func TestDependencyBufferExpireOrder(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name string
+		push []*Message
+		want []uint64
+	}{
+		{
+			name: "chain",
+			push: []*Message{seqMsg(2, 1), seqMsg(3, 2), seqMsg(4, 3)},
+			want: []uint64{2, 3, 4},
+		},
+		{
+			name: "chain pushed backwards",
+			push: []*Message{seqMsg(4, 3), seqMsg(3, 2), seqMsg(2, 1)},
+			want: []uint64{2, 3, 4},
+		},
+		{
+			name: "two chains",
+			push: []*Message{seqMsg(11, 10), seqMsg(3, 2), seqMsg(12, 11), seqMsg(4, 3)},
+			want: []uint64{3, 4, 11, 12},
+		},
+		{
+			name: "forged cycle",
+			push: []*Message{seqMsg(2, 3), seqMsg(3, 2)},
+			want: []uint64{2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration order varies, so repeat to catch ordering bugs.
+			for range 20 {
+				b := NewDependencyBuffer(time.Second)
+				for _, m := range tt.push {
+					if ready := b.PushAt(m, start); len(ready) != 0 {
+						t.Fatalf("Push(seq %d) released %v", m.Seq, seqs(ready))
+					}
+				}
+				if got := seqs(b.Expire(start.Add(time.Second))); !slices.Equal(got, tt.want) {
+					t.Fatalf("Expire() = %v, want %v", got, tt.want)
+				}
+				if b.Held() != 0 {
+					t.Fatalf("Held() = %d after everything expired", b.Held())
+				}
+			}
+		})
+	}
+}
+
+func TestDependencyBufferExpireReleasesFreshDependents(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	b := NewDependencyBuffer(time.Second)
+	b.PushAt(seqMsg(5, 4), start)
+	b.PushAt(seqMsg(6, 5), start.Add(900*time.Millisecond))
+
+	got := seqs(b.Expire(start.Add(time.Second)))
+	if !slices.Equal(got, []uint64{5, 6}) {
+		t.Errorf("Expire() = %v, want [5 6]", got)
+	}
+}
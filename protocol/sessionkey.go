@@ -0,0 +1,82 @@
+package protocol
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// SessionKeySize is the length in bytes of keys from DeriveSessionKey, sized
+// for AES-256.
+const SessionKeySize = 32
+
+// SessionNonceSize is the length in bytes of nonces from NewSessionNonce.
+const SessionNonceSize = 32
+
+const sessionKeyInfo = "watchdog-proto session key v1"
+
+// NewSessionNonce returns a random nonce for the hub to send in
+// AuthAckPayload.SessionNonce.
+func NewSessionNonce() []byte {
+	nonce := make([]byte, SessionNonceSize)
+	rand.Read(nonce)
+	return nonce
+}
+
+// DeriveSessionKey derives a per-session key from the agent's API key and the
+// nonce exchanged at auth using HKDF-SHA256, so payload encryption never uses
+// the long-lived API key directly and each session gets its own key. The same
+// inputs always produce the same key. Pass it to EncryptPayload and
+// DecryptPayload.
+func DeriveSessionKey(apiKey, sessionNonce []byte) []byte {
+	// hkdf.Key only fails for lengths beyond 255 hash blocks.
+	key, err := hkdf.Key(sha256.New, apiKey, sessionNonce, sessionKeyInfo, SessionKeySize)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// ErrPayloadDecrypt is returned by DecryptPayload when the ciphertext was not
+// produced with the same key, or has been modified.
+var ErrPayloadDecrypt = errors.New("payload decryption failed")
+
+// EncryptPayload encrypts a payload with a key from DeriveSessionKey using
+// AES-256-GCM. A fresh random nonce is generated for every call and prepended
+// to the result, so the output is safe to send as is.
+func EncryptPayload(sessionKey, plaintext []byte) ([]byte, error) {
+	aead, err := newSessionAEAD(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nil, plaintext, nil), nil
+}
+
+// DecryptPayload reverses EncryptPayload. It returns ErrPayloadDecrypt if the
+// ciphertext is truncated, tampered with, or sealed under a different key.
+func DecryptPayload(sessionKey, ciphertext []byte) ([]byte, error) {
+	aead, err := newSessionAEAD(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nil, ciphertext, nil)
+	if err != nil {
+		return nil, ErrPayloadDecrypt
+	}
+	return plaintext, nil
+}
+
+func newSessionAEAD(sessionKey []byte) (cipher.AEAD, error) {
+	if len(sessionKey) != SessionKeySize {
+		return nil, fmt.Errorf("session key must be %d bytes, got %d", SessionKeySize, len(sessionKey))
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithRandomNonce(block)
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDeriveSessionKey(t *testing.T) {
+	apiKey := []byte("wd_live_0123456789")
+	nonce := NewSessionNonce()
+	if len(nonce) != SessionNonceSize {
+		t.Fatalf("nonce length = %d, want %d", len(nonce), SessionNonceSize)
+	}
+
+	key := DeriveSessionKey(apiKey, nonce)
+	if len(key) != SessionKeySize {
+		t.Fatalf("key length = %d, want %d", len(key), SessionKeySize)
+	}
+	if !bytes.Equal(key, DeriveSessionKey(apiKey, nonce)) {
+		t.Error("same inputs derived different keys")
+	}
+	if bytes.Equal(key, DeriveSessionKey(apiKey, NewSessionNonce())) {
+		t.Error("different nonces derived the same key")
+	}
+	if bytes.Equal(key, DeriveSessionKey([]byte("wd_live_other"), nonce)) {
+		t.Error("different API keys derived the same key")
+	}
+	if bytes.Contains(key, apiKey) {
+		t.Error("derived key contains the API key")
+	}
+}
+
+func TestPayloadEncryption(t *testing.T) {
+	nonce := NewSessionNonce()
+	key := DeriveSessionKey([]byte("wd_live_0123456789"), nonce)
+	payload := NewHeartbeatMessage("mon-1", "up", 12, "").Payload
+
+	sealed, err := EncryptPayload(key, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("mon-1")) {
+		t.Error("ciphertext contains the plaintext")
+	}
+	again, err := EncryptPayload(key, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sealed, again) {
+		t.Error("two encryptions produced the same ciphertext")
+	}
+
+	opened, err := DecryptPayload(key, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, payload) {
+		t.Errorf("DecryptPayload() = %s, want %s", opened, payload)
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	otherKey := DeriveSessionKey([]byte("wd_live_0123456789"), NewSessionNonce())
+	tests := []struct {
+		name       string
+		key, input []byte
+	}{
+		{"tampered", key, tampered},
+		{"truncated", key, sealed[:len(sealed)/2]},
+		{"empty", key, nil},
+		{"wrong key", otherKey, sealed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecryptPayload(tt.key, tt.input); !errors.Is(err, ErrPayloadDecrypt) {
+				t.Errorf("DecryptPayload() error = %v, want ErrPayloadDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestPayloadEncryptionKeySize(t *testing.T) {
+	if _, err := EncryptPayload([]byte("short"), []byte("x")); err == nil {
+		t.Error("EncryptPayload accepted a short key")
+	}
+	if _, err := DecryptPayload(make([]byte, 16), []byte("x")); err == nil {
+		t.Error("DecryptPayload accepted an AES-128 key")
+	}
+}
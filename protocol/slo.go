@@ -0,0 +1,86 @@
+package protocol
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// SLOTarget is a monitor's service level objective. A zero field sets no
+// target for that dimension.
+type SLOTarget struct {
+	AvailabilityPct float64 `json:"availability_pct,omitempty"`
+	LatencyP95Ms    int     `json:"latency_p95_ms,omitempty"`
+}
+
+// Validate reports whether the targets are in range.
+func (s SLOTarget) Validate() error {
+	if math.IsNaN(s.AvailabilityPct) || s.AvailabilityPct < 0 || s.AvailabilityPct > 100 {
+		return fmt.Errorf("slo: availability_pct must be between 0 and 100, got %g", s.AvailabilityPct)
+	}
+	if s.LatencyP95Ms < 0 {
+		return fmt.Errorf("slo: latency_p95_ms must be non-negative, got %d", s.LatencyP95Ms)
+	}
+	return nil
+}
+
+// SLOStatus is how a monitor performed against its SLO over a window.
+// BurnRate is how fast the error budget is being used up: 1 means exactly at
+// the target, above 1 means the budget runs out before the period ends. It is
+// zero when there is no availability target or no budget to burn.
+type SLOStatus struct {
+	Samples         int
+	AvailabilityPct float64
+	LatencyP95Ms    int
+	AvailabilityMet bool
+	LatencyMet      bool
+	Met             bool
+	BurnRate        float64
+}
+
+// EvaluateSLO measures a window of heartbeats against slo. Heartbeats the
+// agent marked as expected, i.e. down during maintenance, do not count. The
+// p95 latency is taken over successful checks only, since failed checks
+// often report the timeout rather than a real latency. An empty window
+// meets every target.
+func EvaluateSLO(window []HeartbeatPayload, slo SLOTarget) SLOStatus {
+	var up int
+	var latencies []int
+	var st SLOStatus
+	for _, hb := range window {
+		if hb.Expected {
+			continue
+		}
+		st.Samples++
+		if MonitorStatus(hb.Status).IsUp() {
+			up++
+			latencies = append(latencies, hb.LatencyMs)
+		}
+	}
+
+	st.AvailabilityPct = 100
+	if st.Samples > 0 {
+		st.AvailabilityPct = float64(up) / float64(st.Samples) * 100
+	}
+	st.LatencyP95Ms = percentile(latencies, 95)
+
+	st.AvailabilityMet = st.AvailabilityPct >= slo.AvailabilityPct
+	st.LatencyMet = slo.LatencyP95Ms == 0 || st.LatencyP95Ms <= slo.LatencyP95Ms
+	st.Met = st.AvailabilityMet && st.LatencyMet
+	if slo.AvailabilityPct > 0 && slo.AvailabilityPct < 100 {
+		st.BurnRate = (100 - st.AvailabilityPct) / (100 - slo.AvailabilityPct)
+	}
+	return st
+}
+
+// percentile returns the nearest-rank p-th percentile of values, or 0 for no
+// values.
+func percentile(values []int, p float64) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
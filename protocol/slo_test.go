@@ -0,0 +1,128 @@
+package protocol
+
+import (
+	"math"
+	"testing"
+)
+
+func sloWindow(up, down int, latencyMs int) []HeartbeatPayload {
+	var w []HeartbeatPayload
+	for range up {
+		w = append(w, HeartbeatPayload{MonitorID: "m", Status: "up", LatencyMs: latencyMs})
+	}
+	for range down {
+		w = append(w, HeartbeatPayload{MonitorID: "m", Status: "down", LatencyMs: 5000})
+	}
+	return w
+}
+
+func TestSLOTargetValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		slo     SLOTarget
+		wantErr bool
+	}{
+		{"none", SLOTarget{}, false},
+		{"both", SLOTarget{AvailabilityPct: 99.9, LatencyP95Ms: 300}, false},
+		{"negative availability", SLOTarget{AvailabilityPct: -1}, true},
+		{"availability over 100", SLOTarget{AvailabilityPct: 100.1}, true},
+		{"negative latency", SLOTarget{LatencyP95Ms: -1}, true},
+		{"nan availability", SLOTarget{AvailabilityPct: math.NaN()}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.slo.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluateSLO(t *testing.T) {
+	maintenance := append(sloWindow(9, 0, 100), HeartbeatPayload{MonitorID: "m", Status: "down", Expected: true})
+	slowTail := append(sloWindow(19, 0, 100), HeartbeatPayload{MonitorID: "m", Status: "up", LatencyMs: 900})
+	tests := []struct {
+		name   string
+		window []HeartbeatPayload
+		slo    SLOTarget
+		want   SLOStatus
+	}{
+		{
+			name: "empty window",
+			slo:  SLOTarget{AvailabilityPct: 99, LatencyP95Ms: 200},
+			want: SLOStatus{AvailabilityPct: 100, AvailabilityMet: true, LatencyMet: true, Met: true},
+		},
+		{
+			name:   "all up within targets",
+			window: sloWindow(10, 0, 100),
+			slo:    SLOTarget{AvailabilityPct: 99, LatencyP95Ms: 200},
+			want:   SLOStatus{Samples: 10, AvailabilityPct: 100, LatencyP95Ms: 100, AvailabilityMet: true, LatencyMet: true, Met: true},
+		},
+		{
+			name:   "availability missed",
+			window: sloWindow(9, 1, 100),
+			slo:    SLOTarget{AvailabilityPct: 95},
+			want:   SLOStatus{Samples: 10, AvailabilityPct: 90, LatencyP95Ms: 100, LatencyMet: true, BurnRate: 2},
+		},
+		{
+			name:   "down checks ignored for latency",
+			window: sloWindow(9, 1, 100),
+			slo:    SLOTarget{LatencyP95Ms: 100},
+			want:   SLOStatus{Samples: 10, AvailabilityPct: 90, LatencyP95Ms: 100, AvailabilityMet: true, LatencyMet: true, Met: true},
+		},
+		{
+			name:   "latency missed",
+			window: sloWindow(10, 0, 250),
+			slo:    SLOTarget{AvailabilityPct: 99, LatencyP95Ms: 200},
+			want:   SLOStatus{Samples: 10, AvailabilityPct: 100, LatencyP95Ms: 250, AvailabilityMet: true},
+		},
+		{
+			name:   "p95 ignores the slowest 5%",
+			window: slowTail,
+			slo:    SLOTarget{LatencyP95Ms: 100},
+			want:   SLOStatus{Samples: 20, AvailabilityPct: 100, LatencyP95Ms: 100, AvailabilityMet: true, LatencyMet: true, Met: true},
+		},
+		{
+			name:   "expected downtime not counted",
+			window: maintenance,
+			slo:    SLOTarget{AvailabilityPct: 100},
+			want:   SLOStatus{Samples: 9, AvailabilityPct: 100, LatencyP95Ms: 100, AvailabilityMet: true, LatencyMet: true, Met: true},
+		},
+		{
+			name:   "no budget to burn",
+			window: sloWindow(9, 1, 100),
+			slo:    SLOTarget{AvailabilityPct: 100},
+			want:   SLOStatus{Samples: 10, AvailabilityPct: 90, LatencyP95Ms: 100, LatencyMet: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateSLO(tt.window, tt.slo)
+			if math.Abs(got.BurnRate-tt.want.BurnRate) < 1e-9 {
+				got.BurnRate = tt.want.BurnRate
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateSLO() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		values []int
+		p      float64
+		want   int
+	}{
+		{nil, 95, 0},
+		{[]int{7}, 95, 7},
+		{[]int{5, 1, 4, 2, 3}, 50, 3},
+		{[]int{5, 1, 4, 2, 3}, 100, 5},
+		{[]int{5, 1, 4, 2, 3}, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.values, tt.p); got != tt.want {
+			t.Errorf("percentile(%v, %g) = %d, want %d", tt.values, tt.p, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// MaxStateReportMonitors caps the number of monitors in a single state report.
+const MaxStateReportMonitors = 10000
+
+// ConfigHash returns a stable hash of the task configuration. Two tasks with
+// the same hash run identical checks. The alias and config revision are left
+// out: the alias only names the monitor on one connection and may be
+// renumbered on reconnect, and the revision is bumped for the agent's whole
+// config, not just this task, so neither changes the check.
+//
+// The task should pass Validate, which rejects the values JSON cannot encode:
+// a NaN SLO target or a maintenance window outside the years 0 to 9999. A
+// task that cannot be encoded has an empty hash, which DiffState never treats
+// as matching.
+func (t TaskPayload) ConfigHash() string {
+	t.Alias = 0
+	t.ConfigRevision = 0
+	// Map keys are sorted on encode, so the encoding is deterministic.
+	data, err := json.Marshal(t)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// MonitorState is the agent's view of one monitor it is running.
+type MonitorState struct {
+	MonitorID  string `json:"monitor_id"`
+	ConfigHash string `json:"config_hash"`
+	LastStatus string `json:"last_status,omitempty"`
+}
+
+// StateReportPayload is sent by agent right after re-authenticating with the
+// monitors it is still running, so the hub only needs to send what changed.
+type StateReportPayload struct {
+	Monitors []MonitorState `json:"monitors"`
+}
+
+// Validate reports whether the state report is well formed.
+func (p StateReportPayload) Validate() error {
+	if len(p.Monitors) > MaxStateReportMonitors {
+		return fmt.Errorf("state_report: %d monitors exceeds limit of %d", len(p.Monitors), MaxStateReportMonitors)
+	}
+	for i, m := range p.Monitors {
+		if m.MonitorID == "" {
+			return fmt.Errorf("state_report: monitors[%d].monitor_id is required", i)
+		}
+	}
+	return nil
+}
+
+// StateDiff lists what the hub must send to bring an agent in line with its
+// authoritative task set.
+type StateDiff struct {
+	Assign []TaskPayload
+	Cancel []string
+}
+
+// DiffState compares an agent's state report with the tasks the hub wants it
+// to run. Tasks the agent lacks or runs with a different configuration are
+// assigned, as are tasks without a config hash; monitors the agent runs that
+// the hub no longer wants are cancelled.
+func DiffState(report StateReportPayload, tasks []TaskPayload) StateDiff {
+	reported := make(map[string]string, len(report.Monitors))
+	for _, m := range report.Monitors {
+		reported[m.MonitorID] = m.ConfigHash
+	}
+
+	var diff StateDiff
+	wanted := make(map[string]struct{}, len(tasks))
+	for _, t := range tasks {
+		wanted[t.MonitorID] = struct{}{}
+		hash, ok := reported[t.MonitorID]
+		if want := t.ConfigHash(); !ok || want == "" || hash != want {
+			diff.Assign = append(diff.Assign, t)
+		}
+	}
+	for _, m := range report.Monitors {
+		if _, ok := wanted[m.MonitorID]; !ok {
+			diff.Cancel = append(diff.Cancel, m.MonitorID)
+		}
+	}
+	return diff
+}
+
+// NewStateReportMessage creates a state report message.
+func NewStateReportMessage(monitors []MonitorState) *Message {
+	return MustNewMessage(MsgTypeStateReport, StateReportPayload{
+		Monitors: monitors,
+	})
+}
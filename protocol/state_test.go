@@ -0,0 +1,108 @@
+package protocol
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestConfigHash(t *testing.T) {
+	base := TaskPayload{MonitorID: "m", Type: "http", Target: "https://example.com", Interval: 30, Timeout: 5,
+		Metadata: map[string]string{"a": "1", "b": "2"}}
+
+	same := base
+	same.Metadata = map[string]string{"b": "2", "a": "1"}
+	if base.ConfigHash() != same.ConfigHash() {
+		t.Error("ConfigHash depends on map insertion order")
+	}
+
+	changed := base
+	changed.Interval = 60
+	if base.ConfigHash() == changed.ConfigHash() {
+		t.Error("ConfigHash ignores the interval")
+	}
+
+	aliased := base
+	aliased.Alias = 7
+	if base.ConfigHash() != aliased.ConfigHash() {
+		t.Error("ConfigHash depends on the connection-scoped alias")
+	}
+	if aliased.Alias != 7 {
+		t.Error("ConfigHash modified the task")
+	}
+
+	revised := base
+	revised.ConfigRevision = 12
+	if base.ConfigHash() != revised.ConfigHash() {
+		t.Error("ConfigHash depends on the config revision")
+	}
+
+	invalid := base
+	invalid.SLO = &SLOTarget{AvailabilityPct: math.NaN()}
+	if h := invalid.ConfigHash(); h != "" {
+		t.Errorf("ConfigHash() of a task JSON cannot encode = %q, want empty", h)
+	}
+	report := StateReportPayload{Monitors: []MonitorState{{MonitorID: "m"}}}
+	if diff := DiffState(report, []TaskPayload{invalid}); len(diff.Assign) != 1 {
+		t.Errorf("DiffState() matched an empty config hash: %+v", diff)
+	}
+}
+
+func TestDiffState(t *testing.T) {
+	http := TaskPayload{MonitorID: "http", Type: "http", Target: "https://example.com", Interval: 30}
+	tcp := TaskPayload{MonitorID: "tcp", Type: "tcp", Target: "db:5432", Interval: 10}
+	ping := TaskPayload{MonitorID: "ping", Type: "ping", Target: "10.0.0.1", Interval: 60}
+	dns := TaskPayload{MonitorID: "dns", Type: "dns", Target: "example.com", Interval: 60}
+	tcpChanged := tcp
+	tcpChanged.Interval = 20
+
+	report := StateReportPayload{Monitors: []MonitorState{
+		{MonitorID: "http", ConfigHash: http.ConfigHash()},
+		{MonitorID: "tcp", ConfigHash: tcp.ConfigHash()},
+		{MonitorID: "ping", ConfigHash: ping.ConfigHash()},
+	}}
+
+	tests := []struct {
+		name       string
+		tasks      []TaskPayload
+		wantAssign []string
+		wantCancel []string
+	}{
+		{"in sync", []TaskPayload{http, tcp, ping}, nil, nil},
+		{"new monitor", []TaskPayload{http, tcp, ping, dns}, []string{"dns"}, nil},
+		{"changed config", []TaskPayload{http, tcpChanged, ping}, []string{"tcp"}, nil},
+		{"removed monitors", []TaskPayload{http}, nil, []string{"tcp", "ping"}},
+		{"hub has nothing", nil, nil, []string{"http", "tcp", "ping"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffState(report, tt.tasks)
+			var assigned []string
+			for _, task := range diff.Assign {
+				assigned = append(assigned, task.MonitorID)
+			}
+			if !slices.Equal(assigned, tt.wantAssign) {
+				t.Errorf("Assign = %v, want %v", assigned, tt.wantAssign)
+			}
+			if !slices.Equal(diff.Cancel, tt.wantCancel) {
+				t.Errorf("Cancel = %v, want %v", diff.Cancel, tt.wantCancel)
+			}
+		})
+	}
+}
+
+func TestStateReportValidate(t *testing.T) {
+	if err := (StateReportPayload{Monitors: []MonitorState{{ConfigHash: "x"}}}).Validate(); err == nil {
+		t.Error("Validate() accepted a monitor without an ID")
+	}
+	big := StateReportPayload{Monitors: make([]MonitorState, MaxStateReportMonitors+1)}
+	for i := range big.Monitors {
+		big.Monitors[i].MonitorID = "m"
+	}
+	if err := big.Validate(); err == nil {
+		t.Error("Validate() accepted more than MaxStateReportMonitors monitors")
+	}
+	if err := NewStateReportMessage([]MonitorState{{MonitorID: "m", ConfigHash: "h"}}).Validate(); err != nil {
+		t.Errorf("valid state report rejected: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package protocol
+
+// MonitorStatus is the outcome of a check as reported in heartbeats.
+type MonitorStatus string
+
+// Check outcomes.
+const (
+	StatusUp      MonitorStatus = "up"
+	StatusDown    MonitorStatus = "down"
+	StatusTimeout MonitorStatus = "timeout"
+	StatusError   MonitorStatus = "error"
+)
+
+// IsUp reports whether the status means the monitor is healthy. Every other
+// status, including timeouts and errors, counts as down.
+func (s MonitorStatus) IsUp() bool {
+	return s == StatusUp
+}
+
+// severity orders statuses from healthy to worst, for picking the worst of
+// several results. Unrecognized statuses rank with errors.
+func (s MonitorStatus) severity() int {
+	switch s {
+	case StatusUp:
+		return 0
+	case StatusTimeout:
+		return 1
+	case StatusDown:
+		return 3
+	}
+	return 2
+}
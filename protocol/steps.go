@@ -0,0 +1,191 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxPendingTransactions bounds how many incomplete transactions a
+// StepAccumulator holds at once. Starting another evicts the oldest.
+const maxPendingTransactions = 1024
+
+// StepResultPayload is streamed by agent as each step of a multi-step
+// synthetic check completes. Steps are numbered from 1 and the last one has
+// Final set.
+type StepResultPayload struct {
+	RequestID string `json:"request_id"`
+	Step      int    `json:"step"`
+	Name      string `json:"name"`
+	Success   bool   `json:"success"`
+	LatencyMs int    `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Final     bool   `json:"final,omitempty"`
+}
+
+// Validate reports whether the step result is well formed.
+func (p StepResultPayload) Validate() error {
+	if p.RequestID == "" {
+		return errors.New("step_result: request_id is required")
+	}
+	if p.Step < 1 {
+		return fmt.Errorf("step_result: step must be at least 1, got %d", p.Step)
+	}
+	if p.Name == "" {
+		return errors.New("step_result: name is required")
+	}
+	if p.LatencyMs < 0 {
+		return fmt.Errorf("step_result: latency_ms must be non-negative, got %d", p.LatencyMs)
+	}
+	return nil
+}
+
+// NewStepResultMessage creates a step result message. errMsg explains a
+// failed step and is empty for one that succeeded.
+func NewStepResultMessage(requestID string, step int, name string, success bool, latencyMs int, errMsg string, final bool) *Message {
+	return MustNewMessage(MsgTypeStepResult, StepResultPayload{
+		RequestID: requestID,
+		Step:      step,
+		Name:      name,
+		Success:   success,
+		LatencyMs: latencyMs,
+		Error:     errMsg,
+		Final:     final,
+	})
+}
+
+// TransactionResult is a complete multi-step check assembled from its steps.
+type TransactionResult struct {
+	RequestID string
+	Steps     []StepResultPayload
+	Success   bool
+	LatencyMs int
+}
+
+// StepAccumulator assembles streamed step results into transactions, keyed by
+// request ID. It holds at most 1024 incomplete transactions, evicting the
+// oldest to start another; Expire drops those whose final step never came.
+// It is safe for concurrent use.
+type StepAccumulator struct {
+	mu      sync.Mutex
+	pending map[string]*pendingTransaction
+}
+
+type pendingTransaction struct {
+	steps   []StepResultPayload
+	started time.Time
+}
+
+// NewStepAccumulator creates an empty accumulator.
+func NewStepAccumulator() *StepAccumulator {
+	return &StepAccumulator{pending: make(map[string]*pendingTransaction)}
+}
+
+// Add records a step. Once the final step arrives it returns the assembled
+// transaction and forgets the request. Steps must arrive in order with no
+// gaps; a step that is out of order or skips ahead discards the whole
+// transaction and returns an error, since its result can no longer be
+// trusted.
+func (a *StepAccumulator) Add(p StepResultPayload) (*TransactionResult, error) {
+	return a.AddAt(p, time.Now())
+}
+
+// AddAt is Add with an explicit clock reading.
+func (a *StepAccumulator) AddAt(p StepResultPayload, now time.Time) (*TransactionResult, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var steps []StepResultPayload
+	if t, ok := a.pending[p.RequestID]; ok {
+		steps = t.steps
+	}
+	if want := len(steps) + 1; p.Step != want {
+		delete(a.pending, p.RequestID)
+		return nil, fmt.Errorf("step_result: request %s: got step %d, want %d", p.RequestID, p.Step, want)
+	}
+	steps = append(steps, p)
+	if !p.Final {
+		if t, ok := a.pending[p.RequestID]; ok {
+			t.steps = steps
+			return nil, nil
+		}
+		if len(a.pending) >= maxPendingTransactions {
+			delete(a.pending, a.oldest())
+		}
+		a.pending[p.RequestID] = &pendingTransaction{steps: steps, started: now}
+		return nil, nil
+	}
+
+	delete(a.pending, p.RequestID)
+	result := &TransactionResult{RequestID: p.RequestID, Steps: steps, Success: true}
+	for _, s := range steps {
+		result.Success = result.Success && s.Success
+		result.LatencyMs += s.LatencyMs
+	}
+	return result, nil
+}
+
+// Pending returns the request IDs of transactions still waiting for their
+// final step.
+func (a *StepAccumulator) Pending() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ids := make([]string, 0, len(a.pending))
+	for id := range a.pending {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Expire drops the transactions whose first step arrived more than maxAge
+// ago and returns how many it dropped.
+func (a *StepAccumulator) Expire(maxAge time.Duration) int {
+	return a.ExpireAt(time.Now(), maxAge)
+}
+
+// ExpireAt is Expire with an explicit clock reading.
+func (a *StepAccumulator) ExpireAt(now time.Time, maxAge time.Duration) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for id, t := range a.pending {
+		if now.Sub(t.started) > maxAge {
+			delete(a.pending, id)
+			n++
+		}
+	}
+	return n
+}
+
+// Discard drops an incomplete transaction, e.g. when the agent reports the
+// check timed out, and returns the steps received so far.
+func (a *StepAccumulator) Discard(requestID string) []StepResultPayload {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	t, ok := a.pending[requestID]
+	if !ok {
+		return nil
+	}
+	delete(a.pending, requestID)
+	return t.steps
+}
+
+// oldest returns the request ID of the incomplete transaction started first.
+func (a *StepAccumulator) oldest() string {
+	var id string
+	var started time.Time
+	for k, t := range a.pending {
+		if id == "" || t.started.Before(started) {
+			id, started = k, t.started
+		}
+	}
+	return id
+}
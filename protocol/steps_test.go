@@ -0,0 +1,231 @@
+package protocol
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStepResultValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    StepResultPayload
+		wantErr bool
+	}{
+		{"valid", StepResultPayload{RequestID: "r", Step: 1, Name: "login"}, false},
+		{"no request id", StepResultPayload{Step: 1, Name: "login"}, true},
+		{"step zero", StepResultPayload{RequestID: "r", Name: "login"}, true},
+		{"negative step", StepResultPayload{RequestID: "r", Step: -1, Name: "login"}, true},
+		{"no name", StepResultPayload{RequestID: "r", Step: 1}, true},
+		{"negative latency", StepResultPayload{RequestID: "r", Step: 1, Name: "login", LatencyMs: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.step.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStepAccumulator(t *testing.T) {
+	step := func(n int, success, final bool) StepResultPayload {
+		return StepResultPayload{RequestID: "r1", Step: n, Name: "step", Success: success, LatencyMs: 10 * n, Final: final}
+	}
+	tests := []struct {
+		name        string
+		steps       []StepResultPayload
+		wantErrAt   int // index of the step that fails, or -1
+		wantSuccess bool
+		wantLatency int
+		wantPending bool
+	}{
+		{
+			name:        "in order",
+			steps:       []StepResultPayload{step(1, true, false), step(2, true, false), step(3, true, true)},
+			wantErrAt:   -1,
+			wantSuccess: true,
+			wantLatency: 60,
+		},
+		{
+			name:        "single final step",
+			steps:       []StepResultPayload{step(1, true, true)},
+			wantErrAt:   -1,
+			wantSuccess: true,
+			wantLatency: 10,
+		},
+		{
+			name:        "failed step fails the transaction",
+			steps:       []StepResultPayload{step(1, true, false), step(2, false, true)},
+			wantErrAt:   -1,
+			wantLatency: 30,
+		},
+		{
+			name:      "out of order",
+			steps:     []StepResultPayload{step(1, true, false), step(3, true, false)},
+			wantErrAt: 1,
+		},
+		{
+			name:      "skipped first step",
+			steps:     []StepResultPayload{step(2, true, true)},
+			wantErrAt: 0,
+		},
+		{
+			name:      "replayed step",
+			steps:     []StepResultPayload{step(1, true, false), step(1, true, false)},
+			wantErrAt: 1,
+		},
+		{
+			name:      "forged step without name",
+			steps:     []StepResultPayload{step(1, true, false), {RequestID: "r1", Step: 2, Final: true}},
+			wantErrAt: 1,
+			// An invalid step is rejected before it touches state, so the
+			// transaction is still waiting for a real step 2.
+			wantPending: true,
+		},
+		{
+			name:        "incomplete",
+			steps:       []StepResultPayload{step(1, true, false)},
+			wantErrAt:   -1,
+			wantPending: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewStepAccumulator()
+			var result *TransactionResult
+			for i, s := range tt.steps {
+				got, err := a.Add(s)
+				if (err != nil) != (i == tt.wantErrAt) {
+					t.Fatalf("Add(step %d) error = %v, want error %v", s.Step, err, i == tt.wantErrAt)
+				}
+				if got != nil {
+					result = got
+				}
+			}
+			if pending := len(a.Pending()) > 0; pending != tt.wantPending {
+				t.Errorf("pending = %v, want %v", pending, tt.wantPending)
+			}
+			if tt.wantErrAt >= 0 || tt.wantPending {
+				if result != nil {
+					t.Errorf("got a result for a broken transaction: %+v", result)
+				}
+				return
+			}
+			if result == nil {
+				t.Fatal("no result after the final step")
+			}
+			if result.Success != tt.wantSuccess || result.LatencyMs != tt.wantLatency || len(result.Steps) != len(tt.steps) {
+				t.Errorf("result = %+v, want success %v latency %d", result, tt.wantSuccess, tt.wantLatency)
+			}
+		})
+	}
+}
+
+func TestStepAccumulatorDiscard(t *testing.T) {
+	a := NewStepAccumulator()
+	for _, id := range []string{"r1", "r2"} {
+		if _, err := a.Add(StepResultPayload{RequestID: id, Step: 1, Name: "open"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending := a.Pending()
+	slices.Sort(pending)
+	if !slices.Equal(pending, []string{"r1", "r2"}) {
+		t.Fatalf("Pending() = %v", pending)
+	}
+	if steps := a.Discard("r1"); len(steps) != 1 {
+		t.Errorf("Discard() returned %d steps, want 1", len(steps))
+	}
+	if steps := a.Discard("r1"); steps != nil {
+		t.Errorf("second Discard() = %v, want nil", steps)
+	}
+	if !slices.Equal(a.Pending(), []string{"r2"}) {
+		t.Errorf("Pending() after Discard = %v", a.Pending())
+	}
+}
+
+func TestStepAccumulatorConcurrent(t *testing.T) {
+	a := NewStepAccumulator()
+	var wg sync.WaitGroup
+	results := make(chan *TransactionResult, 50)
+	for i := range 50 {
+		wg.Go(func() {
+			id := string(rune('A' + i))
+			for n := 1; n <= 3; n++ {
+				r, err := a.Add(StepResultPayload{RequestID: id, Step: n, Name: "s", Success: true, Final: n == 3})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if r != nil {
+					results <- r
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(results)
+	var n int
+	for range results {
+		n++
+	}
+	if n != 50 || len(a.Pending()) != 0 {
+		t.Errorf("completed %d transactions with %d pending, want 50 and 0", n, len(a.Pending()))
+	}
+}
+
+func TestStepAccumulatorExpire(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	a := NewStepAccumulator()
+	for i, id := range []string{"r1", "r2"} {
+		if _, err := a.AddAt(StepResultPayload{RequestID: id, Step: 1, Name: "open"}, start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A later step does not restart the clock.
+	if _, err := a.AddAt(StepResultPayload{RequestID: "r1", Step: 2, Name: "login"}, start.Add(20*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := a.ExpireAt(start.Add(30*time.Second), 30*time.Second); n != 0 {
+		t.Errorf("ExpireAt() before the timeout dropped %d", n)
+	}
+	if n := a.ExpireAt(start.Add(30*time.Second+1), 30*time.Second); n != 1 {
+		t.Errorf("ExpireAt() dropped %d, want 1", n)
+	}
+	if !slices.Equal(a.Pending(), []string{"r2"}) {
+		t.Errorf("Pending() = %v, want [r2]", a.Pending())
+	}
+}
+
+func TestStepAccumulatorEvictsOldest(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	a := NewStepAccumulator()
+	for i := range maxPendingTransactions + 1 {
+		p := StepResultPayload{RequestID: fmt.Sprintf("r%d", i), Step: 1, Name: "open"}
+		if _, err := a.AddAt(p, start.Add(time.Duration(i)*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending := a.Pending()
+	if len(pending) != maxPendingTransactions {
+		t.Errorf("Pending() holds %d, want %d", len(pending), maxPendingTransactions)
+	}
+	if slices.Contains(pending, "r0") {
+		t.Error("the oldest transaction was not evicted")
+	}
+}
+
+func TestNewStepResultMessage(t *testing.T) {
+	var p StepResultPayload
+	if err := NewStepResultMessage("r", 2, "login", false, 80, "401 Unauthorized", true).ParsePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	want := StepResultPayload{RequestID: "r", Step: 2, Name: "login", LatencyMs: 80, Error: "401 Unauthorized", Final: true}
+	if p != want {
+		t.Errorf("payload = %+v, want %+v", p, want)
+	}
+}
@@ -0,0 +1,316 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Wire encodings selectable with the watchdog struct tag, e.g.
+// `json:"timeout" watchdog:"duration=ms"`. Fields without the tag are encoded
+// exactly as encoding/json would.
+//
+//	duration=ms       time.Duration as integer milliseconds
+//	duration=s        time.Duration as fractional seconds
+//	duration=string   time.Duration as a Go duration string, e.g. "1m30s"
+//	time=unix         time.Time as integer Unix seconds
+//	time=unixms       time.Time as integer Unix milliseconds
+//	time=rfc3339      time.Time as an RFC 3339 string with nanoseconds
+//
+// Tagged fields are honoured in nested structs too, but not inside slices or
+// maps.
+const (
+	encDurationMs     = "duration=ms"
+	encDurationS      = "duration=s"
+	encDurationString = "duration=string"
+	encTimeUnix       = "time=unix"
+	encTimeUnixMs     = "time=unixms"
+	encTimeRFC3339    = "time=rfc3339"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// MarshalTagged encodes a struct, or pointer to one, as JSON, applying the
+// encodings chosen by watchdog struct tags.
+func MarshalTagged(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return []byte("null"), nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("tagged: cannot marshal %s, want a struct", rv.Type())
+	}
+	obj, err := marshalTaggedStruct(rv)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+// UnmarshalTagged decodes JSON produced by MarshalTagged into the struct v
+// points to.
+func UnmarshalTagged(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("tagged: unmarshal target must be a non-nil pointer to a struct")
+	}
+	return unmarshalTaggedStruct(data, rv.Elem())
+}
+
+// NewTaggedMessage is NewMessage with the payload encoded by MarshalTagged.
+func NewTaggedMessage(msgType string, payload any) (*Message, error) {
+	data, err := MarshalTagged(payload)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewMessage(msgType, nil)
+	if err != nil {
+		return nil, err
+	}
+	m.Payload = data
+	return m, nil
+}
+
+// ParseTaggedPayload is ParsePayload for payloads encoded by
+// NewTaggedMessage.
+func (m *Message) ParseTaggedPayload(v any) error {
+	if m.Payload == nil {
+		return nil
+	}
+	return UnmarshalTagged(m.Payload, v)
+}
+
+// taggedField is one JSON member of a struct being encoded.
+type taggedField struct {
+	name      string
+	omitEmpty bool
+	encoding  string
+	value     reflect.Value
+}
+
+// taggedFields lists the JSON members of rv, with untagged embedded structs
+// inlined as encoding/json does.
+func taggedFields(rv reflect.Value) []taggedField {
+	var fields []taggedField
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		jsonTag := sf.Tag.Get("json")
+		name, opts, _ := strings.Cut(jsonTag, ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, taggedFields(rv.Field(i))...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, taggedField{
+			name:      name,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			encoding:  sf.Tag.Get("watchdog"),
+			value:     rv.Field(i),
+		})
+	}
+	return fields
+}
+
+func marshalTaggedStruct(rv reflect.Value) (map[string]any, error) {
+	obj := make(map[string]any)
+	for _, f := range taggedFields(rv) {
+		if f.omitEmpty && isEmptyJSONValue(f.value) {
+			continue
+		}
+		switch {
+		case f.encoding != "":
+			enc, err := encodeTagged(f)
+			if err != nil {
+				return nil, err
+			}
+			obj[f.name] = enc
+		case isNestedStruct(f.value.Type()):
+			nested := f.value
+			if nested.Kind() == reflect.Pointer {
+				if nested.IsNil() {
+					obj[f.name] = nil
+					continue
+				}
+				nested = nested.Elem()
+			}
+			enc, err := marshalTaggedStruct(nested)
+			if err != nil {
+				return nil, err
+			}
+			obj[f.name] = enc
+		default:
+			obj[f.name] = f.value.Interface()
+		}
+	}
+	return obj, nil
+}
+
+// isEmptyJSONValue matches the omitempty rule of encoding/json, under which
+// structs, including times, are never empty.
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
+
+func encodeTagged(f taggedField) (any, error) {
+	switch f.encoding {
+	case encDurationMs, encDurationS, encDurationString:
+		if f.value.Type() != durationType {
+			return nil, tagTypeError(f)
+		}
+		d := time.Duration(f.value.Int())
+		switch f.encoding {
+		case encDurationMs:
+			return d.Milliseconds(), nil
+		case encDurationS:
+			return d.Seconds(), nil
+		}
+		return d.String(), nil
+	case encTimeUnix, encTimeUnixMs, encTimeRFC3339:
+		if f.value.Type() != timeType {
+			return nil, tagTypeError(f)
+		}
+		t := f.value.Interface().(time.Time)
+		switch f.encoding {
+		case encTimeUnix:
+			return t.Unix(), nil
+		case encTimeUnixMs:
+			return t.UnixMilli(), nil
+		}
+		return t.Format(time.RFC3339Nano), nil
+	}
+	return nil, fmt.Errorf("tagged: field %s: unknown encoding %q", f.name, f.encoding)
+}
+
+func unmarshalTaggedStruct(data []byte, rv reflect.Value) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	for _, f := range taggedFields(rv) {
+		raw, ok := obj[f.name]
+		if !ok || bytes.Equal(raw, []byte("null")) {
+			continue
+		}
+		switch {
+		case f.encoding != "":
+			if err := decodeTagged(f, raw); err != nil {
+				return err
+			}
+		case isNestedStruct(f.value.Type()):
+			nested := f.value
+			if nested.Kind() == reflect.Pointer {
+				if nested.IsNil() {
+					nested.Set(reflect.New(nested.Type().Elem()))
+				}
+				nested = nested.Elem()
+			}
+			if err := unmarshalTaggedStruct(raw, nested); err != nil {
+				return fmt.Errorf("tagged: field %s: %w", f.name, err)
+			}
+		default:
+			if err := json.Unmarshal(raw, f.value.Addr().Interface()); err != nil {
+				return fmt.Errorf("tagged: field %s: %w", f.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func decodeTagged(f taggedField, raw json.RawMessage) error {
+	var err error
+	switch f.encoding {
+	case encDurationMs, encDurationS, encDurationString:
+		if f.value.Type() != durationType {
+			return tagTypeError(f)
+		}
+		var d time.Duration
+		switch f.encoding {
+		case encDurationMs:
+			var ms int64
+			err = json.Unmarshal(raw, &ms)
+			d = time.Duration(ms) * time.Millisecond
+		case encDurationS:
+			var s float64
+			err = json.Unmarshal(raw, &s)
+			d = time.Duration(s * float64(time.Second))
+		default:
+			var s string
+			if err = json.Unmarshal(raw, &s); err == nil {
+				d, err = time.ParseDuration(s)
+			}
+		}
+		if err == nil {
+			f.value.SetInt(int64(d))
+		}
+	case encTimeUnix, encTimeUnixMs, encTimeRFC3339:
+		if f.value.Type() != timeType {
+			return tagTypeError(f)
+		}
+		var t time.Time
+		switch f.encoding {
+		case encTimeUnix:
+			var sec int64
+			err = json.Unmarshal(raw, &sec)
+			t = time.Unix(sec, 0)
+		case encTimeUnixMs:
+			var ms int64
+			err = json.Unmarshal(raw, &ms)
+			t = time.UnixMilli(ms)
+		default:
+			var s string
+			if err = json.Unmarshal(raw, &s); err == nil {
+				t, err = time.Parse(time.RFC3339Nano, s)
+			}
+		}
+		if err == nil {
+			f.value.Set(reflect.ValueOf(t))
+		}
+	default:
+		return fmt.Errorf("tagged: field %s: unknown encoding %q", f.name, f.encoding)
+	}
+	if err != nil {
+		return fmt.Errorf("tagged: field %s: %w", f.name, err)
+	}
+	return nil
+}
+
+func tagTypeError(f taggedField) error {
+	return fmt.Errorf("tagged: field %s: encoding %q does not apply to %s", f.name, f.encoding, f.value.Type())
+}
+
+// isNestedStruct reports whether t is a struct, or pointer to one, whose
+// fields MarshalTagged should walk. Types with their own JSON encoding, such
+// as time.Time, are left to encoding/json.
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(jsonMarshalerType) && !t.Implements(jsonMarshalerType)
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
@@ -0,0 +1,175 @@
+package protocol
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type taggedTestInner struct {
+	Wait time.Duration `json:"wait" watchdog:"duration=s"`
+}
+
+type taggedTestEmbedded struct {
+	Since time.Time `json:"since" watchdog:"time=unixms"`
+}
+
+type taggedTestPayload struct {
+	taggedTestEmbedded
+	Name     string           `json:"name"`
+	Timeout  time.Duration    `json:"timeout" watchdog:"duration=ms"`
+	Backoff  time.Duration    `json:"backoff" watchdog:"duration=string"`
+	At       time.Time        `json:"at" watchdog:"time=unix"`
+	Exact    time.Time        `json:"exact" watchdog:"time=rfc3339"`
+	Plain    time.Time        `json:"plain"`
+	Inner    taggedTestInner  `json:"inner"`
+	Optional *taggedTestInner `json:"optional,omitempty"`
+	Skipped  string           `json:"-"`
+	Empty    time.Duration    `json:"empty,omitempty" watchdog:"duration=ms"`
+}
+
+func TestMarshalTagged(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 600_000_000, time.UTC)
+	p := taggedTestPayload{
+		taggedTestEmbedded: taggedTestEmbedded{Since: at},
+		Name:               "m",
+		Timeout:            1500 * time.Millisecond,
+		Backoff:            90 * time.Second,
+		At:                 at,
+		Exact:              at,
+		Plain:              at,
+		Inner:              taggedTestInner{Wait: 2500 * time.Millisecond},
+		Skipped:            "secret",
+	}
+	data, err := MarshalTagged(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"since":   "1767323045600",
+		"name":    `"m"`,
+		"timeout": "1500",
+		"backoff": `"1m30s"`,
+		"at":      "1767323045",
+		"exact":   `"2026-01-02T03:04:05.6Z"`,
+		"plain":   `"2026-01-02T03:04:05.6Z"`,
+		"inner":   `{"wait":2.5}`,
+	}
+	for key, v := range want {
+		if got := string(obj[key]); got != v {
+			t.Errorf("%s = %s, want %s", key, got, v)
+		}
+	}
+	for _, key := range []string{"Skipped", "optional", "empty"} {
+		if _, ok := obj[key]; ok {
+			t.Errorf("%s encoded, want it omitted", key)
+		}
+	}
+
+	var back taggedTestPayload
+	if err := UnmarshalTagged(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	p.Skipped = ""
+	p.At = at.Truncate(time.Second)
+	for _, pair := range [][2]time.Time{{back.Since, p.Since}, {back.At, p.At}, {back.Exact, p.Exact}, {back.Plain, p.Plain}} {
+		if !pair[0].Equal(pair[1]) {
+			t.Errorf("time round trip = %v, want %v", pair[0], pair[1])
+		}
+	}
+	if back.Name != p.Name || back.Timeout != p.Timeout || back.Backoff != p.Backoff || back.Inner != p.Inner || back.Optional != nil {
+		t.Errorf("round trip = %+v, want %+v", back, p)
+	}
+}
+
+func TestTaggedNestedPointer(t *testing.T) {
+	p := taggedTestPayload{Optional: &taggedTestInner{Wait: time.Second}}
+	data, err := MarshalTagged(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"optional":{"wait":1}`) {
+		t.Errorf("MarshalTagged() = %s, want the pointer field tagged too", data)
+	}
+	var back taggedTestPayload
+	if err := UnmarshalTagged(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Optional == nil || back.Optional.Wait != time.Second {
+		t.Errorf("Optional = %+v, want wait 1s", back.Optional)
+	}
+}
+
+func TestTaggedErrors(t *testing.T) {
+	type wrongType struct {
+		N int `json:"n" watchdog:"duration=ms"`
+	}
+	type unknownEncoding struct {
+		D time.Duration `json:"d" watchdog:"duration=hours"`
+	}
+	marshal := []struct {
+		name string
+		v    any
+	}{
+		{"not a struct", 42},
+		{"encoding on wrong type", wrongType{N: 1}},
+		{"unknown encoding", unknownEncoding{D: time.Second}},
+	}
+	for _, tt := range marshal {
+		t.Run("marshal "+tt.name, func(t *testing.T) {
+			if data, err := MarshalTagged(tt.v); err == nil {
+				t.Errorf("MarshalTagged() = %s, want an error", data)
+			}
+		})
+	}
+	if data, err := MarshalTagged((*taggedTestPayload)(nil)); err != nil || string(data) != "null" {
+		t.Errorf("MarshalTagged(nil) = %s, %v, want null", data, err)
+	}
+
+	unmarshal := []struct {
+		name   string
+		data   string
+		target any
+	}{
+		{"not a pointer", `{}`, taggedTestPayload{}},
+		{"nil pointer", `{}`, (*taggedTestPayload)(nil)},
+		{"not json", `{`, &taggedTestPayload{}},
+		{"bad duration", `{"backoff":"soon"}`, &taggedTestPayload{}},
+		{"bad time", `{"exact":"yesterday"}`, &taggedTestPayload{}},
+		{"wrong json type", `{"timeout":"1500"}`, &taggedTestPayload{}},
+		{"bad nested field", `{"inner":{"wait":"x"}}`, &taggedTestPayload{}},
+		{"bad plain field", `{"name":7}`, &taggedTestPayload{}},
+	}
+	for _, tt := range unmarshal {
+		t.Run("unmarshal "+tt.name, func(t *testing.T) {
+			if err := UnmarshalTagged([]byte(tt.data), tt.target); err == nil {
+				t.Error("UnmarshalTagged() succeeded")
+			}
+		})
+	}
+}
+
+func TestTaggedMessage(t *testing.T) {
+	type payload struct {
+		Timeout time.Duration `json:"timeout" watchdog:"duration=ms"`
+	}
+	m, err := NewTaggedMessage(MsgTypePing, payload{Timeout: 3 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Payload) != `{"timeout":3000}` {
+		t.Errorf("Payload = %s", m.Payload)
+	}
+	var got payload
+	if err := m.ParseTaggedPayload(&got); err != nil || got.Timeout != 3*time.Second {
+		t.Errorf("ParseTaggedPayload() = %+v, %v", got, err)
+	}
+	if err := NewPingMessage().ParseTaggedPayload(&got); err != nil {
+		t.Errorf("ParseTaggedPayload() without payload = %v", err)
+	}
+}
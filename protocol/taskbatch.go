@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// TaskBatchPayload is sent by hub to assign several monitors at once.
+type TaskBatchPayload struct {
+	Tasks []TaskPayload `json:"tasks"`
+}
+
+// Validate reports whether the batch is non-empty. Individual tasks are
+// checked by ProcessTaskBatch so that one bad task does not fail the rest.
+func (b TaskBatchPayload) Validate() error {
+	if len(b.Tasks) == 0 {
+		return errors.New("task_batch: tasks must not be empty")
+	}
+	return nil
+}
+
+// BatchResult is sent by agent in reply to a task batch. It reports which
+// tasks were accepted, by monitor ID, and why each of the others was rejected,
+// keyed by the task's position in the batch, e.g. "#3". Positions identify
+// rejected tasks even when they lack a monitor ID or repeat one.
+type BatchResult struct {
+	Accepted []string          `json:"accepted,omitempty"`
+	Rejected map[string]string `json:"rejected,omitempty"`
+}
+
+// ProcessTaskBatch checks every task of a batch on its own and reports the
+// outcome per task. A nil validate uses TaskPayload.Validate. A monitor ID
+// that already appeared earlier in the batch is rejected, whether or not the
+// earlier task was valid.
+func ProcessTaskBatch(b TaskBatchPayload, validate func(TaskPayload) error) BatchResult {
+	if validate == nil {
+		validate = TaskPayload.Validate
+	}
+
+	var result BatchResult
+	reject := func(i int, reason string) {
+		if result.Rejected == nil {
+			result.Rejected = make(map[string]string)
+		}
+		result.Rejected["#"+strconv.Itoa(i)] = reason
+	}
+
+	seen := make(map[string]bool, len(b.Tasks))
+	for i, task := range b.Tasks {
+		if task.MonitorID != "" {
+			if seen[task.MonitorID] {
+				reject(i, fmt.Sprintf("duplicate monitor_id %q", task.MonitorID))
+				continue
+			}
+			seen[task.MonitorID] = true
+		}
+		if err := validate(task); err != nil {
+			reject(i, err.Error())
+			continue
+		}
+		result.Accepted = append(result.Accepted, task.MonitorID)
+	}
+	return result
+}
+
+// NewTaskBatchMessage creates a task batch message.
+func NewTaskBatchMessage(tasks []TaskPayload) *Message {
+	return MustNewMessage(MsgTypeTaskBatch, TaskBatchPayload{
+		Tasks: tasks,
+	})
+}
+
+// NewTaskBatchAckMessage creates the reply to a task batch.
+func NewTaskBatchAckMessage(result BatchResult) *Message {
+	return MustNewMessage(MsgTypeTaskBatchAck, result)
+}
@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProcessTaskBatch(t *testing.T) {
+	valid := func(id string) TaskPayload {
+		return TaskPayload{MonitorID: id, Type: "http", Target: "https://example.com", Interval: 30}
+	}
+	tests := []struct {
+		name         string
+		tasks        []TaskPayload
+		validate     func(TaskPayload) error
+		wantAccepted []string
+		wantRejected []string
+	}{
+		{"all valid", []TaskPayload{valid("a"), valid("b")}, nil, []string{"a", "b"}, nil},
+		{"one invalid", []TaskPayload{valid("a"), {MonitorID: "b"}, valid("c")}, nil, []string{"a", "c"}, []string{"#1"}},
+		{"missing id uses position", []TaskPayload{valid("a"), {Type: "http"}}, nil, []string{"a"}, []string{"#1"}},
+		{"duplicate", []TaskPayload{valid("a"), valid("a")}, nil, []string{"a"}, []string{"#1"}},
+		{"duplicate of an invalid task", []TaskPayload{{MonitorID: "a"}, valid("a")}, nil, nil, []string{"#0", "#1"}},
+		{"monitor id shaped like a position", []TaskPayload{{Type: "http"}, {MonitorID: "#0"}}, nil, nil, []string{"#0", "#1"}},
+		{"custom validator", []TaskPayload{valid("a"), valid("b")}, func(task TaskPayload) error {
+			if task.MonitorID == "b" {
+				return errors.New("unsupported")
+			}
+			return nil
+		}, []string{"a"}, []string{"#1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ProcessTaskBatch(TaskBatchPayload{Tasks: tt.tasks}, tt.validate)
+			if !slices.Equal(got.Accepted, tt.wantAccepted) {
+				t.Errorf("Accepted = %v, want %v", got.Accepted, tt.wantAccepted)
+			}
+			rejected := slices.Sorted(maps.Keys(got.Rejected))
+			if !slices.Equal(rejected, tt.wantRejected) {
+				t.Errorf("Rejected = %v, want keys %v", got.Rejected, tt.wantRejected)
+			}
+			for key, reason := range got.Rejected {
+				if reason == "" {
+					t.Errorf("Rejected[%q] has no reason", key)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessTaskBatchDuplicateReason(t *testing.T) {
+	task := TaskPayload{MonitorID: "a", Type: "tcp"}
+	got := ProcessTaskBatch(TaskBatchPayload{Tasks: []TaskPayload{task, task}}, nil)
+	if !strings.Contains(got.Rejected["#1"], `"a"`) {
+		t.Errorf("duplicate reason = %q, want it to name the monitor", got.Rejected["#1"])
+	}
+}
+
+func TestTaskBatchMessages(t *testing.T) {
+	if err := (TaskBatchPayload{}).Validate(); err == nil {
+		t.Error("Validate() accepted an empty batch")
+	}
+	// One bad task does not fail the whole batch message.
+	m := NewTaskBatchMessage([]TaskPayload{{MonitorID: "a", Type: "tcp"}, {}})
+	if err := m.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	ack := NewTaskBatchAckMessage(BatchResult{Accepted: []string{"a"}, Rejected: map[string]string{"#1": "task: monitor_id is required"}})
+	var result BatchResult
+	if err := ack.ParsePayload(&result); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Accepted, []string{"a"}) || len(result.Rejected) != 1 {
+		t.Errorf("round trip = %+v", result)
+	}
+}
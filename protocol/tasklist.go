@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ListTasksPayload is sent by hub to ask the agent which monitors it is
+// running, for diagnosing assignment discrepancies. The agent answers with a
+// task list carrying the same RequestID.
+type ListTasksPayload struct {
+	RequestID string `json:"request_id"`
+}
+
+// Validate reports whether the request can be correlated with its answer.
+func (p ListTasksPayload) Validate() error {
+	if p.RequestID == "" {
+		return errors.New("list_tasks: request_id is required")
+	}
+	return nil
+}
+
+// TaskSummary is the agent's view of one task it is running. Status is the
+// last status it reported for the monitor.
+type TaskSummary struct {
+	MonitorID  string `json:"monitor_id"`
+	Type       string `json:"type"`
+	Status     string `json:"status,omitempty"`
+	ConfigHash string `json:"config_hash"`
+}
+
+// TaskListPayload is sent by agent in answer to a list_tasks request.
+type TaskListPayload struct {
+	RequestID string        `json:"request_id"`
+	Tasks     []TaskSummary `json:"tasks"`
+}
+
+// Validate reports whether the task list is well formed.
+func (p TaskListPayload) Validate() error {
+	if p.RequestID == "" {
+		return errors.New("task_list: request_id is required")
+	}
+	for i, t := range p.Tasks {
+		if t.MonitorID == "" {
+			return fmt.Errorf("task_list: tasks[%d].monitor_id is required", i)
+		}
+	}
+	return nil
+}
+
+// SummarizeTask describes a running task for a task list.
+func SummarizeTask(task TaskPayload, lastStatus string) TaskSummary {
+	return TaskSummary{
+		MonitorID:  task.MonitorID,
+		Type:       task.Type,
+		Status:     lastStatus,
+		ConfigHash: task.ConfigHash(),
+	}
+}
+
+// StateReport converts the task list to a state report, so the hub can
+// compare it with its own assignments using DiffState.
+func (p TaskListPayload) StateReport() StateReportPayload {
+	monitors := make([]MonitorState, len(p.Tasks))
+	for i, t := range p.Tasks {
+		monitors[i] = MonitorState{MonitorID: t.MonitorID, ConfigHash: t.ConfigHash, LastStatus: t.Status}
+	}
+	return StateReportPayload{Monitors: monitors}
+}
+
+// NewListTasksMessage creates a task list request.
+func NewListTasksMessage(requestID string) *Message {
+	return MustNewMessage(MsgTypeListTasks, ListTasksPayload{
+		RequestID: requestID,
+	})
+}
+
+// NewTaskListMessage creates the answer to the list_tasks request requestID.
+func NewTaskListMessage(requestID string, tasks []TaskSummary) *Message {
+	return MustNewMessage(MsgTypeTaskList, TaskListPayload{
+		RequestID: requestID,
+		Tasks:     tasks,
+	})
+}
@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTaskListValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       *Message
+		wantErr bool
+	}{
+		{"list tasks", NewListTasksMessage("req-1"), false},
+		{"list tasks without id", NewListTasksMessage(""), true},
+		{"task list", NewTaskListMessage("req-1", []TaskSummary{{MonitorID: "a", Type: "http"}}), false},
+		{"empty task list", NewTaskListMessage("req-1", nil), false},
+		{"task list without id", NewTaskListMessage("", nil), true},
+		{"task without monitor", NewTaskListMessage("req-1", []TaskSummary{{MonitorID: "a"}, {Type: "tcp"}}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.m.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSummarizeTask(t *testing.T) {
+	task := TaskPayload{MonitorID: "a", Type: "http", Target: "https://example.com", Interval: 30}
+	got := SummarizeTask(task, "down")
+	want := TaskSummary{MonitorID: "a", Type: "http", Status: "down", ConfigHash: task.ConfigHash()}
+	if got != want {
+		t.Errorf("SummarizeTask() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTaskListStateReport(t *testing.T) {
+	http := TaskPayload{MonitorID: "http", Type: "http", Target: "https://example.com", Interval: 30}
+	tcp := TaskPayload{MonitorID: "tcp", Type: "tcp", Target: "db:5432", Interval: 10}
+	stale := tcp
+	stale.Interval = 60
+
+	tests := []struct {
+		name       string
+		running    []TaskSummary
+		assigned   []TaskPayload
+		wantAssign []string
+		wantCancel []string
+	}{
+		{"in sync", []TaskSummary{SummarizeTask(http, "up"), SummarizeTask(tcp, "up")}, []TaskPayload{http, tcp}, nil, nil},
+		{"missing on agent", []TaskSummary{SummarizeTask(http, "up")}, []TaskPayload{http, tcp}, []string{"tcp"}, nil},
+		{"stale config", []TaskSummary{SummarizeTask(http, "up"), SummarizeTask(stale, "up")}, []TaskPayload{http, tcp}, []string{"tcp"}, nil},
+		{"orphan on agent", []TaskSummary{SummarizeTask(http, "up"), SummarizeTask(tcp, "down")}, []TaskPayload{http}, nil, []string{"tcp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := TaskListPayload{RequestID: "req-1", Tasks: tt.running}.StateReport()
+			for i, m := range report.Monitors {
+				if m.LastStatus != tt.running[i].Status {
+					t.Errorf("monitor %s LastStatus = %q, want %q", m.MonitorID, m.LastStatus, tt.running[i].Status)
+				}
+			}
+			diff := DiffState(report, tt.assigned)
+			var assigned []string
+			for _, task := range diff.Assign {
+				assigned = append(assigned, task.MonitorID)
+			}
+			if !slices.Equal(assigned, tt.wantAssign) || !slices.Equal(diff.Cancel, tt.wantCancel) {
+				t.Errorf("DiffState() assign %v cancel %v, want %v and %v", assigned, diff.Cancel, tt.wantAssign, tt.wantCancel)
+			}
+		})
+	}
+}
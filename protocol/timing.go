@@ -0,0 +1,50 @@
+package protocol
+
+import "fmt"
+
+// TimingBreakdown splits an HTTP check's latency into its phases. The phases
+// do not overlap: TTFBMs is measured from the request being written to the
+// first response byte, after DNS, connect and TLS have completed.
+type TimingBreakdown struct {
+	DNSMs     int `json:"dns_ms"`
+	ConnectMs int `json:"connect_ms"`
+	TLSMs     int `json:"tls_ms,omitempty"`
+	TTFBMs    int `json:"ttfb_ms"`
+	TotalMs   int `json:"total_ms"`
+}
+
+// Validate reports whether the phases are non-negative and together fit
+// within the total.
+func (t TimingBreakdown) Validate() error {
+	phases := []struct {
+		name string
+		ms   int
+	}{
+		{"dns_ms", t.DNSMs},
+		{"connect_ms", t.ConnectMs},
+		{"tls_ms", t.TLSMs},
+		{"ttfb_ms", t.TTFBMs},
+		{"total_ms", t.TotalMs},
+	}
+	for _, p := range phases {
+		if p.ms < 0 {
+			return fmt.Errorf("timing: %s must be non-negative, got %d", p.name, p.ms)
+		}
+	}
+	if sum := t.DNSMs + t.ConnectMs + t.TLSMs + t.TTFBMs; sum > t.TotalMs {
+		return fmt.Errorf("timing: phases add up to %dms, more than total_ms %d", sum, t.TotalMs)
+	}
+	return nil
+}
+
+// NewHTTPHeartbeatMessage creates a heartbeat message for an HTTP check with
+// its timing breakdown. The heartbeat latency is the breakdown's total.
+func NewHTTPHeartbeatMessage(monitorID, status string, timing TimingBreakdown, errorMsg string) *Message {
+	return MustNewMessage(MsgTypeHeartbeat, HeartbeatPayload{
+		MonitorID:    monitorID,
+		Status:       status,
+		LatencyMs:    timing.TotalMs,
+		ErrorMessage: errorMsg,
+		Timing:       &timing,
+	})
+}
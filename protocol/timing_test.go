@@ -0,0 +1,46 @@
+package protocol
+
+import "testing"
+
+func TestTimingBreakdownValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		timing  TimingBreakdown
+		wantErr bool
+	}{
+		{"phases within total", TimingBreakdown{DNSMs: 5, ConnectMs: 10, TLSMs: 20, TTFBMs: 50, TotalMs: 90}, false},
+		{"phases equal total", TimingBreakdown{DNSMs: 5, ConnectMs: 10, TTFBMs: 50, TotalMs: 65}, false},
+		{"plain http without tls", TimingBreakdown{DNSMs: 1, ConnectMs: 2, TTFBMs: 3, TotalMs: 10}, false},
+		{"phases exceed total", TimingBreakdown{DNSMs: 5, ConnectMs: 10, TTFBMs: 50, TotalMs: 60}, true},
+		{"negative phase", TimingBreakdown{DNSMs: -1, TotalMs: 10}, true},
+		{"negative total", TimingBreakdown{TotalMs: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.timing.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewHTTPHeartbeatMessage(t *testing.T) {
+	timing := TimingBreakdown{DNSMs: 3, ConnectMs: 7, TLSMs: 12, TTFBMs: 40, TotalMs: 70}
+	m := NewHTTPHeartbeatMessage("mon-1", "up", timing, "")
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var hb HeartbeatPayload
+	if err := m.ParsePayload(&hb); err != nil {
+		t.Fatal(err)
+	}
+	if hb.LatencyMs != 70 || hb.Timing == nil || *hb.Timing != timing {
+		t.Errorf("heartbeat = latency %d, timing %+v; want 70, %+v", hb.LatencyMs, hb.Timing, timing)
+	}
+
+	bad := NewHTTPHeartbeatMessage("mon-1", "up", TimingBreakdown{DNSMs: 100, TotalMs: 10}, "")
+	if err := bad.Validate(); err == nil {
+		t.Error("heartbeat with inconsistent timing passed validation")
+	}
+}
@@ -0,0 +1,177 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxTraceStateEntries is the most list members a tracestate may carry, as
+// set by the W3C Trace Context specification.
+const MaxTraceStateEntries = 32
+
+// TraceStateEntry is one vendor key/value pair of a tracestate.
+type TraceStateEntry struct {
+	Key   string
+	Value string
+}
+
+// TraceContext is W3C trace context carried on the message envelope.
+type TraceContext struct {
+	TraceParent string
+	TraceState  []TraceStateEntry
+}
+
+// InjectTraceContext validates tc and stores it on the message envelope.
+func (m *Message) InjectTraceContext(tc TraceContext) error {
+	if err := ValidateTraceParent(tc.TraceParent); err != nil {
+		return err
+	}
+	state := FormatTraceState(tc.TraceState)
+	if _, err := ParseTraceState(state); err != nil {
+		return err
+	}
+	m.TraceParent = tc.TraceParent
+	m.TraceState = state
+	return nil
+}
+
+// ExtractTraceContext reads the trace context from the message envelope. A
+// message without a traceparent yields an empty context. As the
+// specification requires, a malformed tracestate is discarded rather than
+// failing an otherwise valid traceparent, and a tracestate without a
+// traceparent is ignored.
+func (m *Message) ExtractTraceContext() (TraceContext, error) {
+	if m.TraceParent == "" {
+		return TraceContext{}, nil
+	}
+	if err := ValidateTraceParent(m.TraceParent); err != nil {
+		return TraceContext{}, err
+	}
+	state, err := ParseTraceState(m.TraceState)
+	if err != nil {
+		state = nil
+	}
+	return TraceContext{TraceParent: m.TraceParent, TraceState: state}, nil
+}
+
+// ValidateTraceParent checks a traceparent of the form
+// "00-<32 hex trace id>-<16 hex parent id>-<2 hex flags>".
+func ValidateTraceParent(s string) error {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 {
+		return fmt.Errorf("traceparent %q: expected version-traceid-parentid-flags", s)
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	switch {
+	case !isLowerHex(version, 2) || version == "ff":
+		return fmt.Errorf("traceparent %q: invalid version", s)
+	case version == "00" && len(parts) != 4:
+		return fmt.Errorf("traceparent %q: unexpected trailing data", s)
+	case !isLowerHex(traceID, 32) || strings.Trim(traceID, "0") == "":
+		return fmt.Errorf("traceparent %q: invalid trace id", s)
+	case !isLowerHex(parentID, 16) || strings.Trim(parentID, "0") == "":
+		return fmt.Errorf("traceparent %q: invalid parent id", s)
+	case !isLowerHex(flags, 2):
+		return fmt.Errorf("traceparent %q: invalid flags", s)
+	}
+	return nil
+}
+
+// ParseTraceState parses a comma-separated tracestate header value. Empty
+// list members are skipped. It fails on malformed keys or values, duplicate
+// keys, and more than MaxTraceStateEntries members.
+func ParseTraceState(s string) ([]TraceStateEntry, error) {
+	var entries []TraceStateEntry
+	seen := make(map[string]bool)
+	for _, member := range strings.Split(s, ",") {
+		member = strings.Trim(member, " \t")
+		if member == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(member, "=")
+		if !ok {
+			return nil, fmt.Errorf("tracestate: member %q has no value", member)
+		}
+		if !validTraceStateKey(key) {
+			return nil, fmt.Errorf("tracestate: invalid key %q", key)
+		}
+		if !validTraceStateValue(value) {
+			return nil, fmt.Errorf("tracestate: invalid value for key %q", key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("tracestate: duplicate key %q", key)
+		}
+		seen[key] = true
+		entries = append(entries, TraceStateEntry{Key: key, Value: value})
+		if len(entries) > MaxTraceStateEntries {
+			return nil, fmt.Errorf("tracestate: more than %d entries", MaxTraceStateEntries)
+		}
+	}
+	return entries, nil
+}
+
+// FormatTraceState renders entries as a tracestate header value.
+func FormatTraceState(entries []TraceStateEntry) string {
+	members := make([]string, len(entries))
+	for i, e := range entries {
+		members[i] = e.Key + "=" + e.Value
+	}
+	return strings.Join(members, ",")
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '*' || c == '/'
+}
+
+// validTraceStateKey accepts a simple key or a multi-tenant "tenant@system"
+// key.
+func validTraceStateKey(key string) bool {
+	tenant, system, multi := strings.Cut(key, "@")
+	if !multi {
+		return validKeyPart(key, 256, false)
+	}
+	return validKeyPart(tenant, 241, true) && validKeyPart(system, 14, false)
+}
+
+func validKeyPart(s string, maxLen int, digitFirst bool) bool {
+	if s == "" || len(s) > maxLen {
+		return false
+	}
+	first := s[0]
+	if !(first >= 'a' && first <= 'z' || digitFirst && first >= '0' && first <= '9') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isKeyChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// validTraceStateValue accepts up to 256 printable ASCII characters other
+// than ',' and '=', not ending in a space.
+func validTraceStateValue(v string) bool {
+	if v == "" || len(v) > 256 || v[len(v)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestValidateTraceParent(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"valid", testTraceParent, false},
+		{"future version with extra data", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"version 00 with extra data", testTraceParent + "-extra", true},
+		{"version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"too few parts", "00-4bf92f3577b34da6a3ce929d0e0e4736-01", true},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", true},
+		{"zero parent id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", true},
+		{"upper-case hex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", true},
+		{"short trace id", "00-4bf92f35-00f067aa0ba902b7-01", true},
+		{"bad flags", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateTraceParent(tt.in); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTraceParent(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTraceState(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []TraceStateEntry
+		wantErr bool
+	}{
+		{name: "empty", in: ""},
+		{name: "single", in: "congo=t61rcWkgMzE", want: []TraceStateEntry{{"congo", "t61rcWkgMzE"}}},
+		{name: "multiple with blanks", in: "rojo=00f067aa0ba902b7, ,congo=t61rcWkgMzE",
+			want: []TraceStateEntry{{"rojo", "00f067aa0ba902b7"}, {"congo", "t61rcWkgMzE"}}},
+		{name: "multi-tenant key", in: "tenant1@vendor=x", want: []TraceStateEntry{{"tenant1@vendor", "x"}}},
+		{name: "no value", in: "congo", wantErr: true},
+		{name: "upper-case key", in: "Congo=x", wantErr: true},
+		{name: "duplicate key", in: "a=1,a=2", wantErr: true},
+		{name: "value with equals", in: "a=b=c", wantErr: true},
+		{name: "trailing space in value", in: "a=b \t", want: []TraceStateEntry{{"a", "b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTraceState(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTraceState(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseTraceState(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTraceStateLimit(t *testing.T) {
+	members := make([]string, MaxTraceStateEntries+1)
+	for i := range members {
+		members[i] = fmt.Sprintf("k%d=v", i)
+	}
+	if _, err := ParseTraceState(strings.Join(members[:MaxTraceStateEntries], ",")); err != nil {
+		t.Errorf("rejected %d entries: %v", MaxTraceStateEntries, err)
+	}
+	if _, err := ParseTraceState(strings.Join(members, ",")); err == nil {
+		t.Errorf("accepted %d entries", len(members))
+	}
+}
+
+func TestTraceContextRoundTrip(t *testing.T) {
+	tc := TraceContext{
+		TraceParent: testTraceParent,
+		TraceState:  []TraceStateEntry{{"rojo", "00f067aa0ba902b7"}, {"congo", "t61rcWkgMzE"}},
+	}
+	m := NewHeartbeatMessage("mon-1", "up", 1, "")
+	if err := m.InjectTraceContext(tc); err != nil {
+		t.Fatal(err)
+	}
+	if m.TraceState != "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE" {
+		t.Errorf("TraceState = %q", m.TraceState)
+	}
+
+	got, err := m.ExtractTraceContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TraceParent != tc.TraceParent || !slices.Equal(got.TraceState, tc.TraceState) {
+		t.Errorf("ExtractTraceContext() = %+v, want %+v", got, tc)
+	}
+}
+
+func TestInjectTraceContextRejectsInvalid(t *testing.T) {
+	m := &Message{}
+	if err := m.InjectTraceContext(TraceContext{TraceParent: "bogus"}); err == nil {
+		t.Error("injected an invalid traceparent")
+	}
+	if err := m.InjectTraceContext(TraceContext{TraceParent: testTraceParent, TraceState: []TraceStateEntry{{"BAD", "x"}}}); err == nil {
+		t.Error("injected an invalid tracestate")
+	}
+	if m.TraceParent != "" || m.TraceState != "" {
+		t.Errorf("failed inject changed the envelope: %q %q", m.TraceParent, m.TraceState)
+	}
+}
+
+func TestExtractTraceContextLenience(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		want    TraceContext
+		wantErr bool
+	}{
+		{"nothing", Message{}, TraceContext{}, false},
+		{"state without parent is ignored", Message{TraceState: "a=b"}, TraceContext{}, false},
+		{"malformed state is dropped", Message{TraceParent: testTraceParent, TraceState: "a"}, TraceContext{TraceParent: testTraceParent}, false},
+		{"malformed parent fails", Message{TraceParent: "00-zz"}, TraceContext{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.msg.ExtractTraceContext()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.TraceParent != tt.want.TraceParent || len(got.TraceState) != len(tt.want.TraceState) {
+				t.Errorf("ExtractTraceContext() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TransitionPayload is sent by agent when a monitor's status changes. Unlike
+// heartbeats, which are best-effort telemetry, transitions are acked by the
+// hub and resent until they are, since alerting is driven from them; see
+// TransitionOutbox.
+type TransitionPayload struct {
+	MonitorID string        `json:"monitor_id"`
+	From      MonitorStatus `json:"from"`
+	To        MonitorStatus `json:"to"`
+	At        time.Time     `json:"at"`
+	Reason    string        `json:"reason,omitempty"`
+}
+
+// Validate reports whether the transition is well formed and actually
+// changes the status.
+func (p TransitionPayload) Validate() error {
+	if p.MonitorID == "" {
+		return errors.New("transition: monitor_id is required")
+	}
+	if p.From == "" || p.To == "" {
+		return errors.New("transition: from and to are required")
+	}
+	if p.From == p.To {
+		return fmt.Errorf("transition: from and to are both %q", p.From)
+	}
+	if p.At.IsZero() {
+		return errors.New("transition: at is required")
+	}
+	return nil
+}
+
+// TransitionAckPayload is sent by hub to confirm it recorded a transition.
+// MessageID is the ID of the transition message.
+type TransitionAckPayload struct {
+	MessageID string `json:"message_id"`
+}
+
+// Validate reports whether the ack names the transition it confirms.
+func (p TransitionAckPayload) Validate() error {
+	if p.MessageID == "" {
+		return errors.New("transition_ack: message_id is required")
+	}
+	return nil
+}
+
+// NewTransitionMessage creates a status transition message. It is given an
+// ID, which the hub's ack refers to.
+func NewTransitionMessage(monitorID string, from, to MonitorStatus, at time.Time, reason string) *Message {
+	m := MustNewMessage(MsgTypeTransition, TransitionPayload{
+		MonitorID: monitorID,
+		From:      from,
+		To:        to,
+		At:        at,
+		Reason:    reason,
+	})
+	m.ID = NewMessageID()
+	return m
+}
+
+// NewTransitionAckMessage creates the acknowledgment of a transition message.
+func NewTransitionAckMessage(messageID string) *Message {
+	return MustNewMessage(MsgTypeTransitionAck, TransitionAckPayload{
+		MessageID: messageID,
+	})
+}
+
+// TransitionDetector watches the heartbeats of each monitor and reports when
+// its status changes. It is safe for concurrent use.
+type TransitionDetector struct {
+	mu      sync.Mutex
+	last    map[string]MonitorStatus
+	aliases map[int]string
+}
+
+// NewTransitionDetector creates a detector with no known statuses.
+func NewTransitionDetector() *TransitionDetector {
+	return &TransitionDetector{last: make(map[string]MonitorStatus)}
+}
+
+// SetAliases gives the detector the connection's alias table, so heartbeats
+// that carry only an alias are attributed to their monitor.
+func (d *TransitionDetector) SetAliases(table map[int]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.aliases = table
+}
+
+// Observe records a heartbeat taken at the given time and returns the
+// transition it represents, if any. The first heartbeat of a monitor only
+// establishes its status. The reason is the heartbeat's down reason category,
+// falling back to its error message. A heartbeat that names no monitor, such
+// as one with an alias missing from the table given to SetAliases, is
+// ignored.
+func (d *TransitionDetector) Observe(hb HeartbeatPayload, at time.Time) (TransitionPayload, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	monitorID := hb.monitorIDIn(d.aliases)
+	if monitorID == "" {
+		return TransitionPayload{}, false
+	}
+	to := MonitorStatus(hb.Status)
+	from, known := d.last[monitorID]
+	d.last[monitorID] = to
+	if !known || from == to {
+		return TransitionPayload{}, false
+	}
+
+	reason := hb.ErrorMessage
+	if hb.DownReason != nil {
+		reason = hb.DownReason.Category
+	}
+	return TransitionPayload{
+		MonitorID: monitorID,
+		From:      from,
+		To:        to,
+		At:        at,
+		Reason:    reason,
+	}, true
+}
+
+// Forget drops the known status of a monitor, e.g. after its task is
+// cancelled.
+func (d *TransitionDetector) Forget(monitorID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.last, monitorID)
+}
+
+// maxPendingTransitions bounds how many unacked transitions a
+// TransitionOutbox holds, so a hub that stops acking cannot grow it without
+// limit.
+const maxPendingTransitions = 1024
+
+// TransitionOutbox keeps the transition messages the hub has not acked yet so
+// the agent can resend them, e.g. after a reconnect or when an ack is lost.
+// Once full it drops the oldest transition first. It is safe for concurrent
+// use.
+type TransitionOutbox struct {
+	resendAfter time.Duration
+
+	mu      sync.Mutex
+	pending []outboxEntry
+}
+
+type outboxEntry struct {
+	msg    *Message
+	sentAt time.Time
+}
+
+// NewTransitionOutbox creates an empty outbox that resends a transition once
+// resendAfter has passed without an ack.
+func NewTransitionOutbox(resendAfter time.Duration) *TransitionOutbox {
+	return &TransitionOutbox{resendAfter: resendAfter}
+}
+
+// Track records that m was sent at now. m must be a transition message with
+// an ID, as made by NewTransitionMessage. A full outbox drops its oldest
+// transition to make room.
+func (o *TransitionOutbox) Track(m *Message, now time.Time) error {
+	if m.Type != MsgTypeTransition {
+		return fmt.Errorf("transition outbox: cannot track %q message", m.Type)
+	}
+	if m.ID == "" {
+		return errors.New("transition outbox: message has no id")
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) >= maxPendingTransitions {
+		o.pending = slices.Delete(o.pending, 0, 1)
+	}
+	o.pending = append(o.pending, outboxEntry{msg: m, sentAt: now})
+	return nil
+}
+
+// Ack removes the transition the ack confirms. It reports false for an ack
+// that matches nothing pending, such as a duplicate.
+func (o *TransitionOutbox) Ack(p TransitionAckPayload) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, e := range o.pending {
+		if e.msg.ID == p.MessageID {
+			o.pending = slices.Delete(o.pending, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// Resend returns the unacked transitions sent at least resendAfter before
+// now, oldest first, and counts them as sent again at now.
+func (o *TransitionOutbox) Resend(now time.Time) []*Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var due []*Message
+	for i := range o.pending {
+		if now.Sub(o.pending[i].sentAt) >= o.resendAfter {
+			due = append(due, o.pending[i].msg)
+			o.pending[i].sentAt = now
+		}
+	}
+	return due
+}
+
+// Pending returns the number of unacked transitions.
+func (o *TransitionOutbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
@@ -0,0 +1,234 @@
+package protocol
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTransitionValidate(t *testing.T) {
+	at := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name    string
+		p       TransitionPayload
+		wantErr bool
+	}{
+		{"valid", TransitionPayload{MonitorID: "m", From: StatusUp, To: StatusDown, At: at}, false},
+		{"no monitor", TransitionPayload{From: StatusUp, To: StatusDown, At: at}, true},
+		{"no from", TransitionPayload{MonitorID: "m", To: StatusDown, At: at}, true},
+		{"no change", TransitionPayload{MonitorID: "m", From: StatusUp, To: StatusUp, At: at}, true},
+		{"no time", TransitionPayload{MonitorID: "m", From: StatusUp, To: StatusDown}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := (TransitionAckPayload{}).Validate(); err == nil {
+		t.Error("Validate() accepted an ack without a message ID")
+	}
+}
+
+func TestTransitionDetector(t *testing.T) {
+	at := time.Unix(1_700_000_000, 0)
+	refused := &DownReason{Category: DownCategoryConnect}
+	tests := []struct {
+		name       string
+		heartbeats []HeartbeatPayload
+		want       []TransitionPayload // one per heartbeat, zero when none
+	}{
+		{
+			name:       "first heartbeat",
+			heartbeats: []HeartbeatPayload{{MonitorID: "m", Status: "up"}},
+			want:       []TransitionPayload{{}},
+		},
+		{
+			name:       "same status",
+			heartbeats: []HeartbeatPayload{{MonitorID: "m", Status: "up"}, {MonitorID: "m", Status: "up"}},
+			want:       []TransitionPayload{{}, {}},
+		},
+		{
+			name: "down and back up",
+			heartbeats: []HeartbeatPayload{
+				{MonitorID: "m", Status: "up"},
+				{MonitorID: "m", Status: "down", ErrorMessage: "refused"},
+				{MonitorID: "m", Status: "up"},
+			},
+			want: []TransitionPayload{
+				{},
+				{MonitorID: "m", From: StatusUp, To: StatusDown, At: at, Reason: "refused"},
+				{MonitorID: "m", From: StatusDown, To: StatusUp, At: at},
+			},
+		},
+		{
+			name: "down reason preferred over error message",
+			heartbeats: []HeartbeatPayload{
+				{MonitorID: "m", Status: "up"},
+				{MonitorID: "m", Status: "down", ErrorMessage: "dial tcp: refused", DownReason: refused},
+			},
+			want: []TransitionPayload{{}, {MonitorID: "m", From: StatusUp, To: StatusDown, At: at, Reason: DownCategoryConnect}},
+		},
+		{
+			name: "monitors tracked separately",
+			heartbeats: []HeartbeatPayload{
+				{MonitorID: "a", Status: "up"},
+				{MonitorID: "b", Status: "down"},
+				{MonitorID: "a", Status: "up"},
+			},
+			want: []TransitionPayload{{}, {}, {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewTransitionDetector()
+			for i, hb := range tt.heartbeats {
+				got, ok := d.Observe(hb, at)
+				if ok != (tt.want[i] != TransitionPayload{}) || got != tt.want[i] {
+					t.Errorf("Observe(%d) = %+v, %v, want %+v", i, got, ok, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTransitionDetectorForget(t *testing.T) {
+	d := NewTransitionDetector()
+	d.Observe(HeartbeatPayload{MonitorID: "m", Status: "up"}, time.Now())
+	d.Forget("m")
+	if _, ok := d.Observe(HeartbeatPayload{MonitorID: "m", Status: "down"}, time.Now()); ok {
+		t.Error("Observe() reported a transition for a forgotten monitor")
+	}
+}
+
+func TestTransitionDetectorAliases(t *testing.T) {
+	d := NewTransitionDetector()
+	d.Observe(HeartbeatPayload{Alias: 1, Status: "up"}, time.Now())
+	if _, ok := d.Observe(HeartbeatPayload{Alias: 2, Status: "down"}, time.Now()); ok {
+		t.Error("Observe() reported a transition for a heartbeat without a known monitor")
+	}
+
+	d.SetAliases(map[int]string{1: "a", 2: "b"})
+	d.Observe(HeartbeatPayload{MonitorID: "a", Status: "up"}, time.Now())
+	d.Observe(HeartbeatPayload{Alias: 2, Status: "up"}, time.Now())
+	got, ok := d.Observe(HeartbeatPayload{Alias: 1, Status: "down"}, time.Now())
+	if !ok || got.MonitorID != "a" || got.From != StatusUp || got.To != StatusDown {
+		t.Errorf("Observe() by alias = %+v, %v, want a up to down", got, ok)
+	}
+	if err := got.Validate(); err != nil {
+		t.Errorf("transition by alias is invalid: %v", err)
+	}
+	if _, ok := d.Observe(HeartbeatPayload{Alias: 2, Status: "up"}, time.Now()); ok {
+		t.Error("aliased heartbeats of different monitors share a status")
+	}
+}
+
+func TestNewTransitionMessage(t *testing.T) {
+	a := NewTransitionMessage("m", StatusUp, StatusDown, time.Now(), "")
+	b := NewTransitionMessage("m", StatusUp, StatusDown, time.Now(), "")
+	if a.ID == "" || a.ID == b.ID {
+		t.Errorf("transition IDs = %q and %q, want distinct non-empty IDs", a.ID, b.ID)
+	}
+	if err := a.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	var ack TransitionAckPayload
+	if err := NewTransitionAckMessage(a.ID).ParsePayload(&ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack.MessageID != a.ID {
+		t.Errorf("ack MessageID = %q, want %q", ack.MessageID, a.ID)
+	}
+}
+
+func TestTransitionOutbox(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	o := NewTransitionOutbox(10 * time.Second)
+	first := NewTransitionMessage("a", StatusUp, StatusDown, start, "")
+	second := NewTransitionMessage("b", StatusUp, StatusDown, start, "")
+	if err := o.Track(first, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Track(second, start.Add(5*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(msgs []*Message) []string {
+		var out []string
+		for _, m := range msgs {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+	steps := []struct {
+		at   time.Duration
+		want []string
+	}{
+		{9 * time.Second, nil},
+		{10 * time.Second, []string{first.ID}},
+		{15 * time.Second, []string{second.ID}},
+		{19 * time.Second, nil},
+		{25 * time.Second, []string{first.ID, second.ID}},
+	}
+	for _, s := range steps {
+		if got := ids(o.Resend(start.Add(s.at))); !slices.Equal(got, s.want) {
+			t.Errorf("Resend(+%v) = %v, want %v", s.at, got, s.want)
+		}
+	}
+
+	if !o.Ack(TransitionAckPayload{MessageID: first.ID}) {
+		t.Error("Ack() of a pending transition = false")
+	}
+	if o.Ack(TransitionAckPayload{MessageID: first.ID}) {
+		t.Error("duplicate Ack() = true")
+	}
+	if o.Pending() != 1 {
+		t.Errorf("Pending() = %d, want 1", o.Pending())
+	}
+	if got := ids(o.Resend(start.Add(time.Minute))); !slices.Equal(got, []string{second.ID}) {
+		t.Errorf("Resend() after ack = %v, want only the unacked transition", got)
+	}
+}
+
+func TestTransitionOutboxBounded(t *testing.T) {
+	o := NewTransitionOutbox(time.Second)
+	start := time.Unix(1_700_000_000, 0)
+	var first, second *Message
+	for i := range maxPendingTransitions + 1 {
+		m := NewTransitionMessage("m", StatusUp, StatusDown, start, "")
+		if err := o.Track(m, start); err != nil {
+			t.Fatal(err)
+		}
+		switch i {
+		case 0:
+			first = m
+		case 1:
+			second = m
+		}
+	}
+	if o.Pending() != maxPendingTransitions {
+		t.Errorf("Pending() = %d, want %d", o.Pending(), maxPendingTransitions)
+	}
+	if o.Ack(TransitionAckPayload{MessageID: first.ID}) {
+		t.Error("oldest transition not dropped")
+	}
+	if !o.Ack(TransitionAckPayload{MessageID: second.ID}) {
+		t.Error("second oldest transition dropped")
+	}
+}
+
+func TestTransitionOutboxTrackRejects(t *testing.T) {
+	o := NewTransitionOutbox(time.Second)
+	noID := NewTransitionMessage("m", StatusUp, StatusDown, time.Now(), "")
+	noID.ID = ""
+	for name, m := range map[string]*Message{"other type": NewPingMessage(), "no id": noID} {
+		if err := o.Track(m, time.Now()); err == nil {
+			t.Errorf("Track(%s) succeeded", name)
+		}
+	}
+	if o.Pending() != 0 {
+		t.Errorf("Pending() = %d, want 0", o.Pending())
+	}
+}
@@ -0,0 +1,41 @@
+package protocol
+
+import "time"
+
+// Expired reports whether m is older than its TTL at now. Messages without a
+// TTL never expire.
+func (m *Message) Expired(now time.Time) bool {
+	return ExpiredWithSkew(m, now, 0)
+}
+
+// ExpiredWithSkew reports whether m has outlived its TTL, allowing the
+// sender's clock to be up to tolerance away from ours. The validity window is
+// widened by tolerance, so a message is only dropped once it is stale under
+// any clock offset within the tolerance. Messages without a TTL never expire.
+func ExpiredWithSkew(m *Message, now time.Time, tolerance time.Duration) bool {
+	if m.TTLMs <= 0 {
+		return false
+	}
+	return now.Sub(m.Timestamp) > ttlDuration(m)+tolerance
+}
+
+// ExpiredStrict is the conservative counterpart of ExpiredWithSkew for
+// security-sensitive messages: it assumes the worst clock offset within
+// tolerance, so a message is rejected once it might have outlived its TTL.
+// Messages stamped further in the future than the tolerance are rejected as
+// well, since their age cannot be trusted. Messages without a TTL never
+// expire.
+func ExpiredStrict(m *Message, now time.Time, tolerance time.Duration) bool {
+	if m.TTLMs <= 0 {
+		return false
+	}
+	age := now.Sub(m.Timestamp)
+	if age < -tolerance {
+		return true
+	}
+	return age+tolerance > ttlDuration(m)
+}
+
+func ttlDuration(m *Message) time.Duration {
+	return time.Duration(m.TTLMs) * time.Millisecond
+}
@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpired(t *testing.T) {
+	sent := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name       string
+		ttlMs      int64
+		age        time.Duration
+		tolerance  time.Duration
+		want       bool
+		wantStrict bool
+	}{
+		{"no ttl", 0, time.Hour, 0, false, false},
+		{"negative ttl", -1, time.Hour, 0, false, false},
+		{"fresh", 1000, 500 * time.Millisecond, 0, false, false},
+		{"exactly at ttl", 1000, time.Second, 0, false, false},
+		{"just past ttl", 1000, time.Second + time.Millisecond, 0, true, true},
+		{"past ttl within tolerance", 1000, 1200 * time.Millisecond, 500 * time.Millisecond, false, true},
+		{"past ttl and tolerance", 1000, 1600 * time.Millisecond, 500 * time.Millisecond, true, true},
+		{"near ttl under strict tolerance", 1000, 600 * time.Millisecond, 500 * time.Millisecond, false, true},
+		{"strict boundary", 1000, 500 * time.Millisecond, 500 * time.Millisecond, false, false},
+		{"future within tolerance", 1000, -300 * time.Millisecond, 500 * time.Millisecond, false, false},
+		{"future beyond tolerance", 1000, -600 * time.Millisecond, 500 * time.Millisecond, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewPingMessage()
+			m.Timestamp, m.TTLMs = sent, tt.ttlMs
+			now := sent.Add(tt.age)
+			if got := ExpiredWithSkew(m, now, tt.tolerance); got != tt.want {
+				t.Errorf("ExpiredWithSkew() = %v, want %v", got, tt.want)
+			}
+			if got := ExpiredStrict(m, now, tt.tolerance); got != tt.wantStrict {
+				t.Errorf("ExpiredStrict() = %v, want %v", got, tt.wantStrict)
+			}
+			if tt.tolerance == 0 {
+				if got := m.Expired(now); got != tt.want {
+					t.Errorf("Expired() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// Update progress reported in UpdateStatusPayload.
+const (
+	UpdateStatusDownloading = "downloading"
+	UpdateStatusApplying    = "applying"
+	UpdateStatusFailed      = "failed"
+	UpdateStatusCompleted   = "completed"
+)
+
+// UpdateStatusPayload is sent by agent to report progress on an update it was
+// offered. Error explains a failed update.
+type UpdateStatusPayload struct {
+	Version string `json:"version"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Validate reports whether the update status is well formed.
+func (p UpdateStatusPayload) Validate() error {
+	if _, err := ParseVersion(p.Version); err != nil {
+		return fmt.Errorf("update_status: %w", err)
+	}
+	switch p.Status {
+	case UpdateStatusDownloading, UpdateStatusApplying, UpdateStatusFailed, UpdateStatusCompleted:
+	default:
+		return fmt.Errorf("update_status: unknown status %q", p.Status)
+	}
+	if p.Status == UpdateStatusFailed && p.Error == "" {
+		return errors.New("update_status: error is required for a failed update")
+	}
+	return nil
+}
+
+// NewMandatoryUpdateAvailableMessage creates an update available message for
+// an update the agent must apply.
+func NewMandatoryUpdateAvailableMessage(version, downloadURL, sha256, signature string) *Message {
+	return MustNewMessage(MsgTypeUpdateAvailable, UpdateAvailablePayload{
+		Version:     version,
+		DownloadURL: downloadURL,
+		SHA256:      sha256,
+		Signature:   signature,
+		Mandatory:   true,
+	})
+}
+
+// NewUpdateStatusMessage creates an update progress message.
+func NewUpdateStatusMessage(version, status, errMsg string) *Message {
+	return MustNewMessage(MsgTypeUpdateStatus, UpdateStatusPayload{
+		Version: version,
+		Status:  status,
+		Error:   errMsg,
+	})
+}
+
+// InUpdateRollout reports whether an agent is among the first percent of the
+// fleet to be offered version, so the hub can stagger an update. The choice
+// is stable for a version and raising percent only adds agents, but each
+// version picks a different first wave.
+func InUpdateRollout(agentID, version string, percent int) bool {
+	h := fnv.New64a()
+	h.Write([]byte(version))
+	h.Write([]byte{0})
+	h.Write([]byte(agentID))
+	return mix64(h.Sum64())%100 < uint64(max(percent, 0))
+}
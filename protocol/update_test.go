@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUpdateStatusValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       UpdateStatusPayload
+		wantErr bool
+	}{
+		{"downloading", UpdateStatusPayload{Version: "1.4.0", Status: UpdateStatusDownloading}, false},
+		{"applying", UpdateStatusPayload{Version: "v1.4.0", Status: UpdateStatusApplying}, false},
+		{"completed", UpdateStatusPayload{Version: "1.4.0", Status: UpdateStatusCompleted}, false},
+		{"failed with error", UpdateStatusPayload{Version: "1.4.0", Status: UpdateStatusFailed, Error: "checksum mismatch"}, false},
+		{"failed without error", UpdateStatusPayload{Version: "1.4.0", Status: UpdateStatusFailed}, true},
+		{"unknown status", UpdateStatusPayload{Version: "1.4.0", Status: "paused"}, true},
+		{"bad version", UpdateStatusPayload{Version: "latest", Status: UpdateStatusCompleted}, true},
+		{"no version", UpdateStatusPayload{Status: UpdateStatusCompleted}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateMessages(t *testing.T) {
+	var offer UpdateAvailablePayload
+	if err := NewMandatoryUpdateAvailableMessage("1.4.0", "https://example.com/agent", "abc", "sig").ParsePayload(&offer); err != nil {
+		t.Fatal(err)
+	}
+	if !offer.Mandatory || offer.Version != "1.4.0" || offer.SHA256 != "abc" {
+		t.Errorf("mandatory offer = %+v", offer)
+	}
+
+	m := NewUpdateStatusMessage("1.4.0", UpdateStatusFailed, "disk full")
+	if err := m.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	var status UpdateStatusPayload
+	if err := m.ParsePayload(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != (UpdateStatusPayload{Version: "1.4.0", Status: UpdateStatusFailed, Error: "disk full"}) {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestInUpdateRollout(t *testing.T) {
+	agents := make([]string, 2000)
+	for i := range agents {
+		agents[i] = fmt.Sprintf("agent-%d", i)
+	}
+	inWave := func(version string, percent int) map[string]bool {
+		wave := make(map[string]bool)
+		for _, id := range agents {
+			if InUpdateRollout(id, version, percent) {
+				wave[id] = true
+			}
+		}
+		return wave
+	}
+
+	tests := []struct {
+		percent  int
+		min, max int
+	}{
+		{-5, 0, 0},
+		{0, 0, 0},
+		{10, 140, 260},
+		{50, 900, 1100},
+		{100, 2000, 2000},
+		{150, 2000, 2000},
+	}
+	for _, tt := range tests {
+		if n := len(inWave("1.4.0", tt.percent)); n < tt.min || n > tt.max {
+			t.Errorf("%d%% rollout picked %d of %d agents, want %d to %d", tt.percent, n, len(agents), tt.min, tt.max)
+		}
+	}
+
+	small, large := inWave("1.4.0", 10), inWave("1.4.0", 30)
+	for id := range small {
+		if !large[id] {
+			t.Fatalf("raising the rollout dropped %s", id)
+		}
+	}
+	if again := inWave("1.4.0", 10); len(again) != len(small) {
+		t.Error("rollout choice is not stable")
+	}
+	other := inWave("1.5.0", 10)
+	shared := 0
+	for id := range other {
+		if small[id] {
+			shared++
+		}
+	}
+	if shared == len(small) {
+		t.Error("each version picks the same first wave")
+	}
+}
@@ -0,0 +1,187 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate reports whether the message is well formed: its type must be
+// known, its trace context valid, and its payload must decode and pass the
+// payload's own validation.
+func (m *Message) Validate() error {
+	if m == nil {
+		return errors.New("message is nil")
+	}
+	if m.Type == "" {
+		return errors.New("message type is required")
+	}
+	if m.TraceParent != "" {
+		if err := ValidateTraceParent(m.TraceParent); err != nil {
+			return err
+		}
+	}
+	payload, err := m.DecodePayload()
+	if err != nil {
+		return err
+	}
+	if v, ok := payload.(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// IndexedError is a validation error for the message at Index of a slice.
+type IndexedError struct {
+	Index int
+	Err   error
+}
+
+func (e IndexedError) Error() string {
+	return fmt.Sprintf("message %d: %v", e.Index, e.Err)
+}
+
+func (e IndexedError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateAll validates every message and reports each invalid one with its
+// index, rather than stopping at the first failure. The result is empty when
+// all messages are valid.
+func ValidateAll(msgs []*Message) []IndexedError {
+	var errs []IndexedError
+	for i, m := range msgs {
+		if err := m.Validate(); err != nil {
+			errs = append(errs, IndexedError{Index: i, Err: err})
+		}
+	}
+	return errs
+}
+
+// Validate reports whether the authentication request is well formed.
+func (a AuthPayload) Validate() error {
+	if a.APIKey == "" {
+		return errors.New("auth: api_key is required")
+	}
+	if a.Version != "" {
+		if _, err := ParseVersion(a.Version); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	if a.DesiredMessageRate < 0 {
+		return fmt.Errorf("auth: desired_message_rate must be non-negative, got %d", a.DesiredMessageRate)
+	}
+	if a.NetworkConstraints != nil {
+		for _, port := range a.NetworkConstraints.AllowedPorts {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("auth: network_constraints.allowed_ports: invalid port %d", port)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate reports whether the authentication acknowledgment is well formed.
+func (a AuthAckPayload) Validate() error {
+	if a.AgentID == "" {
+		return errors.New("auth_ack: agent_id is required")
+	}
+	if a.GrantedMessageRate < 0 {
+		return fmt.Errorf("auth_ack: granted_message_rate must be non-negative, got %d", a.GrantedMessageRate)
+	}
+	if _, err := dictionary(a.DictionaryVersion); err != nil {
+		return fmt.Errorf("auth_ack: %w", err)
+	}
+	return nil
+}
+
+// Validate reports whether the update announcement is well formed.
+func (u UpdateAvailablePayload) Validate() error {
+	if _, err := ParseVersion(u.Version); err != nil {
+		return fmt.Errorf("update_available: %w", err)
+	}
+	if u.DownloadURL == "" {
+		return errors.New("update_available: download_url is required")
+	}
+	if u.SHA256 == "" {
+		return errors.New("update_available: sha256 is required")
+	}
+	return nil
+}
+
+// Validate reports whether the task is well formed.
+func (t TaskPayload) Validate() error {
+	if t.MonitorID == "" {
+		return errors.New("task: monitor_id is required")
+	}
+	if t.Type == "" {
+		return errors.New("task: type is required")
+	}
+	if t.Interval < 0 {
+		return fmt.Errorf("task: interval must be non-negative, got %d", t.Interval)
+	}
+	if t.Timeout < 0 {
+		return fmt.Errorf("task: timeout must be non-negative, got %d", t.Timeout)
+	}
+	if t.ResultCacheTTLMs < 0 {
+		return fmt.Errorf("task: result_cache_ttl_ms must be non-negative, got %d", t.ResultCacheTTLMs)
+	}
+	if t.ConfigRevision < 0 {
+		return fmt.Errorf("task: config_revision must be non-negative, got %d", t.ConfigRevision)
+	}
+	if t.Alias < 0 {
+		return fmt.Errorf("task: alias must be non-negative, got %d", t.Alias)
+	}
+	if t.MaxChecksPerMinute < 0 {
+		return fmt.Errorf("task: max_checks_per_minute must be non-negative, got %d", t.MaxChecksPerMinute)
+	}
+	if err := t.validateBanner(); err != nil {
+		return err
+	}
+	if t.SLO != nil {
+		if err := t.SLO.Validate(); err != nil {
+			return fmt.Errorf("task: %w", err)
+		}
+	}
+	for i, w := range t.MaintenanceWindows {
+		if !w.End.After(w.Start) {
+			return fmt.Errorf("task: maintenance_windows[%d] must end after it starts", i)
+		}
+		if w.Start.Year() < 0 || w.End.Year() > 9999 {
+			return fmt.Errorf("task: maintenance_windows[%d] must fall within years 0 to 9999", i)
+		}
+	}
+	return nil
+}
+
+// Validate reports whether the heartbeat is well formed.
+func (h HeartbeatPayload) Validate() error {
+	if h.MonitorID == "" && h.Alias == 0 {
+		return errors.New("heartbeat: monitor_id or alias is required")
+	}
+	if h.Alias < 0 {
+		return fmt.Errorf("heartbeat: alias must be non-negative, got %d", h.Alias)
+	}
+	if h.Status == "" {
+		return errors.New("heartbeat: status is required")
+	}
+	if h.LatencyMs < 0 {
+		return fmt.Errorf("heartbeat: latency_ms must be non-negative, got %d", h.LatencyMs)
+	}
+	if h.ThrottledChecks < 0 {
+		return fmt.Errorf("heartbeat: throttled_checks must be non-negative, got %d", h.ThrottledChecks)
+	}
+	if h.Timing != nil {
+		if err := h.Timing.Validate(); err != nil {
+			return fmt.Errorf("heartbeat: %w", err)
+		}
+	}
+	if h.DownReason != nil {
+		if err := h.DownReason.Validate(); err != nil {
+			return fmt.Errorf("heartbeat: %w", err)
+		}
+	}
+	if err := validateAssertions(h.Assertions); err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+	return nil
+}
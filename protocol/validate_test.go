@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMessageValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     *Message
+		wantErr bool
+	}{
+		{"nil", nil, true},
+		{"no type", &Message{}, true},
+		{"unknown type", &Message{Type: "bogus"}, true},
+		{"valid ping", NewPingMessage(), false},
+		{"valid heartbeat", NewHeartbeatMessage("mon-1", "up", 5, ""), false},
+		{"invalid heartbeat", NewHeartbeatMessage("", "up", 5, ""), true},
+		{"invalid payload json", &Message{Type: MsgTypeHeartbeat, Payload: json.RawMessage(`{"latency_ms":"x"}`)}, true},
+		{"invalid traceparent", &Message{Type: MsgTypePing, TraceParent: "00-zz"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.msg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	msgs := []*Message{
+		NewPingMessage(),
+		NewHeartbeatMessage("", "up", 5, ""),
+		NewHeartbeatMessage("mon-1", "up", 5, ""),
+		{Type: "bogus"},
+	}
+	errs := ValidateAll(msgs)
+	if len(errs) != 2 {
+		t.Fatalf("ValidateAll() returned %d errors, want 2: %v", len(errs), errs)
+	}
+	if errs[0].Index != 1 || errs[1].Index != 3 {
+		t.Errorf("indexes = %d, %d, want 1, 3", errs[0].Index, errs[1].Index)
+	}
+	if !errors.Is(errs[1], ErrUnknownMessageType) {
+		t.Errorf("errs[1] = %v, want it to wrap ErrUnknownMessageType", errs[1])
+	}
+	if got := errs[0].Error(); got != "message 1: heartbeat: monitor_id or alias is required" {
+		t.Errorf("Error() = %q", got)
+	}
+
+	if errs := ValidateAll([]*Message{NewPingMessage()}); len(errs) != 0 {
+		t.Errorf("ValidateAll() on valid messages = %v", errs)
+	}
+	if errs := ValidateAll(nil); len(errs) != 0 {
+		t.Errorf("ValidateAll(nil) = %v", errs)
+	}
+}
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version as described by https://semver.org.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+	Build      string
+}
+
+// ParseVersion parses a semantic version such as "1.4.2", "1.0.0-rc.1" or
+// "2.0.0+build.7". A leading "v" is accepted since agent builds are tagged
+// that way.
+func ParseVersion(s string) (Version, error) {
+	rest := strings.TrimPrefix(s, "v")
+
+	var v Version
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		v.Build = rest[i+1:]
+		rest = rest[:i]
+		if err := checkIdentifiers(v.Build, false); err != nil {
+			return Version{}, fmt.Errorf("version %q: build metadata: %w", s, err)
+		}
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		v.PreRelease = rest[i+1:]
+		rest = rest[:i]
+		if err := checkIdentifiers(v.PreRelease, true); err != nil {
+			return Version{}, fmt.Errorf("version %q: pre-release: %w", s, err)
+		}
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("version %q: expected MAJOR.MINOR.PATCH", s)
+	}
+	nums := [3]*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := parseNumericIdentifier(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("version %q: %w", s, err)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or +1 depending on whether v has lower, equal or
+// higher precedence than other. Build metadata is ignored.
+func (v Version) Compare(other Version) int {
+	if c := compareInt(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	return comparePreRelease(v.PreRelease, other.PreRelease)
+}
+
+// Less reports whether v has lower precedence than other.
+func (v Version) Less(other Version) bool {
+	return v.Compare(other) < 0
+}
+
+// Compatible reports whether v and other can talk to each other: the major
+// versions must match, and while the major version is 0 the minor versions
+// must match too.
+func (v Version) Compatible(other Version) bool {
+	if v.Major != other.Major {
+		return false
+	}
+	if v.Major == 0 {
+		return v.Minor == other.Minor
+	}
+	return true
+}
+
+// String formats the version in canonical form without a "v" prefix.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePreRelease implements semver rule 11: a version without a
+// pre-release outranks one with it, numeric identifiers compare numerically
+// and rank below alphanumeric ones, and a longer identifier list wins ties.
+func comparePreRelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		aNum, bNum := isNumeric(as[i]), isNumeric(bs[i])
+		switch {
+		case aNum && bNum:
+			// Without leading zeros a longer number is always larger.
+			if c := compareInt(len(as[i]), len(bs[i])); c != 0 {
+				return c
+			}
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		case aNum:
+			return -1
+		case bNum:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(as), len(bs))
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func parseNumericIdentifier(s string) (int, error) {
+	if !isNumeric(s) {
+		return 0, fmt.Errorf("numeric identifier %q is not a number", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("numeric identifier %q has a leading zero", s)
+	}
+	return strconv.Atoi(s)
+}
+
+// checkIdentifiers validates a dot-separated list of identifiers. Numeric
+// pre-release identifiers must not have leading zeros; build metadata has no
+// such restriction.
+func checkIdentifiers(s string, preRelease bool) error {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return errors.New("empty identifier")
+		}
+		for _, c := range id {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return fmt.Errorf("identifier %q has invalid character %q", id, c)
+			}
+		}
+		if preRelease && isNumeric(id) && len(id) > 1 && id[0] == '0' {
+			return fmt.Errorf("identifier %q has a leading zero", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package protocol
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Version
+		wantErr bool
+	}{
+		{in: "1.4.2", want: Version{Major: 1, Minor: 4, Patch: 2}},
+		{in: "v1.4.2", want: Version{Major: 1, Minor: 4, Patch: 2}},
+		{in: "1.0.0-rc.1", want: Version{Major: 1, PreRelease: "rc.1"}},
+		{in: "2.0.0+build.7", want: Version{Major: 2, Build: "build.7"}},
+		{in: "1.0.0-alpha+001", want: Version{Major: 1, PreRelease: "alpha", Build: "001"}},
+		{in: "", wantErr: true},
+		{in: "1.2", wantErr: true},
+		{in: "1.2.3.4", wantErr: true},
+		{in: "01.2.3", wantErr: true},
+		{in: "1.x.3", wantErr: true},
+		{in: "1.2.3-", wantErr: true},
+		{in: "1.2.3-01", wantErr: true},
+		{in: "1.2.3-a..b", wantErr: true},
+		{in: "1.2.3+b_1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseVersion(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// Ascending precedence, from the semver specification.
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, b := mustParseVersion(t, ordered[i]), mustParseVersion(t, ordered[j])
+			want := compareInt(i, j)
+			if got := a.Compare(b); got != want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+
+	if c := mustParseVersion(t, "1.0.0+a").Compare(mustParseVersion(t, "1.0.0+b")); c != 0 {
+		t.Errorf("build metadata affected precedence: %d", c)
+	}
+}
+
+func TestVersionCompatible(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.0", "1.9.3", true},
+		{"1.2.0", "2.0.0", false},
+		{"0.3.1", "0.3.9", true},
+		{"0.3.1", "0.4.0", false},
+	}
+	for _, tt := range tests {
+		if got := mustParseVersion(t, tt.a).Compatible(mustParseVersion(t, tt.b)); got != tt.want {
+			t.Errorf("%s.Compatible(%s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	for _, s := range []string{"1.2.3", "1.0.0-rc.1", "2.0.0+build.7", "1.0.0-alpha+001"} {
+		if got := mustParseVersion(t, s).String(); got != s {
+			t.Errorf("String() = %q, want %q", got, s)
+		}
+	}
+	if got := mustParseVersion(t, "v1.2.3").String(); got != "1.2.3" {
+		t.Errorf("String() kept the v prefix: %q", got)
+	}
+}
+
+func TestVersionFieldsValidated(t *testing.T) {
+	if err := (AuthPayload{APIKey: "k", Version: "latest"}).Validate(); err == nil {
+		t.Error("auth accepted a non-semver version")
+	}
+	if err := (AuthPayload{APIKey: "k", Version: "v1.2.3"}).Validate(); err != nil {
+		t.Errorf("auth rejected a valid version: %v", err)
+	}
+	u := UpdateAvailablePayload{Version: "1.2", DownloadURL: "https://x", SHA256: "ab"}
+	if err := u.Validate(); err == nil {
+		t.Error("update_available accepted a non-semver version")
+	}
+}
+
+func mustParseVersion(t *testing.T, s string) Version {
+	t.Helper()
+	v, err := ParseVersion(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}